@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errNoToken = errors.New("no access token")
+
+func requestClaims(c *gin.Context) (string, string, error) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token, _ = c.Cookie("access_token")
+	}
+	if token == "" {
+		return "", "", errNoToken
+	}
+
+	claims, err := ParseJwtToken(token)
+	if err != nil {
+		return "", "", err
+	}
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	return email, role, nil
+}
+
+func abortUnauthenticated(c *gin.Context, err error) {
+	if errors.Is(err, errNoToken) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+}
+
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if email, role, err := requestClaims(c); err == nil {
+			c.Set("email", email)
+			c.Set("role", role)
+		}
+		c.Next()
+	}
+}
+
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, role, err := requestClaims(c)
+		if err != nil {
+			abortUnauthenticated(c, err)
+			return
+		}
+		c.Set("email", email)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, userRole, err := requestClaims(c)
+		if err != nil {
+			abortUnauthenticated(c, err)
+			return
+		}
+		if !strings.EqualFold(userRole, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Set("email", email)
+		c.Set("role", userRole)
+		c.Next()
+	}
+}
@@ -0,0 +1,487 @@
+package config
+
+import (
+	"filepackage/utils"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type migration struct {
+	ID         string
+	Statements []string
+	Run        func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{
+		ID: "0001_cansettings_versions",
+		Statements: []string{
+			`ALTER TABLE "FileTracker"."parsedfiles" ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1`,
+			`ALTER TABLE "FileTracker"."parsedfiles" ADD COLUMN IF NOT EXISTS contenthash text NOT NULL DEFAULT ''`,
+			`ALTER TABLE "FileTracker"."parsedfiles" ADD COLUMN IF NOT EXISTS iscurrent boolean NOT NULL DEFAULT false`,
+			`UPDATE "FileTracker"."parsedfiles" p SET version = v.rn
+				FROM (SELECT ctid, row_number() OVER (PARTITION BY filename ORDER BY createdat, fileid) AS rn FROM "FileTracker"."parsedfiles") v
+				WHERE p.ctid = v.ctid`,
+			`UPDATE "FileTracker"."parsedfiles" p SET iscurrent = true
+				FROM (SELECT filename, MAX(version) AS version FROM "FileTracker"."parsedfiles" GROUP BY filename) m
+				WHERE p.filename = m.filename AND p.version = m.version`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS parsedfiles_filename_version_idx ON "FileTracker"."parsedfiles" (filename, version)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS parsedfiles_filename_current_idx ON "FileTracker"."parsedfiles" (filename) WHERE iscurrent`,
+			`ALTER TABLE "LAFPackages"."packages" ADD COLUMN IF NOT EXISTS mainsettingsversion integer NOT NULL DEFAULT 0`,
+		},
+		Run: backfillCanSettingsHashes,
+	},
+	{
+		ID: "0002_settings_search_indexes",
+		Statements: []string{
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`CREATE INDEX IF NOT EXISTS parsedfiles_filename_trgm_idx ON "FileTracker"."parsedfiles" USING gin (filename gin_trgm_ops)`,
+			`CREATE INDEX IF NOT EXISTS parsedfiles_content_fts_idx ON "FileTracker"."parsedfiles"
+				USING gin (jsonb_to_tsvector('simple', jsondata::jsonb, '["key", "string"]'))`,
+			`CREATE INDEX IF NOT EXISTS nrf_parsedfiles_filename_trgm_idx ON "NrfSettings"."parsedfiles" USING gin (filename gin_trgm_ops)`,
+			`CREATE INDEX IF NOT EXISTS nrf_parsedfiles_content_fts_idx ON "NrfSettings"."parsedfiles"
+				USING gin ((jsonb_to_tsvector('simple', jsondata::jsonb, '["key", "string"]') || jsonb_to_tsvector('simple', sleepcdns::jsonb, '["key", "string"]')))`,
+		},
+	},
+	{
+		ID: "0003_settings_schemas",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."settingsschemas" (
+				id serial PRIMARY KEY,
+				settingstype text NOT NULL,
+				filetype integer,
+				version integer NOT NULL,
+				schema jsonb NOT NULL,
+				isactive boolean NOT NULL DEFAULT false,
+				description text NOT NULL DEFAULT '',
+				createdby text NOT NULL DEFAULT '',
+				createdat bigint NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS settingsschemas_version_idx ON "FileTracker"."settingsschemas" (settingstype, COALESCE(filetype, -1), version)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS settingsschemas_active_idx ON "FileTracker"."settingsschemas" (settingstype, COALESCE(filetype, -1)) WHERE isactive`,
+			`ALTER TABLE "FileTracker"."parsedfiles" ADD COLUMN IF NOT EXISTS schemaid integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS schemaid integer NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		ID: "0004_value_mapping_dictionaries",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."valuemappingdicts" (
+				id serial PRIMARY KEY,
+				name text NOT NULL UNIQUE,
+				description text NOT NULL DEFAULT '',
+				settingstype text NOT NULL DEFAULT '',
+				owner text NOT NULL,
+				visibility text NOT NULL DEFAULT 'private',
+				createdat bigint NOT NULL,
+				updatedat bigint NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."valuemappingentries" (
+				id serial PRIMARY KEY,
+				dictid integer NOT NULL REFERENCES "FileTracker"."valuemappingdicts" (id) ON DELETE CASCADE,
+				keypath text NOT NULL,
+				value text NOT NULL,
+				displayvalue text NOT NULL,
+				UNIQUE (dictid, keypath, value)
+			)`,
+		},
+	},
+	{
+		ID: "0005_nrfsettings_versions",
+		Statements: []string{
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1`,
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS contenthash text NOT NULL DEFAULT ''`,
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS iscurrent boolean NOT NULL DEFAULT false`,
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS updatedby text NOT NULL DEFAULT ''`,
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS updatedat bigint NOT NULL DEFAULT 0`,
+			`UPDATE "NrfSettings"."parsedfiles" SET updatedby = COALESCE(createdby, ''), updatedat = COALESCE(createdat, 0)`,
+			`UPDATE "NrfSettings"."parsedfiles" p SET version = v.rn
+				FROM (SELECT ctid, row_number() OVER (PARTITION BY filename ORDER BY createdat, ctid) AS rn FROM "NrfSettings"."parsedfiles") v
+				WHERE p.ctid = v.ctid`,
+			`UPDATE "NrfSettings"."parsedfiles" p SET iscurrent = true
+				FROM (SELECT filename, MAX(version) AS version FROM "NrfSettings"."parsedfiles" GROUP BY filename) m
+				WHERE p.filename = m.filename AND p.version = m.version`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS nrf_parsedfiles_filename_version_idx ON "NrfSettings"."parsedfiles" (filename, version)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS nrf_parsedfiles_filename_current_idx ON "NrfSettings"."parsedfiles" (filename) WHERE iscurrent`,
+			`CREATE TABLE IF NOT EXISTS "NrfSettings"."filetypes" (
+				id integer PRIMARY KEY,
+				name text NOT NULL UNIQUE,
+				description text NOT NULL DEFAULT ''
+			)`,
+			`INSERT INTO "NrfSettings"."filetypes" (id, name)
+				SELECT DISTINCT filetype, 'filetype ' || filetype FROM "NrfSettings"."parsedfiles"
+				ON CONFLICT DO NOTHING`,
+		},
+		Run: backfillNrfSettingsHashes,
+	},
+	{
+		ID: "0006_lint_rules",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."lintrules" (
+				id serial PRIMARY KEY,
+				groupid integer NOT NULL DEFAULT 0,
+				ruleid text NOT NULL,
+				enabled boolean NOT NULL DEFAULT true,
+				severity text NOT NULL DEFAULT '',
+				params jsonb,
+				updatedby text NOT NULL DEFAULT '',
+				updatedat bigint NOT NULL DEFAULT 0,
+				UNIQUE (groupid, ruleid)
+			)`,
+		},
+	},
+	{
+		ID: "0007_settings_templates",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."settingstemplates" (
+				id serial PRIMARY KEY,
+				name text NOT NULL UNIQUE,
+				settingstype text NOT NULL,
+				filetype integer,
+				version integer NOT NULL DEFAULT 1,
+				description text NOT NULL DEFAULT '',
+				parameters jsonb NOT NULL DEFAULT '[]',
+				body jsonb NOT NULL,
+				createdby text NOT NULL DEFAULT '',
+				updatedby text NOT NULL DEFAULT '',
+				createdat bigint NOT NULL,
+				updatedat bigint NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS "FileTracker"."settingsgenerations" (
+				id serial PRIMARY KEY,
+				templateid integer NOT NULL REFERENCES "FileTracker"."settingstemplates" (id),
+				templateversion integer NOT NULL,
+				settingstype text NOT NULL,
+				filename text NOT NULL,
+				fileversion integer NOT NULL,
+				params jsonb NOT NULL DEFAULT '{}',
+				generatedby text NOT NULL DEFAULT '',
+				generatedat bigint NOT NULL,
+				UNIQUE (settingstype, filename)
+			)`,
+			`CREATE INDEX IF NOT EXISTS settingsgenerations_templateid_idx ON "FileTracker"."settingsgenerations" (templateid)`,
+		},
+	},
+	{
+		ID: "0008_vehicle_groups",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "LAFPackages"."groups" (
+				groupid integer PRIMARY KEY,
+				groupname text NOT NULL,
+				updatedby text NOT NULL DEFAULT '',
+				updatedat bigint NOT NULL DEFAULT 0
+			)`,
+			`INSERT INTO "LAFPackages"."groups" (groupid, groupname)
+				SELECT DISTINCT ON (groupid) groupid, groupname FROM "LAFPackages"."groupmodels" ORDER BY groupid, groupname
+				ON CONFLICT DO NOTHING`,
+			`CREATE INDEX IF NOT EXISTS groupmodels_groupid_idx ON "LAFPackages"."groupmodels" (groupid)`,
+		},
+	},
+	{
+		ID: "0009_vehicle_catalogue",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "LAFPackages"."vehicles" (
+				vehicleid serial PRIMARY KEY,
+				vehicletype text NOT NULL,
+				oem text NOT NULL,
+				model text NOT NULL,
+				variant text NOT NULL DEFAULT '',
+				year integer NOT NULL,
+				fueltype text NOT NULL DEFAULT '',
+				transmission text NOT NULL DEFAULT '',
+				updatedby text NOT NULL DEFAULT '',
+				updatedat bigint NOT NULL DEFAULT 0
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS vehicles_descriptor_idx ON "LAFPackages"."vehicles"
+				(LOWER(vehicletype), LOWER(oem), LOWER(model), LOWER(variant), year, LOWER(fueltype), LOWER(transmission))`,
+			`INSERT INTO "LAFPackages"."vehicles" (vehicletype, oem, model, variant, year, fueltype, transmission)
+				SELECT DISTINCT ON (LOWER(TRIM(vehicletype)), LOWER(TRIM(oem)), LOWER(TRIM(model)), LOWER(TRIM(variant)), year, LOWER(TRIM(fueltype)), LOWER(TRIM(transmission)))
+					TRIM(vehicletype), TRIM(oem), TRIM(model), TRIM(variant), year, TRIM(fueltype), TRIM(transmission)
+				FROM "LAFPackages"."groupmodels"
+				ORDER BY LOWER(TRIM(vehicletype)), LOWER(TRIM(oem)), LOWER(TRIM(model)), LOWER(TRIM(variant)), year, LOWER(TRIM(fueltype)), LOWER(TRIM(transmission)), modelid
+				ON CONFLICT DO NOTHING`,
+			`ALTER TABLE "LAFPackages"."groupmodels" ADD COLUMN IF NOT EXISTS vehicleid integer REFERENCES "LAFPackages"."vehicles" (vehicleid)`,
+			`UPDATE "LAFPackages"."groupmodels" g SET vehicleid = v.vehicleid
+				FROM "LAFPackages"."vehicles" v
+				WHERE LOWER(v.vehicletype) = LOWER(TRIM(g.vehicletype)) AND LOWER(v.oem) = LOWER(TRIM(g.oem))
+					AND LOWER(v.model) = LOWER(TRIM(g.model)) AND LOWER(v.variant) = LOWER(TRIM(g.variant)) AND v.year = g.year
+					AND LOWER(v.fueltype) = LOWER(TRIM(g.fueltype)) AND LOWER(v.transmission) = LOWER(TRIM(g.transmission))`,
+			`CREATE INDEX IF NOT EXISTS groupmodels_vehicleid_idx ON "LAFPackages"."groupmodels" (vehicleid)`,
+			`ALTER TABLE "Harness"."harness" ADD COLUMN IF NOT EXISTS vehicleid integer REFERENCES "LAFPackages"."vehicles" (vehicleid)`,
+			`CREATE INDEX IF NOT EXISTS harness_vehicleid_idx ON "Harness"."harness" (vehicleid)`,
+		},
+		Run: matchHarnessCatalogue,
+	},
+	{
+		ID: "0010_harness_ids",
+		Statements: []string{
+			`ALTER TABLE "Harness"."harness" ADD COLUMN IF NOT EXISTS harnessid bigint GENERATED ALWAYS AS IDENTITY`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS harness_harnessid_idx ON "Harness"."harness" (harnessid)`,
+		},
+		Run: uniqueHarnessKeys,
+	},
+	{
+		ID: "0011_stock_ledger",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "Harness"."stockmovements" (
+				id serial PRIMARY KEY,
+				harnessid bigint NOT NULL,
+				type text NOT NULL,
+				quantity integer NOT NULL,
+				balance integer NOT NULL,
+				reason text NOT NULL DEFAULT '',
+				reference text NOT NULL DEFAULT '',
+				counterpartid bigint,
+				createdby text NOT NULL DEFAULT '',
+				createdat bigint NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS stockmovements_harnessid_idx ON "Harness"."stockmovements" (harnessid, createdat, id)`,
+			`INSERT INTO "Harness"."stockmovements" (harnessid, type, quantity, balance, reason, createdby, createdat)
+				SELECT harnessid, 'adjustment', currentstock, currentstock, 'Opening balance', 'migration', COALESCE(NULLIF(updatedat, 0), EXTRACT(EPOCH FROM now())::bigint)
+				FROM "Harness"."harness" WHERE currentstock <> 0`,
+		},
+	},
+	{
+		ID: "0012_stock_alerts",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "Harness"."stockthresholds" (
+				id serial PRIMARY KEY,
+				phcode text NOT NULL,
+				ahcode text NOT NULL DEFAULT '',
+				minstock integer NOT NULL,
+				reorderquantity integer NOT NULL DEFAULT 0,
+				updatedby text NOT NULL DEFAULT '',
+				updatedat bigint NOT NULL DEFAULT 0,
+				UNIQUE (phcode, ahcode)
+			)`,
+			`CREATE TABLE IF NOT EXISTS "Harness"."stockalerts" (
+				id serial PRIMARY KEY,
+				thresholdid integer NOT NULL REFERENCES "Harness"."stockthresholds" (id) ON DELETE CASCADE,
+				phcode text NOT NULL,
+				ahcode text NOT NULL DEFAULT '',
+				stock integer NOT NULL,
+				minstock integer NOT NULL,
+				reorderquantity integer NOT NULL,
+				raisedat bigint NOT NULL,
+				notifiedat bigint,
+				resolvedat bigint
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS stockalerts_open_idx ON "Harness"."stockalerts" (thresholdid) WHERE resolvedat IS NULL`,
+		},
+	},
+	{
+		ID: "0013_harness_revisions",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "Harness"."revisions" (
+				id serial PRIMARY KEY,
+				phcode text NOT NULL,
+				rev integer NOT NULL,
+				iscurrent boolean NOT NULL DEFAULT false,
+				specification text NOT NULL DEFAULT '',
+				diagram text NOT NULL DEFAULT '',
+				harnessimage text NOT NULL DEFAULT '',
+				immotype text NOT NULL DEFAULT '',
+				immorelayvoltage text NOT NULL DEFAULT '',
+				can text NOT NULL DEFAULT '',
+				panic text NOT NULL DEFAULT '',
+				notes text NOT NULL DEFAULT '',
+				createdby text NOT NULL DEFAULT '',
+				createdat bigint NOT NULL,
+				UNIQUE (phcode, rev)
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS revisions_current_idx ON "Harness"."revisions" (phcode) WHERE iscurrent`,
+			`INSERT INTO "Harness"."revisions" (phcode, rev, iscurrent, specification, diagram, harnessimage, immotype, immorelayvoltage, can, panic, notes, createdby, createdat)
+				SELECT DISTINCT ON (phcode) phcode, GREATEST(rev, 1), true, COALESCE(specification, ''), COALESCE(diagram, ''), COALESCE(harnessimage, ''),
+					COALESCE(immotype, ''), COALESCE(immorelayvoltage, ''), COALESCE(can, ''), COALESCE(panic, ''),
+					'Snapshot of the existing design', 'migration', COALESCE(NULLIF(updatedat, 0), EXTRACT(EPOCH FROM now())::bigint)
+				FROM "Harness"."harness" ORDER BY phcode, rev DESC, updatedat DESC`,
+			`UPDATE "Harness"."harness" h SET rev = r.rev FROM "Harness"."revisions" r WHERE r.phcode = h.phcode AND r.iscurrent`,
+			`ALTER TABLE "Harness"."stockmovements" ADD COLUMN IF NOT EXISTS rev integer NOT NULL DEFAULT 0`,
+			`UPDATE "Harness"."stockmovements" m SET rev = h.rev FROM "Harness"."harness" h WHERE h.harnessid = m.harnessid`,
+			`CREATE INDEX IF NOT EXISTS stockmovements_rev_idx ON "Harness"."stockmovements" (harnessid, rev)`,
+		},
+	},
+	{
+		ID: "0014_harness_attachments",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS "Harness"."attachments" (
+				id serial PRIMARY KEY,
+				kind text NOT NULL,
+				filename text NOT NULL,
+				originalname text NOT NULL DEFAULT '',
+				size bigint NOT NULL DEFAULT 0,
+				contenttype text NOT NULL DEFAULT '',
+				hash text NOT NULL DEFAULT '',
+				uploadedby text NOT NULL DEFAULT '',
+				uploadedat bigint NOT NULL,
+				UNIQUE (kind, filename)
+			)`,
+		},
+	},
+	{
+		ID: "0015_nrfsettings_retire_and_pin",
+		Statements: []string{
+			`ALTER TABLE "NrfSettings"."parsedfiles" ADD COLUMN IF NOT EXISTS deletedat timestamptz`,
+			`ALTER TABLE "LAFPackages"."packages" ADD COLUMN IF NOT EXISTS coprocsettingsversion integer NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		ID: "0016_harness_revisions_archive",
+		Statements: []string{
+			`ALTER TABLE "Harness"."revisions" ADD COLUMN IF NOT EXISTS archivedat bigint`,
+		},
+	},
+}
+
+func RunMigrations(db *gorm.DB) error {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS public.schemamigrations (id text PRIMARY KEY, appliedat bigint NOT NULL)`).Error; err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	for _, m := range migrations {
+		var count int64
+		if err := db.Table("public.schemamigrations").Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", m.ID, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range m.Statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			if m.Run != nil {
+				if err := m.Run(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Exec(`INSERT INTO public.schemamigrations (id, appliedat) VALUES (?, ?)`, m.ID, time.Now().Unix()).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %v", m.ID, err)
+		}
+		log.Printf("Applied migration %s", m.ID)
+	}
+	return nil
+}
+
+func backfillCanSettingsHashes(tx *gorm.DB) error {
+	type row struct {
+		FileName string `gorm:"column:filename"`
+		Version  int    `gorm:"column:version"`
+		JSONData []byte `gorm:"column:jsondata"`
+	}
+
+	var rows []row
+	if err := tx.Table(`"FileTracker"."parsedfiles"`).Select("filename", "version", "jsondata").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		err := tx.Table(`"FileTracker"."parsedfiles"`).
+			Where("filename = ? AND version = ?", r.FileName, r.Version).
+			Update("contenthash", utils.ContentHash(r.JSONData)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillNrfSettingsHashes(tx *gorm.DB) error {
+	type row struct {
+		FileName  string `gorm:"column:filename"`
+		Version   int    `gorm:"column:version"`
+		JSONData  []byte `gorm:"column:jsondata"`
+		SleepCdns []byte `gorm:"column:sleepcdns"`
+	}
+
+	var rows []row
+	if err := tx.Table(`"NrfSettings"."parsedfiles"`).Select("filename", "version", "jsondata", "sleepcdns").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		err := tx.Table(`"NrfSettings"."parsedfiles"`).
+			Where("filename = ? AND version = ?", r.FileName, r.Version).
+			Update("contenthash", utils.NrfContentHash(r.JSONData, r.SleepCdns)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchHarnessVehicles links every harness without a catalogue vehicle to the
+// entry with the same fields, compared without regard to case or surrounding
+// space. A year of manufacture that is not a four digit year never matches.
+func MatchHarnessVehicles(db *gorm.DB) (int64, error) {
+	result := db.Exec(`UPDATE "Harness"."harness" h SET vehicleid = v.vehicleid
+		FROM "LAFPackages"."vehicles" v
+		WHERE h.vehicleid IS NULL AND TRIM(h.yearofmfg) ~ '^[0-9]{4}$' AND v.year = TRIM(h.yearofmfg)::integer
+			AND LOWER(v.vehicletype) = LOWER(TRIM(h.vehicletype)) AND LOWER(v.oem) = LOWER(TRIM(h.vehicleoem))
+			AND LOWER(v.model) = LOWER(TRIM(h.vehiclemodel)) AND LOWER(v.variant) = LOWER(TRIM(h.vehiclevariant))
+			AND LOWER(v.fueltype) = LOWER(TRIM(h.fueltype)) AND LOWER(v.transmission) = LOWER(TRIM(h.transmissiontype))`)
+	return result.RowsAffected, result.Error
+}
+
+// matchHarnessCatalogue matches existing harnesses to the catalogue and logs
+// those left unmatched for cleanup.
+func matchHarnessCatalogue(tx *gorm.DB) error {
+	matched, err := MatchHarnessVehicles(tx)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		SlNo           int    `gorm:"column:slno"`
+		PhCode         string `gorm:"column:phcode"`
+		VehicleOem     string `gorm:"column:vehicleoem"`
+		VehicleModel   string `gorm:"column:vehiclemodel"`
+		VehicleVariant string `gorm:"column:vehiclevariant"`
+		YearOfMfg      string `gorm:"column:yearofmfg"`
+	}
+	var unmatched []row
+	if err := tx.Table(`"Harness"."harness"`).Where("vehicleid IS NULL").Order("slno").Find(&unmatched).Error; err != nil {
+		return err
+	}
+	log.Printf("Matched %d harnesses to the vehicle catalogue, %d unmatched", matched, len(unmatched))
+	for _, r := range unmatched {
+		log.Printf("Unmatched harness %d: %s %s %s %s %s", r.SlNo, r.PhCode, r.VehicleOem, r.VehicleModel, r.VehicleVariant, r.YearOfMfg)
+	}
+	return nil
+}
+
+// uniqueHarnessKeys adds the uniqueness constraint on the harness natural
+// key. Duplicates must be cleaned up by hand first, so they fail the
+// migration with a list of the rows involved.
+func uniqueHarnessKeys(tx *gorm.DB) error {
+	type row struct {
+		PhCode         string `gorm:"column:phcode"`
+		VehicleOem     string `gorm:"column:vehicleoem"`
+		VehicleModel   string `gorm:"column:vehiclemodel"`
+		VehicleVariant string `gorm:"column:vehiclevariant"`
+		YearOfMfg      string `gorm:"column:yearofmfg"`
+		HarnessIds     string `gorm:"column:harnessids"`
+	}
+	var duplicates []row
+	err := tx.Raw(`SELECT phcode, vehicleoem, vehiclemodel, vehiclevariant, yearofmfg, string_agg(harnessid::text, ', ' ORDER BY harnessid) AS harnessids
+		FROM "Harness"."harness"
+		GROUP BY phcode, vehicleoem, vehiclemodel, vehiclevariant, yearofmfg
+		HAVING COUNT(*) > 1
+		ORDER BY phcode, vehicleoem, vehiclemodel, vehiclevariant, yearofmfg`).Scan(&duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		for _, r := range duplicates {
+			log.Printf("Duplicate harness %s %s %s %s %s: ids %s", r.PhCode, r.VehicleOem, r.VehicleModel, r.VehicleVariant, r.YearOfMfg, r.HarnessIds)
+		}
+		return fmt.Errorf("%d harness natural keys are duplicated", len(duplicates))
+	}
+	return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS harness_naturalkey_idx ON "Harness"."harness" (phcode, vehicleoem, vehiclemodel, vehiclevariant, yearofmfg)`).Error
+}
@@ -0,0 +1,215 @@
+package dbc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filepackage/model"
+)
+
+const header = `VERSION "1.0"
+
+NS_ :
+	CM_
+	BA_DEF_
+	BA_
+	VAL_
+	VAL_TABLE_
+
+BS_:
+
+BU_: ECU Gateway
+`
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestParseWriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		want   func(t *testing.T, doc model.CanDocument)
+		output []string
+	}{
+		{
+			name: "multiplexed signals",
+			src: header + `
+BO_ 256 Mux: 8 ECU
+ SG_ Selector M : 0|8@1+ (1,0) [0|255] "" Gateway
+ SG_ Speed m0 : 8|16@1+ (0.1,0) [0|6553.5] "km/h" Gateway
+ SG_ Temp m1 : 8|8@0- (1,-40) [-40|215] "degC" Gateway,ECU
+`,
+			want: func(t *testing.T, doc model.CanDocument) {
+				signals := doc.Messages[0].Signals
+				if !signals[0].Multiplexer || signals[0].MultiplexValue != nil {
+					t.Errorf("Selector = %+v, want the multiplexer", signals[0])
+				}
+				if !reflect.DeepEqual(signals[1].MultiplexValue, intPtr(0)) || !reflect.DeepEqual(signals[2].MultiplexValue, intPtr(1)) {
+					t.Errorf("multiplex values = %v, %v, want 0, 1", signals[1].MultiplexValue, signals[2].MultiplexValue)
+				}
+				temp := signals[2]
+				if temp.ByteOrder != model.ByteOrderBigEndian || !temp.Signed || temp.Offset != -40 || !reflect.DeepEqual(temp.Receivers, []string{"Gateway", "ECU"}) {
+					t.Errorf("Temp = %+v", temp)
+				}
+			},
+			output: []string{" SG_ Selector M : 0|8@1+", " SG_ Speed m0 : 8|16@1+", " SG_ Temp m1 : 8|8@0-"},
+		},
+		{
+			name: "value tables",
+			src: header + `
+VAL_TABLE_ GearTable 3 "Drive" 2 "Neutral" 1 "Reverse" 0 "Park";
+
+BO_ 512 Gearbox: 2 ECU
+ SG_ Gear : 0|4@1+ (1,0) [0|15] "" Gateway
+ SG_ Mode : 4|4@1+ (1,0) [0|15] "" Gateway
+
+VAL_ 512 Gear GearTable;
+VAL_ 512 Mode 1 "Sport" 0 "Eco";
+`,
+			want: func(t *testing.T, doc model.CanDocument) {
+				gear, mode := doc.Messages[0].Signals[0], doc.Messages[0].Signals[1]
+				if gear.ValueTable != "GearTable" || gear.Values[3] != "Drive" {
+					t.Errorf("Gear = %+v, want values from GearTable", gear)
+				}
+				if mode.ValueTable != "" || !reflect.DeepEqual(mode.Values, map[int64]string{0: "Eco", 1: "Sport"}) {
+					t.Errorf("Mode = %+v, want inline values", mode)
+				}
+			},
+			output: []string{
+				`VAL_TABLE_ GearTable 3 "Drive" 2 "Neutral" 1 "Reverse" 0 "Park";`,
+				"VAL_ 512 Gear GearTable;",
+				`VAL_ 512 Mode 1 "Sport" 0 "Eco";`,
+			},
+		},
+		{
+			name: "comments",
+			src: header + `
+BO_ 768 Status: 1 ECU
+ SG_ Ready : 0|1@1+ (1,0) [0|1] "" Gateway
+
+CM_ "Body network";
+CM_ BO_ 768 "Sent on change";
+CM_ SG_ 768 Ready "Set once the \"ECU\" is up";
+`,
+			want: func(t *testing.T, doc model.CanDocument) {
+				if doc.Comment != "Body network" || doc.Messages[0].Comment != "Sent on change" {
+					t.Errorf("comments = %q, %q", doc.Comment, doc.Messages[0].Comment)
+				}
+				if got := doc.Messages[0].Signals[0].Comment; got != `Set once the "ECU" is up` {
+					t.Errorf("signal comment = %q", got)
+				}
+			},
+			output: []string{`CM_ SG_ 768 Ready "Set once the \"ECU\" is up";`},
+		},
+		{
+			name: "attributes",
+			src: header + `
+BO_ 2566844672 Engine: 8 ECU
+ SG_ Rpm : 0|16@1+ (0.25,0) [0|16383.75] "rpm" Gateway
+
+BA_DEF_ "Baudrate" INT 0 1000000;
+BA_DEF_ BO_ "GenMsgCycleTime" INT 0 65535;
+BA_DEF_ "BusType" STRING ;
+BA_ "Baudrate" 500000;
+BA_ "BusType" "CAN";
+BA_ "GenMsgCycleTime" BO_ 2566844672 100;
+`,
+			want: func(t *testing.T, doc model.CanDocument) {
+				msg := doc.Messages[0]
+				if doc.BaudRate != 500000 || msg.CycleTime != 100 {
+					t.Errorf("baud rate %d, cycle time %d, want 500000, 100", doc.BaudRate, msg.CycleTime)
+				}
+				if !msg.Extended || msg.ID != 0x18FEF100 {
+					t.Errorf("id = %#x extended %v, want extended 0x18FEF100", msg.ID, msg.Extended)
+				}
+			},
+			output: []string{`BA_ "Baudrate" 500000;`, `BA_ "GenMsgCycleTime" BO_ 2566844672 100;`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := ParseString(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(first.Warnings) > 0 {
+				t.Errorf("warnings: %v", first.Warnings)
+			}
+			tt.want(t, first.Document)
+
+			var out bytes.Buffer
+			if err := Write(&out, first.Document); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			for _, line := range tt.output {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("output lacks %q:\n%s", line, out.String())
+				}
+			}
+
+			second, err := ParseString(out.String())
+			if err != nil {
+				t.Fatalf("Parse of written file: %v\n%s", err, out.String())
+			}
+			if !reflect.DeepEqual(second.Document, first.Document) {
+				t.Errorf("round trip changed the document:\n got %+v\nwant %+v", second.Document, first.Document)
+			}
+		})
+	}
+}
+
+// TestSettingsKeepValueTables runs a DBC file through the stored CAN
+// settings and back, as import and export do.
+func TestSettingsKeepValueTables(t *testing.T) {
+	src := header + `
+VAL_TABLE_ OnOff 1 "On" 0 "Off";
+
+BO_ 100 Lights: 1 ECU
+ SG_ Head : 0|1@1+ (1,0) [0|1] "" Gateway
+ SG_ Fog : 1|1@1+ (1,0) [0|1] "" Gateway
+
+VAL_ 100 Head OnOff;
+VAL_ 100 Fog 1 "Lit" 0 "Dark";
+`
+	parsed, err := ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := json.Marshal(model.CanSettingsFromDocument(parsed.Document, "ECU"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings model.CanSettingsData
+	if err := json.Unmarshal(stored, &settings); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := settings.CanDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"VAL_ 100 Head OnOff;", `VAL_ 100 Fog 1 "Lit" 0 "Dark";`} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	// Values edited away from the table are written inline.
+	doc.Messages[0].Signals[0].Values = map[int64]string{1: "High", 0: "Low"}
+	out.Reset()
+	if err := Write(&out, doc); err != nil {
+		t.Fatal(err)
+	}
+	if want := `VAL_ 100 Head 1 "High" 0 "Low";`; !strings.Contains(out.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,130 @@
+package dbc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return t.text
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	line, col := 1, 0
+	i := 0
+
+	advance := func() rune {
+		r := runes[i]
+		i++
+		if r == '\n' {
+			line++
+			col = 0
+		} else {
+			col++
+		}
+		return r
+	}
+
+	for i < len(runes) {
+		r := runes[i]
+		startLine, startCol := line, col
+
+		switch {
+		case r == '\n' || unicode.IsSpace(r):
+			advance()
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				advance()
+			}
+		case r == '"':
+			advance()
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				c := advance()
+				if c == '\\' && i < len(runes) && runes[i] == '"' {
+					sb.WriteRune(advance())
+					continue
+				}
+				if c == '"' {
+					closed = true
+					break
+				}
+				sb.WriteRune(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("line %d: unterminated string", startLine)
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), line: startLine, col: startCol})
+		case isNumberStart(runes, i):
+			start := i
+			advance()
+			for i < len(runes) && isNumberRune(runes[i], runes[i-1]) {
+				advance()
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[start:i]), line: startLine, col: startCol})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				advance()
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[start:i]), line: startLine, col: startCol})
+		default:
+			advance()
+			tokens = append(tokens, token{kind: tokPunct, text: string(r), line: startLine, col: startCol})
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, line: line, col: col})
+	return tokens, nil
+}
+
+func isNumberStart(runes []rune, i int) bool {
+	r := runes[i]
+	if unicode.IsDigit(r) {
+		return true
+	}
+	if (r == '-' || r == '+' || r == '.') && i+1 < len(runes) {
+		next := runes[i+1]
+		if unicode.IsDigit(next) {
+			return true
+		}
+		if next == '.' && r != '.' && i+2 < len(runes) && unicode.IsDigit(runes[i+2]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumberRune(r, prev rune) bool {
+	if unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' {
+		return true
+	}
+	return (r == '-' || r == '+') && (prev == 'e' || prev == 'E')
+}
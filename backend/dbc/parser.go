@@ -0,0 +1,568 @@
+// Package dbc converts between Vector DBC files and the CAN settings document
+// stored in FileTracker.parsedfiles.
+package dbc
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"filepackage/model"
+)
+
+const extendedIdFlag = 0x80000000
+
+// Result is a parsed DBC file along with anything that was skipped on the way.
+type Result struct {
+	Document model.CanDocument `json:"document"`
+	Warnings []string          `json:"warnings"`
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	doc      model.CanDocument
+	warnings []string
+}
+
+// Parse reads a DBC file and converts it into a CAN settings document.
+func Parse(r io.Reader) (*Result, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(string(src))
+}
+
+func ParseString(src string) (*Result, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, doc: model.CanDocument{Messages: []model.CanMessage{}}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return &Result{Document: p.doc, Warnings: p.warnings}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) warnf(t token, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf("line %d: %s", t.line, fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) expectPunct(text string) error {
+	t := p.next()
+	if t.kind != tokPunct || t.text != text {
+		return p.errorf(t, "expected %q, got %s", text, t)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (token, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return t, p.errorf(t, "expected identifier, got %s", t)
+	}
+	return t, nil
+}
+
+func (p *parser) expectString() (string, error) {
+	t := p.next()
+	if t.kind != tokString {
+		return "", p.errorf(t, "expected string, got %s", t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) expectInt() (int64, error) {
+	t := p.next()
+	if t.kind != tokNumber {
+		return 0, p.errorf(t, "expected integer, got %s", t)
+	}
+	v, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid integer %s", t.text)
+	}
+	return v, nil
+}
+
+func (p *parser) expectFloat() (float64, error) {
+	t := p.next()
+	if t.kind != tokNumber {
+		return 0, p.errorf(t, "expected number, got %s", t)
+	}
+	v, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid number %s", t.text)
+	}
+	return v, nil
+}
+
+func (p *parser) skipStatement() {
+	for {
+		t := p.next()
+		if t.kind == tokEOF || (t.kind == tokPunct && t.text == ";") {
+			return
+		}
+	}
+}
+
+func (p *parser) skipLine(line int) {
+	for p.peek().kind != tokEOF && p.peek().line == line {
+		p.next()
+	}
+}
+
+func (p *parser) parse() error {
+	for {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return nil
+		}
+		if t.kind != tokIdent {
+			return p.errorf(t, "unexpected %s", t)
+		}
+
+		var err error
+		switch t.text {
+		case "VERSION":
+			p.next()
+			p.doc.Version, err = p.expectString()
+		case "NS_":
+			p.parseNewSymbols()
+		case "BS_":
+			p.next()
+			err = p.expectPunct(":")
+			p.skipLine(t.line)
+		case "BU_":
+			err = p.parseNodes()
+		case "BO_":
+			err = p.parseMessage()
+		case "CM_":
+			err = p.parseComment()
+		case "VAL_TABLE_":
+			err = p.parseValueTable()
+		case "VAL_":
+			err = p.parseValueDescriptions()
+		case "BA_":
+			err = p.parseAttribute()
+		case "BA_DEF_", "BA_DEF_DEF_", "BO_TX_BU_", "SIG_VALTYPE_", "SIG_GROUP_", "EV_", "ENVVAR_DATA_", "SGTYPE_", "SG_MUL_VAL_", "BA_DEF_REL_", "BA_REL_", "BA_DEF_DEF_REL_", "BU_SG_REL_", "BU_EV_REL_", "BU_BO_REL_", "SIG_TYPE_REF_", "VAL_TYPE_":
+			p.skipStatement()
+		default:
+			p.warnf(t, "skipping unsupported statement %s", t.text)
+			p.skipStatement()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseNewSymbols() {
+	ns := p.next()
+	if p.peek().kind == tokPunct && p.peek().text == ":" {
+		p.next()
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokEOF || (t.line != ns.line && t.col == 0) {
+			return
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseNodes() error {
+	bu := p.next()
+	if err := p.expectPunct(":"); err != nil {
+		return err
+	}
+	for p.peek().kind == tokIdent && p.peek().line == bu.line {
+		p.doc.Nodes = append(p.doc.Nodes, p.next().text)
+	}
+	return nil
+}
+
+func (p *parser) parseMessage() error {
+	p.next()
+	rawId, err := p.expectInt()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return err
+	}
+	dlc, err := p.expectInt()
+	if err != nil {
+		return err
+	}
+	transmitter, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+
+	msg := model.CanMessage{
+		ID:          uint32(rawId) &^ extendedIdFlag,
+		Extended:    uint32(rawId)&extendedIdFlag != 0,
+		Name:        name.text,
+		DLC:         int(dlc),
+		Transmitter: transmitter.text,
+		Signals:     []model.CanSignal{},
+	}
+	if msg.Transmitter == "Vector__XXX" {
+		msg.Transmitter = ""
+	}
+
+	for p.peek().kind == tokIdent && p.peek().text == "SG_" {
+		sig, err := p.parseSignal()
+		if err != nil {
+			return err
+		}
+		msg.Signals = append(msg.Signals, sig)
+	}
+
+	p.doc.Messages = append(p.doc.Messages, msg)
+	return nil
+}
+
+func (p *parser) parseSignal() (model.CanSignal, error) {
+	var sig model.CanSignal
+	sg := p.next()
+
+	name, err := p.expectIdent()
+	if err != nil {
+		return sig, err
+	}
+	sig.Name = name.text
+
+	if p.peek().kind == tokIdent {
+		if err := applyMultiplexIndicator(&sig, p.next()); err != nil {
+			return sig, p.errorf(sg, "signal %s: %v", sig.Name, err)
+		}
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return sig, err
+	}
+
+	start, err := p.expectInt()
+	if err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct("|"); err != nil {
+		return sig, err
+	}
+	length, err := p.expectInt()
+	if err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct("@"); err != nil {
+		return sig, err
+	}
+	order, err := p.expectInt()
+	if err != nil {
+		return sig, err
+	}
+	sign := p.next()
+	if sign.kind != tokPunct || (sign.text != "+" && sign.text != "-") {
+		return sig, p.errorf(sign, "expected value type + or -, got %s", sign)
+	}
+	sig.StartBit = int(start)
+	sig.Length = int(length)
+	sig.Signed = sign.text == "-"
+	switch order {
+	case 0:
+		sig.ByteOrder = model.ByteOrderBigEndian
+	case 1:
+		sig.ByteOrder = model.ByteOrderLittleEndian
+	default:
+		return sig, p.errorf(sg, "signal %s: invalid byte order %d", sig.Name, order)
+	}
+
+	if err := p.expectPunct("("); err != nil {
+		return sig, err
+	}
+	if sig.Factor, err = p.expectFloat(); err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct(","); err != nil {
+		return sig, err
+	}
+	if sig.Offset, err = p.expectFloat(); err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return sig, err
+	}
+
+	if err := p.expectPunct("["); err != nil {
+		return sig, err
+	}
+	if sig.Min, err = p.expectFloat(); err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct("|"); err != nil {
+		return sig, err
+	}
+	if sig.Max, err = p.expectFloat(); err != nil {
+		return sig, err
+	}
+	if err := p.expectPunct("]"); err != nil {
+		return sig, err
+	}
+
+	if sig.Unit, err = p.expectString(); err != nil {
+		return sig, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokIdent || t.text == "SG_" || t.line != sg.line {
+			break
+		}
+		p.next()
+		if t.text != "Vector__XXX" {
+			sig.Receivers = append(sig.Receivers, t.text)
+		}
+		if p.peek().kind == tokPunct && p.peek().text == "," {
+			p.next()
+		}
+	}
+
+	return sig, nil
+}
+
+func applyMultiplexIndicator(sig *model.CanSignal, t token) error {
+	text := t.text
+	if text == "M" {
+		sig.Multiplexer = true
+		return nil
+	}
+	if !strings.HasPrefix(text, "m") {
+		return fmt.Errorf("invalid multiplex indicator %s", text)
+	}
+	text = strings.TrimPrefix(text, "m")
+	if strings.HasSuffix(text, "M") {
+		sig.Multiplexer = true
+		text = strings.TrimSuffix(text, "M")
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return fmt.Errorf("invalid multiplex indicator %s", t.text)
+	}
+	sig.MultiplexValue = &v
+	return nil
+}
+
+func (p *parser) findMessage(rawId int64) *model.CanMessage {
+	id := uint32(rawId) &^ extendedIdFlag
+	extended := uint32(rawId)&extendedIdFlag != 0
+	for i := range p.doc.Messages {
+		if p.doc.Messages[i].ID == id && p.doc.Messages[i].Extended == extended {
+			return &p.doc.Messages[i]
+		}
+	}
+	return nil
+}
+
+func (p *parser) findSignal(rawId int64, name string) *model.CanSignal {
+	msg := p.findMessage(rawId)
+	if msg == nil {
+		return nil
+	}
+	for i := range msg.Signals {
+		if msg.Signals[i].Name == name {
+			return &msg.Signals[i]
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseComment() error {
+	cm := p.next()
+	t := p.peek()
+
+	if t.kind == tokString {
+		p.doc.Comment = p.next().text
+		return p.expectPunct(";")
+	}
+
+	kind, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	switch kind.text {
+	case "BO_":
+		id, err := p.expectInt()
+		if err != nil {
+			return err
+		}
+		text, err := p.expectString()
+		if err != nil {
+			return err
+		}
+		if msg := p.findMessage(id); msg != nil {
+			msg.Comment = text
+		} else {
+			p.warnf(cm, "comment for unknown message %d", id)
+		}
+	case "SG_":
+		id, err := p.expectInt()
+		if err != nil {
+			return err
+		}
+		name, err := p.expectIdent()
+		if err != nil {
+			return err
+		}
+		text, err := p.expectString()
+		if err != nil {
+			return err
+		}
+		if sig := p.findSignal(id, name.text); sig != nil {
+			sig.Comment = text
+		} else {
+			p.warnf(cm, "comment for unknown signal %s in message %d", name.text, id)
+		}
+	default:
+		p.skipStatement()
+		return nil
+	}
+	return p.expectPunct(";")
+}
+
+func (p *parser) parseValueMap() (map[int64]string, error) {
+	values := map[int64]string{}
+	for p.peek().kind == tokNumber {
+		v, err := p.expectFloat()
+		if err != nil {
+			return nil, err
+		}
+		desc, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		values[int64(v)] = desc
+	}
+	return values, p.expectPunct(";")
+}
+
+func (p *parser) parseValueTable() error {
+	p.next()
+	name, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	values, err := p.parseValueMap()
+	if err != nil {
+		return err
+	}
+	if p.doc.ValueTables == nil {
+		p.doc.ValueTables = map[string]map[int64]string{}
+	}
+	p.doc.ValueTables[name.text] = values
+	return nil
+}
+
+func (p *parser) parseValueDescriptions() error {
+	val := p.next()
+	if p.peek().kind != tokNumber {
+		p.skipStatement()
+		return nil
+	}
+	id, err := p.expectInt()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+
+	if p.peek().kind == tokIdent {
+		table := p.next().text
+		if err := p.expectPunct(";"); err != nil {
+			return err
+		}
+		if sig := p.findSignal(id, name.text); sig != nil {
+			sig.ValueTable = table
+			sig.Values = p.doc.ValueTables[table]
+		} else {
+			p.warnf(val, "value table for unknown signal %s in message %d", name.text, id)
+		}
+		return nil
+	}
+
+	values, err := p.parseValueMap()
+	if err != nil {
+		return err
+	}
+	if sig := p.findSignal(id, name.text); sig != nil {
+		sig.Values = values
+	} else {
+		p.warnf(val, "value descriptions for unknown signal %s in message %d", name.text, id)
+	}
+	return nil
+}
+
+func (p *parser) parseAttribute() error {
+	ba := p.next()
+	name, err := p.expectString()
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "Baudrate":
+		if p.peek().kind == tokNumber {
+			v, err := p.expectFloat()
+			if err != nil {
+				return err
+			}
+			p.doc.BaudRate = int(v)
+			return p.expectPunct(";")
+		}
+	case "GenMsgCycleTime":
+		if p.peek().kind == tokIdent && p.peek().text == "BO_" {
+			p.next()
+			id, err := p.expectInt()
+			if err != nil {
+				return err
+			}
+			v, err := p.expectFloat()
+			if err != nil {
+				return err
+			}
+			if msg := p.findMessage(id); msg != nil {
+				msg.CycleTime = int(v)
+			} else {
+				p.warnf(ba, "cycle time for unknown message %d", id)
+			}
+			return p.expectPunct(";")
+		}
+	}
+
+	p.skipStatement()
+	return nil
+}
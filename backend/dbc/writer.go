@@ -0,0 +1,173 @@
+package dbc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+
+	"filepackage/model"
+)
+
+const noNode = "Vector__XXX"
+
+// Write renders a CAN settings document as a DBC file.
+func Write(w io.Writer, doc model.CanDocument) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "VERSION %s\n\n", quote(doc.Version))
+	bw.WriteString("NS_ :\n\tCM_\n\tBA_DEF_\n\tBA_\n\tVAL_\n\tBA_DEF_DEF_\n\tVAL_TABLE_\n\n")
+	bw.WriteString("BS_:\n\n")
+	fmt.Fprintf(bw, "BU_: %s\n\n", strings.Join(doc.Nodes, " "))
+
+	for _, name := range sortedKeys(doc.ValueTables) {
+		fmt.Fprintf(bw, "VAL_TABLE_ %s%s;\n", name, formatValues(doc.ValueTables[name]))
+	}
+	if len(doc.ValueTables) > 0 {
+		bw.WriteString("\n")
+	}
+
+	for _, msg := range doc.Messages {
+		fmt.Fprintf(bw, "BO_ %d %s: %d %s\n", rawId(msg), msg.Name, msg.DLC, nodeOrDefault(msg.Transmitter))
+		for _, sig := range msg.Signals {
+			receivers := noNode
+			if len(sig.Receivers) > 0 {
+				receivers = strings.Join(sig.Receivers, ",")
+			}
+			fmt.Fprintf(bw, " SG_ %s %s: %d|%d@%s%s (%s,%s) [%s|%s] %s %s\n",
+				sig.Name, multiplexIndicator(sig), sig.StartBit, sig.Length, byteOrderFlag(sig), signFlag(sig),
+				formatFloat(sig.Factor), formatFloat(sig.Offset), formatFloat(sig.Min), formatFloat(sig.Max),
+				quote(sig.Unit), receivers)
+		}
+		bw.WriteString("\n")
+	}
+
+	if doc.Comment != "" {
+		fmt.Fprintf(bw, "CM_ %s;\n", quote(doc.Comment))
+	}
+	for _, msg := range doc.Messages {
+		if msg.Comment != "" {
+			fmt.Fprintf(bw, "CM_ BO_ %d %s;\n", rawId(msg), quote(msg.Comment))
+		}
+		for _, sig := range msg.Signals {
+			if sig.Comment != "" {
+				fmt.Fprintf(bw, "CM_ SG_ %d %s %s;\n", rawId(msg), sig.Name, quote(sig.Comment))
+			}
+		}
+	}
+
+	hasCycleTime := false
+	for _, msg := range doc.Messages {
+		if msg.CycleTime > 0 {
+			hasCycleTime = true
+			break
+		}
+	}
+	if doc.BaudRate > 0 {
+		bw.WriteString("BA_DEF_ \"Baudrate\" INT 0 1000000;\n")
+	}
+	if hasCycleTime {
+		bw.WriteString("BA_DEF_ BO_ \"GenMsgCycleTime\" INT 0 65535;\n")
+	}
+	if doc.BaudRate > 0 {
+		fmt.Fprintf(bw, "BA_ \"Baudrate\" %d;\n", doc.BaudRate)
+	}
+	for _, msg := range doc.Messages {
+		if msg.CycleTime > 0 {
+			fmt.Fprintf(bw, "BA_ \"GenMsgCycleTime\" BO_ %d %d;\n", rawId(msg), msg.CycleTime)
+		}
+	}
+
+	// A signal keeps the reference to its value table as long as its values
+	// are still those of the table.
+	for _, msg := range doc.Messages {
+		for _, sig := range msg.Signals {
+			if table, ok := doc.ValueTables[sig.ValueTable]; ok && sig.ValueTable != "" && (len(sig.Values) == 0 || maps.Equal(sig.Values, table)) {
+				fmt.Fprintf(bw, "VAL_ %d %s %s;\n", rawId(msg), sig.Name, sig.ValueTable)
+				continue
+			}
+			if len(sig.Values) == 0 {
+				continue
+			}
+			fmt.Fprintf(bw, "VAL_ %d %s%s;\n", rawId(msg), sig.Name, formatValues(sig.Values))
+		}
+	}
+
+	return bw.Flush()
+}
+
+func rawId(msg model.CanMessage) uint32 {
+	if msg.Extended {
+		return msg.ID | extendedIdFlag
+	}
+	return msg.ID
+}
+
+func nodeOrDefault(node string) string {
+	if node == "" {
+		return noNode
+	}
+	return node
+}
+
+func multiplexIndicator(sig model.CanSignal) string {
+	var sb strings.Builder
+	if sig.MultiplexValue != nil {
+		sb.WriteString("m" + strconv.Itoa(*sig.MultiplexValue))
+	}
+	if sig.Multiplexer {
+		sb.WriteString("M")
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return sb.String() + " "
+}
+
+func byteOrderFlag(sig model.CanSignal) string {
+	if sig.ByteOrder == model.ByteOrderBigEndian {
+		return "0"
+	}
+	return "1"
+}
+
+func signFlag(sig model.CanSignal) string {
+	if sig.Signed {
+		return "-"
+	}
+	return "+"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func formatValues(values map[int64]string) string {
+	keys := make([]int64, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] > keys[j] })
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, " %d %s", k, quote(values[k]))
+	}
+	return sb.String()
+}
+
+func quote(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\\\"") + "\""
+}
+
+func sortedKeys(m map[string]map[int64]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
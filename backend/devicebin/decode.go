@@ -0,0 +1,196 @@
+package devicebin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+)
+
+// Image is a decoded device image.
+type Image struct {
+	Kind     string                 `json:"kind"`
+	Layout   uint16                 `json:"layout"`
+	Document map[string]interface{} `json:"document"`
+}
+
+// Decode verifies the header and CRC of data and unpacks its payload with
+// the layout version recorded in the header.
+func Decode(data []byte) (*Image, error) {
+	if len(data) < headerSize+4 {
+		return nil, errors.New("image too short")
+	}
+
+	kind := ""
+	for k, magic := range magics {
+		if string(data[:4]) == magic {
+			kind = k
+		}
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("unknown image magic %q", data[:4])
+	}
+
+	version := binary.LittleEndian.Uint16(data[4:])
+	flags := binary.LittleEndian.Uint16(data[6:])
+	length := binary.LittleEndian.Uint32(data[8:])
+	if uint64(len(data)) != uint64(headerSize)+uint64(length)+4 {
+		return nil, fmt.Errorf("image length %d does not match payload length %d", len(data), length)
+	}
+	body := data[:len(data)-4]
+	if want, got := binary.LittleEndian.Uint32(data[len(data)-4:]), crc32.ChecksumIEEE(body); want != got {
+		return nil, fmt.Errorf("crc mismatch: image has %08x, computed %08x", want, got)
+	}
+
+	layout, err := Lookup(kind, version)
+	if err != nil {
+		return nil, err
+	}
+	if (flags&flagBigEndian != 0) != (layout.ByteOrder == BigEndian) {
+		return nil, fmt.Errorf("image byte order does not match %s layout %d", kind, version)
+	}
+
+	d := &decoder{r: bytes.NewReader(body[headerSize:]), order: byteOrder(layout.ByteOrder)}
+	doc, err := d.fields("$", layout.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if d.r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing payload bytes", d.r.Len())
+	}
+	return &Image{Kind: kind, Layout: version, Document: doc}, nil
+}
+
+var scalars = map[FieldType]func() interface{}{
+	U8:  func() interface{} { return new(uint8) },
+	U16: func() interface{} { return new(uint16) },
+	U32: func() interface{} { return new(uint32) },
+	U64: func() interface{} { return new(uint64) },
+	I8:  func() interface{} { return new(int8) },
+	I16: func() interface{} { return new(int16) },
+	I32: func() interface{} { return new(int32) },
+	I64: func() interface{} { return new(int64) },
+	F32: func() interface{} { return new(float32) },
+	F64: func() interface{} { return new(float64) },
+}
+
+type decoder struct {
+	r     *bytes.Reader
+	order binary.ByteOrder
+}
+
+func (d *decoder) get(path string, v interface{}) error {
+	if err := binary.Read(d.r, d.order, v); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%s: payload truncated", path)
+		}
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func (d *decoder) fields(path string, fields []Field) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if f.Optional {
+			var present uint8
+			if err := d.get(path+"."+f.Name, &present); err != nil {
+				return nil, err
+			}
+			if present == 0 {
+				continue
+			}
+		}
+		v, err := d.value(path+"."+f.Name, f)
+		if err != nil {
+			return nil, err
+		}
+		obj[f.Name] = v
+	}
+	return obj, nil
+}
+
+func (d *decoder) count(path string) (int, error) {
+	var n uint16
+	err := d.get(path, &n)
+	return int(n), err
+}
+
+func (d *decoder) value(path string, f Field) (interface{}, error) {
+	switch f.Type {
+	case U8, U16, U32, U64, I8, I16, I32, I64, F32, F64:
+		v := scalars[f.Type]()
+		if err := d.get(path, v); err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(v).Elem().Interface(), nil
+	case Bool:
+		var v uint8
+		if err := d.get(path, &v); err != nil {
+			return nil, err
+		}
+		return v != 0, nil
+	case String:
+		n := f.Size
+		if n == 0 {
+			var err error
+			if n, err = d.count(path); err != nil {
+				return nil, err
+			}
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			return nil, fmt.Errorf("%s: payload truncated", path)
+		}
+		if f.Size > 0 {
+			b = bytes.TrimRight(b, "\x00")
+		}
+		return string(b), nil
+	case Enum:
+		var v uint8
+		if err := d.get(path, &v); err != nil {
+			return nil, err
+		}
+		if int(v) >= len(f.Values) {
+			return nil, fmt.Errorf("%s: enum index %d out of range", path, v)
+		}
+		return f.Values[v], nil
+	case Array:
+		n, err := d.count(path)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := d.value(fmt.Sprintf("%s[%d]", path, i), *f.Elem)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case Map:
+		n, err := d.count(path)
+		if err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := d.value(path, Field{Type: String})
+			if err != nil {
+				return nil, err
+			}
+			key := k.(string)
+			if obj[key], err = d.value(path+"."+key, *f.Elem); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case Struct:
+		return d.fields(path, f.Fields)
+	}
+	return nil, fmt.Errorf("%s: unsupported field type %q", path, f.Type)
+}
@@ -0,0 +1,180 @@
+package devicebin
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encodeTestdata(t *testing.T, kind string) (Layout, []byte) {
+	t.Helper()
+	layout, err := Lookup(kind, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := Encode(layout, readTestdata(t, kind+".json"))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return layout, image
+}
+
+func TestEncodeGolden(t *testing.T) {
+	for _, kind := range []string{KindCan, KindNrf} {
+		t.Run(kind, func(t *testing.T) {
+			_, image := encodeTestdata(t, kind)
+			golden := filepath.Join("testdata", kind+".golden")
+			if *update {
+				if err := os.WriteFile(golden, image, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(image, want) {
+				t.Errorf("image differs from %s (run go test -update after an intended layout change)", golden)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, kind := range []string{KindCan, KindNrf} {
+		t.Run(kind, func(t *testing.T) {
+			layout, image := encodeTestdata(t, kind)
+			decoded, err := Decode(image)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if decoded.Kind != kind || decoded.Layout != layout.Version {
+				t.Fatalf("decoded %s layout %d, want %s layout %d", decoded.Kind, decoded.Layout, kind, layout.Version)
+			}
+
+			doc, err := json.Marshal(decoded.Document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := Encode(layout, doc)
+			if err != nil {
+				t.Fatalf("Encode of decoded document: %v", err)
+			}
+			if !bytes.Equal(again, image) {
+				t.Error("re-encoding the decoded document changed the image")
+			}
+			for _, name := range layout.Ignored {
+				if _, ok := decoded.Document[name]; ok {
+					t.Errorf("ignored member %s was decoded", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTripValues(t *testing.T) {
+	_, image := encodeTestdata(t, KindCan)
+	decoded, err := Decode(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canrx := decoded.Document["canrx"].(map[string]interface{})
+	filterId := canrx["filterids"].([]interface{})[0].(map[string]interface{})
+	if got := filterId["filterid"]; got != uint32(0x100) {
+		t.Errorf("filterids[0].filterid = %v, want 0x100", got)
+	}
+	rxParam := canrx["rxparams"].([]interface{})[0].(map[string]interface{})
+	signals := rxParam["signals"].([]interface{})
+	rpm := signals[0].(map[string]interface{})
+	if got := rpm["factor"]; got != 0.25 {
+		t.Errorf("Rpm factor = %v, want 0.25", got)
+	}
+	if _, ok := rpm["multiplexvalue"]; ok {
+		t.Error("Rpm has a multiplex value")
+	}
+	temp := signals[1].(map[string]interface{})
+	if got := temp["multiplexvalue"]; got != uint16(3) {
+		t.Errorf("Temp multiplexvalue = %v, want 3", got)
+	}
+	if got := temp["byteorder"]; got != BigEndian {
+		t.Errorf("Temp byteorder = %v, want %s", got, BigEndian)
+	}
+	global := decoded.Document["globalconfig"].(map[string]interface{})
+	if want := map[string]interface{}{"baudrate": uint32(500000), "listenonly": uint32(0)}; !reflect.DeepEqual(global, want) {
+		t.Errorf("globalconfig = %v, want %v", global, want)
+	}
+}
+
+func TestEncodeRejectsUnmappedMembers(t *testing.T) {
+	tests := []struct {
+		kind, doc, err string
+	}{
+		{KindCan, `{"messages": []}`, "$: layout has no field for messages"},
+		{KindCan, `{"canrx": {"filtermasks": [], "rxfilters": []}}`, "$.canrx: layout has no field for rxfilters"},
+		{KindCan, `{"cantx": {"txparams": [{"paramID": 1, "period": 10}]}}`, "$.cantx.txparams[0]: layout has no field for period"},
+		{KindNrf, `{"sleepsettings": {}, "coprocdsl": {}}`, "$: layout has no field for coprocdsl"},
+	}
+	for _, tt := range tests {
+		layout, err := Lookup(tt.kind, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Encode(layout, []byte(tt.doc))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Encode(%s) error = %v, want %q", tt.doc, err, tt.err)
+		}
+	}
+}
+
+func TestEncodeRejectsInvalidValues(t *testing.T) {
+	layout, err := Lookup(KindCan, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc, err string
+	}{
+		{`{"canrx": {"filtermasks": ["mask"]}}`, `$.canrx.filtermasks[0]: "mask" is not an integer`},
+		{`{"canrx": {"filterids": [{"filterref": 70000}]}}`, "$.canrx.filterids[0].filterref: 70000 out of range for u16"},
+		{`{"globalconfig": {"baudrate": 0.5}}`, "$.globalconfig.baudrate: 0.5 is not an integer"},
+	}
+	for _, tt := range tests {
+		_, err := Encode(layout, []byte(tt.doc))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Encode(%s) error = %v, want %q", tt.doc, err, tt.err)
+		}
+	}
+}
+
+func TestDecodeRejectsDamagedImages(t *testing.T) {
+	_, image := encodeTestdata(t, KindNrf)
+
+	corrupt := append([]byte(nil), image...)
+	corrupt[headerSize] ^= 0xFF
+	if _, err := Decode(corrupt); err == nil || !strings.Contains(err.Error(), "crc mismatch") {
+		t.Errorf("Decode of corrupted image: %v, want crc mismatch", err)
+	}
+	if _, err := Decode(image[:len(image)-1]); err == nil || !strings.Contains(err.Error(), "does not match payload length") {
+		t.Errorf("Decode of truncated image: %v, want length mismatch", err)
+	}
+	unknown := append([]byte("XXXX"), image[4:]...)
+	if _, err := Decode(unknown); err == nil || !strings.Contains(err.Error(), "unknown image magic") {
+		t.Errorf("Decode of unknown magic: %v, want unknown magic", err)
+	}
+}
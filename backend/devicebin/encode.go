@@ -0,0 +1,309 @@
+package devicebin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const headerSize = 12
+
+const flagBigEndian = 1
+
+// Encode packs doc, a JSON document, into a device image using layout.
+// Members missing from doc are written as zero values; members the layout
+// has no field for are an error.
+func Encode(layout Layout, doc []byte) ([]byte, error) {
+	magic, ok := magics[layout.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown settings kind %q", layout.Kind)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var root interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid JSON document: %v", err)
+	}
+	obj, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document must be an object")
+	}
+
+	e := &encoder{order: byteOrder(layout.ByteOrder)}
+	if err := e.fields("$", layout.Fields, obj, layout.Ignored...); err != nil {
+		return nil, err
+	}
+	payload := e.buf.Bytes()
+	if uint64(len(payload)) > math.MaxUint32 {
+		return nil, fmt.Errorf("payload too large")
+	}
+
+	var flags uint16
+	if layout.ByteOrder == BigEndian {
+		flags |= flagBigEndian
+	}
+	out := make([]byte, headerSize, headerSize+len(payload)+4)
+	copy(out, magic)
+	binary.LittleEndian.PutUint16(out[4:], layout.Version)
+	binary.LittleEndian.PutUint16(out[6:], flags)
+	binary.LittleEndian.PutUint32(out[8:], uint32(len(payload)))
+	out = append(out, payload...)
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out)), nil
+}
+
+func byteOrder(name string) binary.ByteOrder {
+	if name == BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+type encoder struct {
+	buf   bytes.Buffer
+	order binary.ByteOrder
+}
+
+func (e *encoder) put(v interface{}) {
+	binary.Write(&e.buf, e.order, v)
+}
+
+// unmapped reports the members of obj that no field packs and that are not
+// ignored.
+func unmapped(path string, fields []Field, obj map[string]interface{}, ignored []string) error {
+	known := make(map[string]bool, len(fields)+len(ignored))
+	for _, f := range fields {
+		known[f.Name] = true
+	}
+	for _, name := range ignored {
+		known[name] = true
+	}
+	var names []string
+	for name := range obj {
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%s: layout has no field for %s", path, strings.Join(names, ", "))
+}
+
+func (e *encoder) fields(path string, fields []Field, obj map[string]interface{}, ignored ...string) error {
+	if err := unmapped(path, fields, obj, ignored); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		value := obj[f.Name]
+		if f.Optional {
+			if value == nil {
+				e.put(uint8(0))
+				continue
+			}
+			e.put(uint8(1))
+		}
+		if err := e.value(path+"."+f.Name, f, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) count(path string, n int) error {
+	if n > math.MaxUint16 {
+		return fmt.Errorf("%s: too many elements (%d)", path, n)
+	}
+	e.put(uint16(n))
+	return nil
+}
+
+func (e *encoder) value(path string, f Field, value interface{}) error {
+	switch f.Type {
+	case U8, U16, U32, U64, I8, I16, I32, I64:
+		return e.integer(path, f.Type, value)
+	case F32, F64:
+		n, err := toFloat(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if f.Type == F32 {
+			e.put(float32(n))
+		} else {
+			e.put(n)
+		}
+	case Bool:
+		b, ok := value.(bool)
+		if value != nil && !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+		if b {
+			e.put(uint8(1))
+		} else {
+			e.put(uint8(0))
+		}
+	case String:
+		s, ok := value.(string)
+		if value != nil && !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if f.Size > 0 {
+			if len(s) > f.Size {
+				return fmt.Errorf("%s: string longer than %d bytes", path, f.Size)
+			}
+			e.buf.WriteString(s)
+			e.buf.Write(make([]byte, f.Size-len(s)))
+			return nil
+		}
+		if err := e.count(path, len(s)); err != nil {
+			return err
+		}
+		e.buf.WriteString(s)
+	case Enum:
+		s, _ := value.(string)
+		for i, v := range f.Values {
+			if v == s {
+				e.put(uint8(i))
+				return nil
+			}
+		}
+		if value == nil {
+			e.put(uint8(0))
+			return nil
+		}
+		return fmt.Errorf("%s: %q is not one of %v", path, s, f.Values)
+	case Array:
+		items, ok := value.([]interface{})
+		if value != nil && !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if err := e.count(path, len(items)); err != nil {
+			return err
+		}
+		for i, item := range items {
+			if err := e.value(path+"["+strconv.Itoa(i)+"]", *f.Elem, item); err != nil {
+				return err
+			}
+		}
+	case Map:
+		obj, ok := value.(map[string]interface{})
+		if value != nil && !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if err := e.count(path, len(keys)); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := e.value(path+"."+k, Field{Type: String}, k); err != nil {
+				return err
+			}
+			if err := e.value(path+"."+k, *f.Elem, obj[k]); err != nil {
+				return err
+			}
+		}
+	case Struct:
+		obj, ok := value.(map[string]interface{})
+		if value != nil && !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		return e.fields(path, f.Fields, obj)
+	default:
+		return fmt.Errorf("%s: unsupported field type %q", path, f.Type)
+	}
+	return nil
+}
+
+var intRanges = map[FieldType][2]float64{
+	U8:  {0, math.MaxUint8},
+	U16: {0, math.MaxUint16},
+	U32: {0, math.MaxUint32},
+	I8:  {math.MinInt8, math.MaxInt8},
+	I16: {math.MinInt16, math.MaxInt16},
+	I32: {math.MinInt32, math.MaxInt32},
+}
+
+func (e *encoder) integer(path string, t FieldType, value interface{}) error {
+	if b, ok := value.(bool); ok {
+		value = json.Number("0")
+		if b {
+			value = json.Number("1")
+		}
+	}
+	if value == nil {
+		value = json.Number("0")
+	}
+	if s, ok := value.(string); ok {
+		if i, err := strconv.ParseInt(strings.TrimSpace(s), 0, 64); err == nil {
+			value = json.Number(strconv.FormatInt(i, 10))
+		} else if u, err := strconv.ParseUint(strings.TrimSpace(s), 0, 64); err == nil {
+			value = json.Number(strconv.FormatUint(u, 10))
+		} else {
+			return fmt.Errorf("%s: %q is not an integer", path, s)
+		}
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return fmt.Errorf("%s: expected number", path)
+	}
+
+	if t == U64 {
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s is not a u64", path, n)
+		}
+		e.put(u)
+		return nil
+	}
+	i, err := strconv.ParseInt(n.String(), 10, 64)
+	if err != nil {
+		f, ferr := n.Float64()
+		if ferr != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return fmt.Errorf("%s: %s is not an integer", path, n)
+		}
+		i = int64(f)
+	}
+	if r, ok := intRanges[t]; ok && (float64(i) < r[0] || float64(i) > r[1]) {
+		return fmt.Errorf("%s: %d out of range for %s", path, i, t)
+	}
+
+	switch t {
+	case U8:
+		e.put(uint8(i))
+	case U16:
+		e.put(uint16(i))
+	case U32:
+		e.put(uint32(i))
+	case I8:
+		e.put(int8(i))
+	case I16:
+		e.put(int16(i))
+	case I32:
+		e.put(int32(i))
+	case I64:
+		e.put(i)
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected number")
+	}
+	return n.Float64()
+}
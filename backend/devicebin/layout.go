@@ -0,0 +1,205 @@
+// Package devicebin packs settings documents into the binary images loaded by
+// devices and unpacks them again. An image is a 12 byte little-endian header
+// (magic, layout version, flags, payload length), the payload encoded field
+// by field according to a versioned Layout, and a CRC-32 (IEEE) of header and
+// payload.
+package devicebin
+
+import (
+	"filepackage/model"
+	"fmt"
+	"sort"
+)
+
+type FieldType string
+
+const (
+	U8     FieldType = "u8"
+	U16    FieldType = "u16"
+	U32    FieldType = "u32"
+	U64    FieldType = "u64"
+	I8     FieldType = "i8"
+	I16    FieldType = "i16"
+	I32    FieldType = "i32"
+	I64    FieldType = "i64"
+	F32    FieldType = "f32"
+	F64    FieldType = "f64"
+	Bool   FieldType = "bool"
+	String FieldType = "string"
+	Enum   FieldType = "enum"
+	Array  FieldType = "array"
+	Map    FieldType = "map"
+	Struct FieldType = "struct"
+)
+
+const (
+	KindCan = "can"
+	KindNrf = "nrf"
+
+	LittleEndian = "little_endian"
+	BigEndian    = "big_endian"
+)
+
+// Field describes one JSON member and how it is packed. Integers may also be
+// given as strings such as "0x7FF". Strings are u16 length-prefixed unless
+// Size fixes their width, enums are a u8 index into Values, arrays and maps
+// carry a u16 element count, and optional fields are preceded by a u8
+// presence flag.
+type Field struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Size     int       `json:"size,omitempty"`
+	Optional bool      `json:"optional,omitempty"`
+	Values   []string  `json:"values,omitempty"`
+	Elem     *Field    `json:"elem,omitempty"`
+	Fields   []Field   `json:"fields,omitempty"`
+}
+
+// Layout is a versioned image format. Ignored names the top-level members
+// the layout deliberately leaves out of the image; any other member without
+// a field is an error.
+type Layout struct {
+	Kind      string   `json:"kind"`
+	Version   uint16   `json:"version"`
+	ByteOrder string   `json:"byteorder"`
+	Fields    []Field  `json:"fields"`
+	Ignored   []string `json:"ignored,omitempty"`
+}
+
+var magics = map[string]string{KindCan: "CANS", KindNrf: "NRFS"}
+
+var layouts = map[string][]Layout{}
+
+func register(l Layout) {
+	layouts[l.Kind] = append(layouts[l.Kind], l)
+	sort.Slice(layouts[l.Kind], func(i, j int) bool {
+		return layouts[l.Kind][i].Version < layouts[l.Kind][j].Version
+	})
+}
+
+// Layouts returns the registered layouts for kind, oldest first.
+func Layouts(kind string) []Layout {
+	return layouts[kind]
+}
+
+// Lookup returns the layout for kind at version, or the latest layout when
+// version is 0.
+func Lookup(kind string, version uint16) (Layout, error) {
+	list := layouts[kind]
+	if len(list) == 0 {
+		return Layout{}, fmt.Errorf("no layouts for %q", kind)
+	}
+	if version == 0 {
+		return list[len(list)-1], nil
+	}
+	for _, l := range list {
+		if l.Version == version {
+			return l, nil
+		}
+	}
+	return Layout{}, fmt.Errorf("unknown %s layout version %d", kind, version)
+}
+
+func str(name string) Field {
+	return Field{Name: name, Type: String}
+}
+
+func strs(name string) Field {
+	return Field{Name: name, Type: Array, Elem: &Field{Type: String}}
+}
+
+func num(name string, t FieldType) Field {
+	return Field{Name: name, Type: t}
+}
+
+func transitions(field func(name string) Field) []Field {
+	fields := make([]Field, 0, len(model.SleepTransitions))
+	for _, name := range model.SleepTransitions {
+		fields = append(fields, field(name))
+	}
+	return fields
+}
+
+func init() {
+	signal := Field{Type: Struct, Fields: []Field{
+		str("name"),
+		num("startbit", U16),
+		num("length", U8),
+		{Name: "byteorder", Type: Enum, Values: []string{LittleEndian, BigEndian}},
+		num("signed", Bool),
+		num("factor", F64),
+		num("offset", F64),
+		num("min", F64),
+		num("max", F64),
+		str("unit"),
+		strs("receivers"),
+		num("multiplexer", Bool),
+		{Name: "multiplexvalue", Type: U16, Optional: true},
+		str("valuetable"),
+		{Name: "values", Type: Map, Elem: &Field{Type: String}},
+		str("comment"),
+	}}
+	// Transmit and receive parameters end with the frame description that
+	// DBC imports add; see model.CanTxParam and model.CanRxParam.
+	frame := []Field{
+		str("name"),
+		num("extended", Bool),
+		num("dlc", U8),
+		str("transmitter"),
+		str("comment"),
+		{Name: "signals", Type: Array, Elem: &signal},
+	}
+	txParam := Field{Type: Struct, Fields: append([]Field{
+		num("paramID", U16),
+		num("arbID", U32),
+		num("txInterval", U32),
+	}, frame...)}
+	rxParam := Field{Type: Struct, Fields: append([]Field{
+		num("paramID", U16),
+		num("arbIDMask", U32),
+		num("arbIDFilter", U32),
+		num("snapshotInterval", U32),
+		num("filterIDRef", U16),
+	}, frame...)}
+	filterId := Field{Type: Struct, Fields: []Field{
+		num("filterref", U16),
+		num("filterid", U32),
+	}}
+	// CAN layout 1 carries the CAN controller configuration only. The
+	// settings and coprocdsl sections of CAN documents, and the dbc section
+	// that only serves DBC export, stay in the stored document and are left
+	// out of the image.
+	register(Layout{Kind: KindCan, Version: 1, ByteOrder: LittleEndian, Fields: []Field{
+		{Name: "globalconfig", Type: Map, Elem: &Field{Type: U32}},
+		{Name: "cantx", Type: Struct, Fields: []Field{
+			{Name: "txparams", Type: Array, Elem: &txParam},
+		}},
+		{Name: "canrx", Type: Struct, Fields: []Field{
+			{Name: "filtermasks", Type: Array, Elem: &Field{Type: U32}},
+			{Name: "filterids", Type: Array, Elem: &filterId},
+			{Name: "rxparams", Type: Array, Elem: &rxParam},
+		}},
+	}, Ignored: []string{"settings", "coprocdsl", "dbc"}})
+
+	// NRF layout 1 carries the sleep state machine only.
+	register(Layout{Kind: KindNrf, Version: 1, ByteOrder: LittleEndian, Fields: []Field{
+		{Name: "sleepsettings", Type: Struct, Fields: transitions(func(name string) Field {
+			return Field{Name: name, Type: Struct, Fields: []Field{
+				num("ain0_v", U32),
+				num("ain1_v", U32),
+				num("vbat_v", U32),
+				num("vin_v", U32),
+				num("hysteresis", U32),
+				num("mov_timeout", U32),
+				num("state_timeout", U32),
+			}}
+		})},
+		{Name: "sleepcdns", Type: Struct, Fields: transitions(func(name string) Field {
+			return Field{Name: name, Type: Struct, Fields: []Field{
+				strs("cdn_and"),
+				strs("cdn_or"),
+				strs("cdn_targ"),
+			}}
+		})},
+	}})
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"filepackage/devicebin"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBinaryUploadSize = 1 << 20
+
+func binaryLayout(c *gin.Context, kind string) (devicebin.Layout, bool) {
+	var version uint64
+	if v := c.Query("layout"); v != "" {
+		var err error
+		if version, err = strconv.ParseUint(v, 10, 16); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Layout must be a numeric version"})
+			return devicebin.Layout{}, false
+		}
+	}
+	layout, err := devicebin.Lookup(kind, uint16(version))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return devicebin.Layout{}, false
+	}
+	return layout, true
+}
+
+func respondBinary(c *gin.Context, layout devicebin.Layout, name string, version int, doc []byte) {
+	image, err := devicebin.Encode(layout, doc)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to encode settings", "details": err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("%s.v%d.l%d.bin", name, version, layout.Version)
+	c.Header("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	c.Header("X-Layout-Version", strconv.Itoa(int(layout.Version)))
+	c.Data(http.StatusOK, "application/octet-stream", image)
+}
+
+func ExportCanSettingsBinary(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	layout, ok := binaryLayout(c, devicebin.KindCan)
+	if !ok {
+		return
+	}
+
+	canSettings, err := findCanSettings(fileName, c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
+		return
+	}
+	doc, err := json.Marshal(canSettings.JSONData)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode can settings"})
+		return
+	}
+	respondBinary(c, layout, fileName, canSettings.Version, doc)
+}
+
+func ExportNrfSettingsBinary(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	layout, ok := binaryLayout(c, devicebin.KindNrf)
+	if !ok {
+		return
+	}
+
+	nrfSettings, err := findNrfSettings(fileName, c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+	raw, err := json.Marshal(nrfSettings.Document())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode nrf settings"})
+		return
+	}
+	respondBinary(c, layout, fileName, nrfSettings.Version, raw)
+}
+
+func GetBinaryLayouts(c *gin.Context) {
+	kinds := []string{devicebin.KindCan, devicebin.KindNrf}
+	if kind := c.Query("kind"); kind != "" {
+		kinds = []string{kind}
+	}
+
+	layouts := []devicebin.Layout{}
+	for _, kind := range kinds {
+		layouts = append(layouts, devicebin.Layouts(kind)...)
+	}
+	c.JSON(http.StatusOK, layouts)
+}
+
+func DecodeSettingsBinary(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Binary file is required"})
+		return
+	}
+	if fileHeader.Size > maxBinaryUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Binary file is too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read binary file"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read binary file"})
+		return
+	}
+
+	image, err := devicebin.Decode(data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid settings binary", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, image)
+}
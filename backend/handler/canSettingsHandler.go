@@ -2,15 +2,101 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"filepackage/config"
+	"filepackage/lint"
 	"filepackage/model"
+	"filepackage/utils"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type canSettingsRequest struct {
+	FileName string          `json:"filename"`
+	JSONData json.RawMessage `json:"jsondata"`
+}
+
+func findCanSettings(fileName, version string) (model.CanSettings, error) {
+	var canSettings model.CanSettings
+	query := config.DB.Where("filename = ?", fileName)
+	if version != "" {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return canSettings, fmt.Errorf("invalid version %q", version)
+		}
+		query = query.Where("version = ?", v)
+	} else {
+		query = query.Where("iscurrent")
+	}
+	err := query.First(&canSettings).Error
+	return canSettings, err
+}
+
+func createCanSettingsVersion(fileName string, jsonData []byte, user string) (model.CanSettings, bool, error) {
+	var canSettings model.CanSettings
+	created := false
+	hash := utils.ContentHash(jsonData)
+
+	var data model.CanSettingsData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return canSettings, false, &settingsRequestError{"Invalid JSON data: " + err.Error()}
+	}
+
+	schemaId, err := validateSettings(settingsTypeCan, nil, jsonData)
+	if err != nil {
+		return canSettings, false, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var current model.CanSettings
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("filename = ? AND iscurrent", fileName).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && current.ContentHash == hash {
+			canSettings = current
+			return nil
+		}
+
+		var maxVersion int
+		if err := tx.Model(&model.CanSettings{}).Where("filename = ?", fileName).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.CanSettings{}).Where("filename = ? AND iscurrent", fileName).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+
+		fileId, err := newFileId()
+		if err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		canSettings = model.CanSettings{
+			FileId:      fileId,
+			FileName:    fileName,
+			Version:     maxVersion + 1,
+			ContentHash: hash,
+			IsCurrent:   true,
+			SchemaId:    schemaId,
+			JSONData:    &data,
+			CreatedBy:   user,
+			UpdatedBy:   user,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		created = true
+		return tx.Create(&canSettings).Error
+	})
+	return canSettings, created, err
+}
+
 func GetCanSettingsByFileName(c *gin.Context) {
 	fileName := c.Param("filename")
 	fileName = strings.TrimSpace(fileName)
@@ -20,24 +106,22 @@ func GetCanSettingsByFileName(c *gin.Context) {
 		return
 	}
 
-	var canSettings model.CanSettings
-	if err := config.DB.Where("filename = ?", fileName).First(&canSettings).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
-		return
+	version := c.Param("version")
+	if version == "" {
+		version = c.Query("version")
 	}
-
-	var response map[string]interface{}
-	if err := json.Unmarshal(canSettings.JSONData, &response); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse JSON data"})
+	canSettings, err := findCanSettings(fileName, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	respondMappedSettings(c, settingsTypeCan, canSettings.JSONData)
 }
 
 func GetAllFileNames(c *gin.Context) {
 	var canSettings []model.CanSettings
-	if err := config.DB.Select("filename").Find(&canSettings).Error; err != nil {
+	if err := config.DB.Select("filename").Where("iscurrent").Find(&canSettings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file names"})
 		return
 	}
@@ -48,3 +132,106 @@ func GetAllFileNames(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, fileNames)
 }
+
+func CreateCanSettings(c *gin.Context) {
+	var req canSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.FileName = strings.TrimSpace(req.FileName)
+	if req.FileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+	if len(req.JSONData) == 0 || !json.Valid(req.JSONData) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid JSON data is required"})
+		return
+	}
+
+	var data model.CanSettingsData
+	if err := json.Unmarshal(req.JSONData, &data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data: " + err.Error()})
+		return
+	}
+	reports, ok := lintUpload(c, settingsTypeCan, req.FileName, lint.Target{Can: &data})
+	if !ok {
+		return
+	}
+
+	canSettings, created, err := createCanSettingsVersion(req.FileName, req.JSONData, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to save can settings")
+		return
+	}
+
+	if !created {
+		response := gin.H{"message": "Can settings " + req.FileName + " unchanged", "version": canSettings.Version}
+		addLintReports(response, reports)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	response := gin.H{"message": "Can settings " + req.FileName + " saved as version " + strconv.Itoa(canSettings.Version), "version": canSettings.Version, "fileid": canSettings.FileId}
+	addLintReports(response, reports)
+	if warnings := usageWarnings(canSettingsUsages, req.FileName); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+func GetCanSettingsVersions(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	if fileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+
+	var versions []model.CanSettings
+	result := config.DB.Omit("jsondata").Where("filename = ?", fileName).Order("version DESC").Find(&versions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch can settings versions"})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func SetCurrentCanSettingsVersion(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	version, err := strconv.Atoi(c.Param("version"))
+	if fileName == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name and a numeric version are required"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var target model.CanSettings
+		if err := tx.Omit("jsondata").Where("filename = ? AND version = ?", fileName, version).First(&target).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.CanSettings{}).Where("filename = ? AND iscurrent", fileName).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.CanSettings{}).Where("filename = ? AND version = ?", fileName, version).
+			Updates(map[string]interface{}{"iscurrent": true, "updatedby": c.GetString("email"), "updatedat": time.Now().Unix()}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current version"})
+		return
+	}
+
+	response := gin.H{"message": "Can settings " + fileName + " version " + strconv.Itoa(version) + " is now current"}
+	if warnings := usageWarnings(canSettingsUsages, fileName); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
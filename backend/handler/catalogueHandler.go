@@ -0,0 +1,399 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// catalogueFilters are the catalogue columns that can be matched exactly as
+// query parameters.
+var catalogueFilters = []string{"vehicletype", "oem", "model", "variant", "fueltype", "transmission"}
+
+var yearOfMfg = regexp.MustCompile(`^[0-9]{4}$`)
+
+type catalogueVehicleResponse struct {
+	model.CatalogueVehicle
+	ModelName   string `json:"modelname"`
+	DisplayName string `json:"displayname"`
+}
+
+type unmatchedHarness struct {
+	vehicleResponse
+	Reason     string                     `json:"reason"`
+	Candidates []catalogueVehicleResponse `json:"candidates"`
+}
+
+func toCatalogueVehicleResponse(v model.CatalogueVehicle) catalogueVehicleResponse {
+	d := v.Descriptor()
+	return catalogueVehicleResponse{CatalogueVehicle: v, ModelName: d.String(), DisplayName: d.DisplayName()}
+}
+
+// findCatalogueVehicle returns the catalogue entry other than vehicleId
+// described by d, compared without regard to case or surrounding space.
+func findCatalogueVehicle(tx *gorm.DB, d model.ModelDescriptor, vehicleId int) (*model.CatalogueVehicle, error) {
+	var existing model.CatalogueVehicle
+	err := tx.Where("LOWER(vehicletype) = LOWER(?) AND LOWER(oem) = LOWER(?) AND LOWER(model) = LOWER(?) AND LOWER(variant) = LOWER(?) AND year = ? AND LOWER(fueltype) = LOWER(?) AND LOWER(transmission) = LOWER(?) AND vehicleid <> ?",
+		strings.TrimSpace(d.VehicleType), strings.TrimSpace(d.Oem), strings.TrimSpace(d.Model), strings.TrimSpace(d.Variant),
+		d.Year, strings.TrimSpace(d.FuelType), strings.TrimSpace(d.Transmission), vehicleId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// ensureCatalogueVehicle returns the id of the catalogue entry described by
+// d, adding one if there is none.
+func ensureCatalogueVehicle(tx *gorm.DB, d model.ModelDescriptor, user string) (int, error) {
+	existing, err := findCatalogueVehicle(tx, d, 0)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return existing.VehicleId, nil
+	}
+	vehicle := model.CatalogueVehicle{UpdatedBy: user, UpdatedAt: time.Now().Unix()}
+	applyDescriptor(&vehicle, d)
+	if err := tx.Create(&vehicle).Error; err != nil {
+		return 0, err
+	}
+	return vehicle.VehicleId, nil
+}
+
+func applyDescriptor(v *model.CatalogueVehicle, d model.ModelDescriptor) {
+	v.VehicleType = d.VehicleType
+	v.Oem = d.Oem
+	v.Model = d.Model
+	v.Variant = d.Variant
+	v.Year = d.Year
+	v.FuelType = d.FuelType
+	v.Transmission = d.Transmission
+}
+
+// linkHarnessVehicle copies the fields of the catalogue vehicle h refers to
+// into h. When h refers to none it is linked to the entry matching its
+// fields, if there is one.
+func linkHarnessVehicle(tx *gorm.DB, h *model.Harness) error {
+	if h.VehicleId == nil {
+		existing, err := findCatalogueVehicle(tx, h.Descriptor(), 0)
+		if err != nil || existing == nil {
+			return err
+		}
+		h.VehicleId = &existing.VehicleId
+		return nil
+	}
+
+	var v model.CatalogueVehicle
+	if err := tx.First(&v, "vehicleid = ?", *h.VehicleId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &settingsRequestError{"Catalogue vehicle " + strconv.Itoa(*h.VehicleId) + " does not exist"}
+		}
+		return err
+	}
+	h.VehicleType = v.VehicleType
+	h.VehicleOem = v.Oem
+	h.VehicleModel = v.Model
+	h.VehicleVariant = v.Variant
+	h.YearOfMfg = strconv.Itoa(v.Year)
+	h.FuelType = v.FuelType
+	h.TransmissionType = v.Transmission
+	return nil
+}
+
+func catalogueVehicleId(c *gin.Context) (int, bool) {
+	vehicleId, err := strconv.Atoi(c.Param("vehicleid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vehicle id must be numeric"})
+		return 0, false
+	}
+	return vehicleId, true
+}
+
+func GetCatalogueVehicles(c *gin.Context) {
+	query := config.DB.Order("vehicletype, oem, model, variant, year, fueltype, transmission, vehicleid")
+	for _, column := range catalogueFilters {
+		if value, ok := c.GetQuery(column); ok {
+			query = query.Where("LOWER("+column+") = LOWER(?)", strings.TrimSpace(value))
+		}
+	}
+	if value, ok := c.GetQuery("year"); ok {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Year must be numeric"})
+			return
+		}
+		query = query.Where("year = ?", year)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("(oem ILIKE ? OR model ILIKE ? OR variant ILIKE ?)", pattern, pattern, pattern)
+	}
+
+	var vehicles []model.CatalogueVehicle
+	if err := query.Find(&vehicles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch catalogue"})
+		return
+	}
+	responses := make([]catalogueVehicleResponse, 0, len(vehicles))
+	for _, v := range vehicles {
+		responses = append(responses, toCatalogueVehicleResponse(v))
+	}
+	respondList(c, responses)
+}
+
+// GetCatalogueVehicle returns a catalogue entry with the group models and
+// harnesses referencing it.
+func GetCatalogueVehicle(c *gin.Context) {
+	vehicleId, ok := catalogueVehicleId(c)
+	if !ok {
+		return
+	}
+
+	var vehicle model.CatalogueVehicle
+	if err := config.DB.First(&vehicle, "vehicleid = ?", vehicleId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	var models []model.GroupModels
+	if err := config.DB.Where("vehicleid = ?", vehicleId).Order("modelid").Find(&models).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models"})
+		return
+	}
+	var harnesses []model.Harness
+	if err := config.DB.Where("vehicleid = ?", vehicleId).Order("slno").Find(&harnesses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harnesses"})
+		return
+	}
+
+	modelResponses := make([]groupModelResponse, 0, len(models))
+	for _, m := range models {
+		modelResponses = append(modelResponses, toGroupModelResponse(m))
+	}
+	harnessResponses := make([]vehicleResponse, 0, len(harnesses))
+	for _, h := range harnesses {
+		harnessResponses = append(harnessResponses, toVehicleResponse(h))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"vehicle":   toCatalogueVehicleResponse(vehicle),
+		"models":    modelResponses,
+		"harnesses": harnessResponses,
+	})
+}
+
+func CreateCatalogueVehicle(c *gin.Context) {
+	var req vehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	vehicle := model.CatalogueVehicle{UpdatedBy: c.GetString("email"), UpdatedAt: time.Now().Unix()}
+	applyDescriptor(&vehicle, req.descriptor())
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		existing, err := findCatalogueVehicle(tx, req.descriptor(), 0)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &settingsConflictError{"Vehicle already exists as " + strconv.Itoa(existing.VehicleId)}
+		}
+		return tx.Create(&vehicle).Error
+	})
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to create vehicle")
+		return
+	}
+	c.JSON(http.StatusCreated, toCatalogueVehicleResponse(vehicle))
+}
+
+// UpdateCatalogueVehicle replaces the fields of a catalogue entry and copies
+// them to the group models and harnesses referencing it. Packages built for
+// those models pick up the new model name.
+func UpdateCatalogueVehicle(c *gin.Context) {
+	vehicleId, ok := catalogueVehicleId(c)
+	if !ok {
+		return
+	}
+	var req vehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var vehicle model.CatalogueVehicle
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		if err := tx.First(&vehicle, "vehicleid = ?", vehicleId).Error; err != nil {
+			return err
+		}
+		existing, err := findCatalogueVehicle(tx, req.descriptor(), vehicleId)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &settingsConflictError{"Vehicle already exists as " + strconv.Itoa(existing.VehicleId)}
+		}
+
+		applyDescriptor(&vehicle, req.descriptor())
+		vehicle.UpdatedBy = c.GetString("email")
+		vehicle.UpdatedAt = time.Now().Unix()
+		if err := tx.Save(&vehicle).Error; err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.GroupModels{}).Where("vehicleid = ?", vehicleId).Updates(map[string]interface{}{
+			"vehicletype":  vehicle.VehicleType,
+			"oem":          vehicle.Oem,
+			"model":        vehicle.Model,
+			"variant":      vehicle.Variant,
+			"year":         vehicle.Year,
+			"fueltype":     vehicle.FuelType,
+			"transmission": vehicle.Transmission,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.FilePackage{}).
+			Where(`modelid IN (SELECT modelid FROM "LAFPackages"."groupmodels" WHERE vehicleid = ?)`, vehicleId).
+			Update("modelname", vehicle.Descriptor().String()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Harness{}).Where("vehicleid = ?", vehicleId).Updates(map[string]interface{}{
+			"vehicletype":      vehicle.VehicleType,
+			"vehicleoem":       vehicle.Oem,
+			"vehiclemodel":     vehicle.Model,
+			"vehiclevariant":   vehicle.Variant,
+			"yearofmfg":        strconv.Itoa(vehicle.Year),
+			"fueltype":         vehicle.FuelType,
+			"transmissiontype": vehicle.Transmission,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to update vehicle")
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogueVehicleResponse(vehicle))
+}
+
+func DeleteCatalogueVehicle(c *gin.Context) {
+	vehicleId, ok := catalogueVehicleId(c)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		var models, harnesses int64
+		if err := tx.Model(&model.GroupModels{}).Where("vehicleid = ?", vehicleId).Count(&models).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Harness{}).Where("vehicleid = ?", vehicleId).Count(&harnesses).Error; err != nil {
+			return err
+		}
+		if models > 0 || harnesses > 0 {
+			return &settingsConflictError{fmt.Sprintf("Vehicle is used by %d models and %d harnesses", models, harnesses)}
+		}
+		result := tx.Delete(&model.CatalogueVehicle{}, "vehicleid = ?", vehicleId)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to delete vehicle")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle " + strconv.Itoa(vehicleId) + " deleted"})
+}
+
+// GetUnmatchedHarnesses lists the harnesses not linked to the catalogue, with
+// the reason and the catalogue entries for the same OEM and model as
+// candidates for cleanup.
+func GetUnmatchedHarnesses(c *gin.Context) {
+	var harnesses []model.Harness
+	if err := config.DB.Where("vehicleid IS NULL").Order("slno").Find(&harnesses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harnesses"})
+		return
+	}
+	var vehicles []model.CatalogueVehicle
+	if err := config.DB.Order("vehicletype, oem, model, variant, year, fueltype, transmission, vehicleid").Find(&vehicles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch catalogue"})
+		return
+	}
+
+	byModel := map[string][]catalogueVehicleResponse{}
+	for _, v := range vehicles {
+		key := strings.ToLower(v.Oem) + "\x00" + strings.ToLower(v.Model)
+		byModel[key] = append(byModel[key], toCatalogueVehicleResponse(v))
+	}
+
+	results := make([]unmatchedHarness, 0, len(harnesses))
+	for _, h := range harnesses {
+		key := strings.ToLower(strings.TrimSpace(h.VehicleOem)) + "\x00" + strings.ToLower(strings.TrimSpace(h.VehicleModel))
+		candidates := byModel[key]
+		if candidates == nil {
+			candidates = []catalogueVehicleResponse{}
+		}
+		reason := "No catalogue vehicle has the same fields"
+		switch {
+		case !yearOfMfg.MatchString(strings.TrimSpace(h.YearOfMfg)):
+			reason = "Year of manufacture is not a year"
+		case len(candidates) == 0:
+			reason = "No catalogue vehicle has the same OEM and model"
+		}
+		results = append(results, unmatchedHarness{vehicleResponse: toVehicleResponse(h), Reason: reason, Candidates: candidates})
+	}
+	respondList(c, results)
+}
+
+// MatchHarnessCatalogue links unmatched harnesses whose fields now match a
+// catalogue entry, e.g. after cleaning them up.
+func MatchHarnessCatalogue(c *gin.Context) {
+	matched, err := config.MatchHarnessVehicles(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to match harnesses"})
+		return
+	}
+	var unmatched int64
+	if err := config.DB.Model(&model.Harness{}).Where("vehicleid IS NULL").Count(&unmatched).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unmatched harnesses"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"matched": matched, "unmatched": unmatched})
+}
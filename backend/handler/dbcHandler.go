@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"filepackage/dbc"
+	"filepackage/lint"
+	"filepackage/model"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type dbcPreview struct {
+	FileName     string                `json:"filename"`
+	MessageCount int                   `json:"messagecount"`
+	SignalCount  int                   `json:"signalcount"`
+	Document     model.CanDocument     `json:"document"`
+	Settings     model.CanSettingsData `json:"settings"`
+	Warnings     []string              `json:"warnings"`
+}
+
+func parseUploadedDbc(c *gin.Context) (*dbcPreview, bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return nil, false
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open uploaded file"})
+		return nil, false
+	}
+	defer src.Close()
+
+	result, err := dbc.Parse(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse DBC file", "details": err.Error()})
+		return nil, false
+	}
+
+	fileName := strings.TrimSpace(c.PostForm("filename"))
+	if fileName == "" {
+		fileName = strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename))
+	}
+
+	// Messages sent by node are transmitted by the device; the rest are
+	// received.
+	node := strings.TrimSpace(c.PostForm("node"))
+	preview := &dbcPreview{
+		FileName:     fileName,
+		MessageCount: len(result.Document.Messages),
+		Document:     result.Document,
+		Settings:     model.CanSettingsFromDocument(result.Document, node),
+		Warnings:     result.Warnings,
+	}
+	if node != "" && len(preview.Settings.CanTx.TxParams) == 0 {
+		preview.Warnings = append(preview.Warnings, "Node "+node+" does not transmit any message")
+	}
+	for _, msg := range result.Document.Messages {
+		preview.SignalCount += len(msg.Signals)
+	}
+	if preview.Warnings == nil {
+		preview.Warnings = []string{}
+	}
+	return preview, true
+}
+
+func PreviewDbc(c *gin.Context) {
+	preview, ok := parseUploadedDbc(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+func ImportDbc(c *gin.Context) {
+	preview, ok := parseUploadedDbc(c)
+	if !ok {
+		return
+	}
+
+	jsonData, err := json.Marshal(preview.Settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode can settings"})
+		return
+	}
+	reports, ok := lintUpload(c, settingsTypeCan, preview.FileName, lint.Target{Can: &preview.Settings})
+	if !ok {
+		return
+	}
+
+	canSettings, created, err := createCanSettingsVersion(preview.FileName, jsonData, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to save can settings")
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	} else {
+		preview.Warnings = append(preview.Warnings, usageWarnings(canSettingsUsages, preview.FileName)...)
+	}
+	response := gin.H{"message": "Can settings " + preview.FileName + " imported as version " + strconv.Itoa(canSettings.Version), "version": canSettings.Version, "fileid": canSettings.FileId, "warnings": preview.Warnings}
+	addLintReports(response, reports)
+	c.JSON(status, response)
+}
+
+func ExportDbc(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	if fileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+
+	canSettings, err := findCanSettings(fileName, c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
+		return
+	}
+
+	if canSettings.JSONData == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Can settings do not contain any CAN messages"})
+		return
+	}
+	doc, err := canSettings.JSONData.CanDocument()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to convert can settings to DBC", "details": err.Error()})
+		return
+	}
+	if len(doc.Messages) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Can settings do not contain any CAN messages"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := dbc.Write(&buf, doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate DBC file"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+fileName+".dbc\"")
+	c.Data(http.StatusOK, "application/octet-stream", buf.Bytes())
+}
+
+func newFileId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
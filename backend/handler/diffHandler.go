@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"filepackage/jsondiff"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type diffSide struct {
+	FileName string `json:"filename"`
+	Version  string `json:"version,omitempty"`
+}
+
+func (s diffSide) label() string {
+	if s.Version == "" {
+		return s.FileName
+	}
+	return s.FileName + "@" + s.Version
+}
+
+func diffSides(c *gin.Context) (diffSide, diffSide, bool) {
+	from := diffSide{FileName: strings.TrimSpace(c.Query("from")), Version: c.Query("fromversion")}
+	to := diffSide{FileName: strings.TrimSpace(c.Query("to")), Version: c.Query("toversion")}
+	if to.FileName == "" {
+		to.FileName = from.FileName
+	}
+	if from.FileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "From file name is required"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+func diffOptions(c *gin.Context) jsondiff.Options {
+	var opts jsondiff.Options
+	for _, key := range strings.Split(c.Query("keys"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			opts.ArrayKeys = append(opts.ArrayKeys, key)
+		}
+	}
+	return opts
+}
+
+func respondDiff(c *gin.Context, from, to diffSide, a, b interface{}) {
+	changes := jsondiff.Compare(a, b, diffOptions(c))
+
+	switch c.DefaultQuery("format", "json") {
+	case "text":
+		c.String(http.StatusOK, jsondiff.RenderText(changes, from.label(), to.label()))
+		return
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(jsondiff.RenderHTML(changes, from.label(), to.label())))
+		return
+	case "json":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be one of json, text or html"})
+		return
+	}
+
+	summary := map[jsondiff.ChangeType]int{jsondiff.Added: 0, jsondiff.Removed: 0, jsondiff.Modified: 0}
+	for _, ch := range changes {
+		summary[ch.Type]++
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "summary": summary, "changes": changes})
+}
+
+func DiffCanSettings(c *gin.Context) {
+	from, to, ok := diffSides(c)
+	if !ok {
+		return
+	}
+	if from == to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify two different files or versions"})
+		return
+	}
+
+	docs := make([]interface{}, 2)
+	for i, side := range []diffSide{from, to} {
+		canSettings, err := findCanSettings(side.FileName, side.Version)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Can settings " + side.label() + " not found"})
+			return
+		}
+		if docs[i], err = genericDocument(canSettings.JSONData); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse JSON data"})
+			return
+		}
+	}
+
+	respondDiff(c, from, to, docs[0], docs[1])
+}
+
+func DiffNrfSettings(c *gin.Context) {
+	from, to, ok := diffSides(c)
+	if !ok {
+		return
+	}
+	if from == to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify two different files or versions"})
+		return
+	}
+
+	docs := make([]interface{}, 2)
+	for i, side := range []diffSide{from, to} {
+		nrfSettings, err := findNrfSettings(side.FileName, side.Version)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings " + side.label() + " not found"})
+			return
+		}
+		if docs[i], err = genericDocument(nrfSettings.Document()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse JSON data"})
+			return
+		}
+	}
+
+	respondDiff(c, from, to, docs[0], docs[1])
+}
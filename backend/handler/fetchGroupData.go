@@ -3,76 +3,191 @@ package handler
 import (
 	"filepackage/config"
 	"filepackage/model"
-	"fmt"
+	"filepackage/utils"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	matchExact    = "exact"
+	matchContains = "contains"
+	matchFuzzy    = "fuzzy"
+
+	defaultFuzzyThreshold = 0.3
+)
+
 type Model struct {
-	ModelId   int    `json:"modelid"`
-	ModelName string `json:"modelname"`
+	ModelId     int                   `json:"modelid"`
+	ModelName   string                `json:"modelname"`
+	DisplayName string                `json:"displayname"`
+	Descriptor  model.ModelDescriptor `json:"descriptor"`
+	GroupId     int                   `json:"groupid"`
+	Score       *float64              `json:"score,omitempty"`
 }
 
 type Group struct {
-	GroupId   int    `json:"groupid"`
-	GroupName string `json:"groupname"`
+	GroupId   int      `json:"groupid"`
+	GroupName string   `json:"groupname"`
+	Score     *float64 `json:"score,omitempty"`
 }
 
-func GetAllGroups(c *gin.Context) {
-	var groups []model.GroupModels
-	distinctGroupsMap := make(map[int]Group)
-	groupName := c.Query("groupname")
+// matchGroups finds the groups whose name matches name in the mode given by
+// the match query parameter: exact (ignoring case), contains, or fuzzy,
+// which ranks groups scoring at least the threshold parameter by trigram
+// similarity. Ties and the other modes are ordered by name, then id.
+func matchGroups(c *gin.Context, name, defaultMode string) ([]Group, bool) {
+	mode := c.DefaultQuery("match", defaultMode)
+	if mode != matchExact && mode != matchContains && mode != matchFuzzy {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Match must be exact, contains or fuzzy"})
+		return nil, false
+	}
+	threshold := defaultFuzzyThreshold
+	if value := c.Query("threshold"); value != "" {
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil || t < 0 || t > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Threshold must be a number between 0 and 1"})
+			return nil, false
+		}
+		threshold = t
+	}
 
-	query := config.DB.Distinct("groupid", "groupname")
-	if groupName != "" {
-		query = query.Where("groupname ILIKE ?", "%"+groupName+"%")
+	query := config.DB.Order("groupname, groupid")
+	if name != "" {
+		switch mode {
+		case matchExact:
+			query = query.Where("LOWER(groupname) = LOWER(?)", name)
+		case matchContains:
+			query = query.Where("groupname ILIKE ?", "%"+escapeLike(name)+"%")
+		}
+	}
+	var rows []model.VehicleGroup
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return nil, false
 	}
 
-	result := query.Find(&groups)
+	groups := []Group{}
+	for _, row := range rows {
+		group := Group{GroupId: row.GroupId, GroupName: row.GroupName}
+		if mode == matchFuzzy && name != "" {
+			score := utils.TrigramSimilarity(name, row.GroupName)
+			if score < threshold {
+				continue
+			}
+			group.Score = &score
+		}
+		groups = append(groups, group)
+	}
+	if mode == matchFuzzy && name != "" {
+		sort.SliceStable(groups, func(i, j int) bool {
+			return *groups[i].Score > *groups[j].Score
+		})
+	}
+	return groups, true
+}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+func GetAllGroups(c *gin.Context) {
+	groups, ok := matchGroups(c, strings.TrimSpace(c.Query("groupname")), matchContains)
+	if !ok {
 		return
 	}
+	respondList(c, groups)
+}
 
-	for _, group := range groups {
-		distinctGroupsMap[group.GroupId] = Group{
-			GroupId:   group.GroupId,
-			GroupName: group.GroupName,
-		}
+// groupModels returns the models of groups in group order, each group's
+// models sorted by vehicle type, OEM, model, variant and year.
+func groupModels(groups []Group) ([]Model, error) {
+	models := []Model{}
+	if len(groups) == 0 {
+		return models, nil
 	}
+	ids := make([]int, len(groups))
+	rank := make(map[int]int, len(groups))
+	for i, group := range groups {
+		ids[i] = group.GroupId
+		rank[group.GroupId] = i
+	}
+
+	var rows []model.GroupModels
+	err := config.DB.Where("groupid IN ?", ids).Order("vehicletype, oem, model, variant, year, fueltype, transmission, modelid").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rank[rows[i].GroupId] < rank[rows[j].GroupId]
+	})
 
-	var distinctGroups []Group
-	for _, group := range distinctGroupsMap {
-		distinctGroups = append(distinctGroups, group)
+	for _, row := range rows {
+		descriptor := row.Descriptor()
+		models = append(models, Model{
+			ModelId:     row.ModelId,
+			ModelName:   descriptor.String(),
+			DisplayName: descriptor.DisplayName(),
+			Descriptor:  descriptor,
+			GroupId:     row.GroupId,
+			Score:       groups[rank[row.GroupId]].Score,
+		})
 	}
-	c.JSON(http.StatusOK, distinctGroups)
+	return models, nil
 }
 
+// GetModelsForGroup looks a group up by name, exactly unless another match
+// mode is requested.
 func GetModelsForGroup(c *gin.Context) {
-	var models []Model
-	groupname := c.Param("groupname")
+	modelsForGroupName(c, c.Param("groupname"))
+}
+
+// GetModelsForGroupByNameDeprecated serves GET /groups/:groupid, which reads
+// a group name where the other /groups routes take an id. It answers as
+// GetModelsForGroup and points clients at /groups/by-name/:groupname/models.
+func GetModelsForGroupByNameDeprecated(c *gin.Context) {
+	groupname := c.Param("groupid")
+	successor := strings.TrimSuffix(c.FullPath(), ":groupid") + "by-name/" + url.PathEscape(strings.TrimSpace(groupname)) + "/models"
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+	modelsForGroupName(c, groupname)
+}
 
+func modelsForGroupName(c *gin.Context, groupname string) {
+	groupname = strings.TrimSpace(groupname)
 	if groupname == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Group Name is required"})
 		return
 	}
 
-	var groups []model.GroupModels
-	result := config.DB.Where("groupname ILIKE ?", "%"+groupname+"%").Find(&groups)
-	if result.Error != nil {
+	groups, ok := matchGroups(c, groupname, matchExact)
+	if !ok {
+		return
+	}
+	models, err := groupModels(groups)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models for group"})
 		return
 	}
+	respondList(c, models)
+}
 
-	for _, group := range groups {
-		modelName := fmt.Sprintf("%s_%s_%s_%s_%d_%s_%s", group.VehicleType, group.Oem, group.Model, group.Variant, group.Year, group.FuelType, group.Transmission)
-		models = append(models, Model{
-			ModelId:   group.ModelId,
-			ModelName: modelName,
-		})
+func GetModelsForGroupId(c *gin.Context) {
+	groupId, err := strconv.Atoi(c.Param("groupid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be numeric"})
+		return
 	}
 
-	c.JSON(http.StatusOK, models)
+	var group model.VehicleGroup
+	if err := config.DB.First(&group, "groupid = ?", groupId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	models, err := groupModels([]Group{{GroupId: group.GroupId, GroupName: group.GroupName}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models for group"})
+		return
+	}
+	respondList(c, models)
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"filepackage/storage"
 	"fmt"
 	"io"
 	"net/http"
@@ -24,7 +25,7 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	basePath := "/home/akash/harness/"
+	basePath := storage.Dir
 	folderName := c.PostForm("folder")
 	folderName = filepath.Join(basePath, folderName)
 	if folderName == "" {
@@ -64,7 +65,7 @@ func UploadFile(c *gin.Context) {
 }
 
 func DownloadFile(c *gin.Context) {
-	basePath := "/home/akash/harness/"
+	basePath := storage.Dir
 	folderName := c.Query("folder")
 	filename := c.Query("filename")
 	filename, err := url.QueryUnescape(filename)
@@ -0,0 +1,502 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// modelLevels are the groupmodels columns browsed from the top down.
+var modelLevels = []string{"vehicletype", "oem", "model", "variant", "year"}
+
+type groupRequest struct {
+	GroupName string `json:"groupname"`
+}
+
+// vehicleRequest holds the fields describing a vehicle, shared by group
+// models and catalogue entries.
+type vehicleRequest struct {
+	VehicleType  string `json:"vehicletype"`
+	Oem          string `json:"oem"`
+	Model        string `json:"model"`
+	Variant      string `json:"variant"`
+	Year         int    `json:"year"`
+	FuelType     string `json:"fueltype"`
+	Transmission string `json:"transmission"`
+}
+
+type groupModelRequest struct {
+	GroupId *int `json:"groupid"`
+	vehicleRequest
+}
+
+type groupModelResponse struct {
+	model.GroupModels
+	ModelName   string                `json:"modelname"`
+	DisplayName string                `json:"displayname"`
+	Descriptor  model.ModelDescriptor `json:"descriptor"`
+}
+
+type modelTreeNode struct {
+	Value    string               `json:"value"`
+	Children []*modelTreeNode     `json:"children,omitempty"`
+	Models   []groupModelResponse `json:"models,omitempty"`
+}
+
+func toGroupModelResponse(g model.GroupModels) groupModelResponse {
+	d := g.Descriptor()
+	return groupModelResponse{GroupModels: g, ModelName: d.String(), DisplayName: d.DisplayName(), Descriptor: d}
+}
+
+func (req *vehicleRequest) normalize() string {
+	for _, field := range []*string{&req.VehicleType, &req.Oem, &req.Model, &req.Variant, &req.FuelType, &req.Transmission} {
+		*field = strings.TrimSpace(*field)
+	}
+	switch {
+	case req.VehicleType == "":
+		return "Vehicle type is required"
+	case req.Oem == "":
+		return "OEM is required"
+	case req.Model == "":
+		return "Model is required"
+	case req.Year < 1900 || req.Year > 2100:
+		return "Year must be between 1900 and 2100"
+	}
+	return ""
+}
+
+func (req *vehicleRequest) descriptor() model.ModelDescriptor {
+	return model.ModelDescriptor{
+		VehicleType:  req.VehicleType,
+		Oem:          req.Oem,
+		Model:        req.Model,
+		Variant:      req.Variant,
+		Year:         req.Year,
+		FuelType:     req.FuelType,
+		Transmission: req.Transmission,
+	}
+}
+
+func (req *vehicleRequest) apply(g *model.GroupModels) {
+	g.VehicleType = req.VehicleType
+	g.Oem = req.Oem
+	g.Model = req.Model
+	g.Variant = req.Variant
+	g.Year = req.Year
+	g.FuelType = req.FuelType
+	g.Transmission = req.Transmission
+}
+
+// duplicateGroupModel returns the model other than modelId with the same
+// OEM, model, variant, year, fuel type and transmission, compared without
+// regard to case.
+func duplicateGroupModel(tx *gorm.DB, g model.GroupModels, modelId int) (*model.GroupModels, error) {
+	var existing model.GroupModels
+	err := tx.Where("LOWER(oem) = LOWER(?) AND LOWER(model) = LOWER(?) AND LOWER(variant) = LOWER(?) AND year = ? AND LOWER(fueltype) = LOWER(?) AND LOWER(transmission) = LOWER(?) AND modelid <> ?",
+		g.Oem, g.Model, g.Variant, g.Year, g.FuelType, g.Transmission, modelId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func duplicateGroupName(tx *gorm.DB, name string, groupId int) (bool, error) {
+	var count int64
+	err := tx.Model(&model.VehicleGroup{}).Where("LOWER(groupname) = LOWER(?) AND groupid <> ?", name, groupId).Count(&count).Error
+	return count > 0, err
+}
+
+func lockGroupModels(tx *gorm.DB) error {
+	return tx.Exec(`LOCK TABLE "LAFPackages"."groups", "LAFPackages"."groupmodels", "LAFPackages"."vehicles" IN SHARE ROW EXCLUSIVE MODE`).Error
+}
+
+func CreateGroup(c *gin.Context) {
+	var req groupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.GroupName = strings.TrimSpace(req.GroupName)
+	if req.GroupName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group name is required"})
+		return
+	}
+
+	var group model.VehicleGroup
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		if dup, err := duplicateGroupName(tx, req.GroupName, 0); err != nil || dup {
+			if err == nil {
+				err = &settingsConflictError{"Group " + req.GroupName + " already exists"}
+			}
+			return err
+		}
+
+		var maxId int
+		err := tx.Raw(`SELECT COALESCE(MAX(groupid), 0) FROM (SELECT groupid FROM "LAFPackages"."groups" UNION ALL SELECT groupid FROM "LAFPackages"."groupmodels") ids`).Scan(&maxId).Error
+		if err != nil {
+			return err
+		}
+		group = model.VehicleGroup{GroupId: maxId + 1, GroupName: req.GroupName, UpdatedBy: c.GetString("email"), UpdatedAt: time.Now().Unix()}
+		return tx.Create(&group).Error
+	})
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to create group")
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup renames a group, along with the copies of its name held by
+// its models and packages.
+func UpdateGroup(c *gin.Context) {
+	groupId, err := strconv.Atoi(c.Param("groupid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be numeric"})
+		return
+	}
+	var req groupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.GroupName = strings.TrimSpace(req.GroupName)
+	if req.GroupName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group name is required"})
+		return
+	}
+
+	var group model.VehicleGroup
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		if err := tx.First(&group, "groupid = ?", groupId).Error; err != nil {
+			return err
+		}
+		if dup, err := duplicateGroupName(tx, req.GroupName, groupId); err != nil || dup {
+			if err == nil {
+				err = &settingsConflictError{"Group " + req.GroupName + " already exists"}
+			}
+			return err
+		}
+
+		group.GroupName = req.GroupName
+		group.UpdatedBy = c.GetString("email")
+		group.UpdatedAt = time.Now().Unix()
+		if err := tx.Save(&group).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.GroupModels{}).Where("groupid = ?", groupId).Update("groupname", req.GroupName).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.FilePackage{}).Where("groupid = ?", groupId).Update("groupname", req.GroupName).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to update group")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func DeleteGroup(c *gin.Context) {
+	groupId, err := strconv.Atoi(c.Param("groupid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be numeric"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		var models, packages int64
+		if err := tx.Model(&model.GroupModels{}).Where("groupid = ?", groupId).Count(&models).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.FilePackage{}).Where("groupid = ?", groupId).Count(&packages).Error; err != nil {
+			return err
+		}
+		if models > 0 || packages > 0 {
+			return &settingsConflictError{fmt.Sprintf("Group has %d models and %d packages", models, packages)}
+		}
+		result := tx.Delete(&model.VehicleGroup{}, "groupid = ?", groupId)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to delete group")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Group " + strconv.Itoa(groupId) + " deleted"})
+}
+
+func CreateGroupModel(c *gin.Context) {
+	var req groupModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.GroupId == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id is required"})
+		return
+	}
+	if msg := req.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var created model.GroupModels
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		var group model.VehicleGroup
+		if err := tx.First(&group, "groupid = ?", *req.GroupId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &settingsRequestError{"Group " + strconv.Itoa(*req.GroupId) + " does not exist"}
+			}
+			return err
+		}
+
+		created = model.GroupModels{GroupId: group.GroupId, GroupName: group.GroupName}
+		req.apply(&created)
+		existing, err := duplicateGroupModel(tx, created, 0)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &settingsConflictError{"Model already exists as " + strconv.Itoa(existing.ModelId) + " in group " + existing.GroupName}
+		}
+
+		vehicleId, err := ensureCatalogueVehicle(tx, created.Descriptor(), c.GetString("email"))
+		if err != nil {
+			return err
+		}
+		created.VehicleId = &vehicleId
+
+		if err := tx.Model(&model.GroupModels{}).Select("COALESCE(MAX(modelid), 0)").Scan(&created.ModelId).Error; err != nil {
+			return err
+		}
+		created.ModelId++
+		return tx.Create(&created).Error
+	})
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to create model")
+		return
+	}
+	c.JSON(http.StatusCreated, toGroupModelResponse(created))
+}
+
+// UpdateGroupModel replaces the attributes of a model and optionally moves it
+// to another group. Packages built for the model pick up its new name.
+func UpdateGroupModel(c *gin.Context) {
+	modelId, err := strconv.Atoi(c.Param("modelid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model id must be numeric"})
+		return
+	}
+	var req groupModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var updated model.GroupModels
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockGroupModels(tx); err != nil {
+			return err
+		}
+		if err := tx.Where("modelid = ?", modelId).First(&updated).Error; err != nil {
+			return err
+		}
+		if req.GroupId != nil && *req.GroupId != updated.GroupId {
+			var group model.VehicleGroup
+			if err := tx.First(&group, "groupid = ?", *req.GroupId).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &settingsRequestError{"Group " + strconv.Itoa(*req.GroupId) + " does not exist"}
+				}
+				return err
+			}
+			updated.GroupId, updated.GroupName = group.GroupId, group.GroupName
+		}
+		req.apply(&updated)
+
+		existing, err := duplicateGroupModel(tx, updated, modelId)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &settingsConflictError{"Model already exists as " + strconv.Itoa(existing.ModelId) + " in group " + existing.GroupName}
+		}
+
+		vehicleId, err := ensureCatalogueVehicle(tx, updated.Descriptor(), c.GetString("email"))
+		if err != nil {
+			return err
+		}
+		updated.VehicleId = &vehicleId
+
+		err = tx.Model(&model.GroupModels{}).Where("modelid = ?", modelId).Updates(map[string]interface{}{
+			"groupid":      updated.GroupId,
+			"groupname":    updated.GroupName,
+			"vehicletype":  updated.VehicleType,
+			"oem":          updated.Oem,
+			"model":        updated.Model,
+			"variant":      updated.Variant,
+			"year":         updated.Year,
+			"fueltype":     updated.FuelType,
+			"transmission": updated.Transmission,
+			"vehicleid":    vehicleId,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.FilePackage{}).Where("modelid = ?", modelId).Updates(map[string]interface{}{
+			"groupid":   updated.GroupId,
+			"groupname": updated.GroupName,
+			"modelname": updated.Descriptor().String(),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to update model")
+		return
+	}
+	c.JSON(http.StatusOK, toGroupModelResponse(updated))
+}
+
+func DeleteGroupModel(c *gin.Context) {
+	modelId, err := strconv.Atoi(c.Param("modelid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model id must be numeric"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var packages int64
+		if err := tx.Model(&model.FilePackage{}).Where("modelid = ?", modelId).Count(&packages).Error; err != nil {
+			return err
+		}
+		if packages > 0 {
+			return &settingsConflictError{fmt.Sprintf("Model is used by %d packages", packages)}
+		}
+		result := tx.Where("modelid = ?", modelId).Delete(&model.GroupModels{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to delete model")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Model " + strconv.Itoa(modelId) + " deleted"})
+}
+
+// BrowseGroupModels returns the values of the first level in modelLevels not
+// given as a query parameter, narrowed by those that are. Once every level
+// is given it returns the matching models.
+func BrowseGroupModels(c *gin.Context) {
+	query := config.DB.Model(&model.GroupModels{})
+	next := ""
+	for _, level := range modelLevels {
+		value, ok := c.GetQuery(level)
+		if !ok {
+			next = level
+			break
+		}
+		if level == "year" {
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Year must be numeric"})
+				return
+			}
+			query = query.Where("year = ?", year)
+			continue
+		}
+		query = query.Where(level+" = ?", value)
+	}
+
+	if next == "" {
+		var rows []model.GroupModels
+		if err := query.Order("fueltype, transmission, modelid").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models"})
+			return
+		}
+		models := make([]groupModelResponse, 0, len(rows))
+		for _, row := range rows {
+			models = append(models, toGroupModelResponse(row))
+		}
+		c.JSON(http.StatusOK, gin.H{"level": "models", "models": models})
+		return
+	}
+
+	values := []interface{}{}
+	if err := query.Distinct(next).Order(next).Pluck(next, &values).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"level": next, "values": values})
+}
+
+// GetGroupModelTree returns every model nested by vehicle type, OEM, model,
+// variant and year.
+func GetGroupModelTree(c *gin.Context) {
+	var rows []model.GroupModels
+	if err := config.DB.Order("vehicletype, oem, model, variant, year, fueltype, transmission, modelid").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models"})
+		return
+	}
+
+	root := &modelTreeNode{}
+	for _, row := range rows {
+		node := root
+		for _, value := range []string{row.VehicleType, row.Oem, row.Model, row.Variant, strconv.Itoa(row.Year)} {
+			n := len(node.Children)
+			if n == 0 || node.Children[n-1].Value != value {
+				node.Children = append(node.Children, &modelTreeNode{Value: value})
+				n++
+			}
+			node = node.Children[n-1]
+		}
+		node.Models = append(node.Models, toGroupModelResponse(row))
+	}
+	if root.Children == nil {
+		root.Children = []*modelTreeNode{}
+	}
+	c.JSON(http.StatusOK, gin.H{"levels": modelLevels, "tree": root.Children})
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"filepackage/storage"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxAttachmentSize = 20 << 20
+
+// attachmentTypes maps the file extensions accepted for each attachment kind
+// to their content types.
+var attachmentTypes = map[string]map[string]string{
+	model.AttachmentHarnessImage: {".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg"},
+	model.AttachmentDiagram:      {".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".pdf": "application/pdf"},
+}
+
+var errNoAttachment = errors.New("harness has no such attachment")
+
+func attachmentKind(c *gin.Context) (string, bool) {
+	kind := c.Param("kind")
+	if _, ok := attachmentTypes[kind]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment must be harnessimage or diagram"})
+		return "", false
+	}
+	return kind, true
+}
+
+// setHarnessAttachment points the design of h's PH code at the file name for
+// the attachment kind, creating a revision when it changes, and returns the
+// file name it replaced. An empty name removes the attachment.
+func setHarnessAttachment(tx *gorm.DB, h *model.Harness, kind, name, notes, user string) (string, error) {
+	if err := reviseHarnessDesign(tx, h, model.HarnessDesign{}, "", user); err != nil {
+		return "", err
+	}
+	current, err := currentHarnessRevision(tx, h.PhCode)
+	if err != nil {
+		return "", err
+	}
+	design := current.HarnessDesign
+	previous := *design.Attachment(kind)
+	*design.Attachment(kind) = name
+	if design == current.HarnessDesign {
+		return previous, applyHarnessRevision(tx, current)
+	}
+	next, err := createHarnessRevision(tx, current, design, notes, user)
+	h.SetDesign(design)
+	h.Rev = next.Rev
+	return previous, err
+}
+
+// removeOrphanedAttachment deletes the file and metadata of an attachment
+// that no harness or unarchived revision references any more. Replaced and
+// removed attachments stay in the revision history of their PH code, so in
+// practice a file is freed when an upload fails or once the revisions that
+// referenced it are archived. The metadata row stays locked until the file
+// is gone, so that an upload of the same file waits for the removal.
+func removeOrphanedAttachment(kind, name string) {
+	if name == "" {
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var attachment model.HarnessAttachment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("kind = ? AND filename = ?", kind, name).Find(&attachment).Error; err != nil {
+			return err
+		}
+		var refs int64
+		if err := tx.Model(&model.Harness{}).Where(kind+" = ?", name).Count(&refs).Error; err != nil || refs > 0 {
+			return err
+		}
+		if err := tx.Model(&model.HarnessRevision{}).Where(kind+" = ? AND archivedat IS NULL", name).Count(&refs).Error; err != nil || refs > 0 {
+			return err
+		}
+		if err := tx.Where("kind = ? AND filename = ?", kind, name).Delete(&model.HarnessAttachment{}).Error; err != nil {
+			return err
+		}
+		return storage.Remove(kind, name)
+	})
+	if err != nil {
+		log.Printf("Error removing %s %s: %v", kind, name, err)
+	}
+}
+
+// GetHarnessAttachments returns the metadata of a harness's image and
+// diagram. Files uploaded before attachments were tracked carry only their
+// name.
+func GetHarnessAttachments(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	design := vehicle.Design()
+	attachments := gin.H{}
+	for _, kind := range []string{model.AttachmentHarnessImage, model.AttachmentDiagram} {
+		name := *design.Attachment(kind)
+		if name == "" {
+			attachments[kind] = nil
+			continue
+		}
+		attachment := model.HarnessAttachment{Kind: kind, FileName: name}
+		if err := config.DB.Where("kind = ? AND filename = ?", kind, name).Find(&attachment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+			return
+		}
+		attachments[kind] = attachment
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+func DownloadHarnessAttachment(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	kind, ok := attachmentKind(c)
+	if !ok {
+		return
+	}
+	design := vehicle.Design()
+	name := *design.Attachment(kind)
+	if name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Harness has no " + kind})
+		return
+	}
+	path, err := storage.Path(kind, name)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File " + name + " not found"})
+		return
+	}
+
+	var attachment model.HarnessAttachment
+	if err := config.DB.Where("kind = ? AND filename = ?", kind, name).Find(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = attachmentTypes[kind][strings.ToLower(filepath.Ext(name))]
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	downloadName := attachment.OriginalName
+	if downloadName == "" {
+		downloadName = name
+	}
+	c.FileAttachment(path, downloadName)
+}
+
+// UploadHarnessAttachment stores the uploaded file as a harness's image or
+// diagram, replacing the previous one. Like other design changes it applies
+// to every harness with the PH code and creates a revision. Files are named
+// by a prefix of their hash, so the same upload is stored once.
+func UploadHarnessAttachment(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	kind, ok := attachmentKind(c)
+	if !ok {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if header.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachments are limited to 20 MB"})
+		return
+	}
+	original := filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/"))
+	contentType, ok := attachmentTypes[kind][strings.ToLower(filepath.Ext(original))]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type for " + kind + ": " + original})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open uploaded file"})
+		return
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	user := c.GetString("email")
+	attachment := model.HarnessAttachment{
+		Kind:         kind,
+		FileName:     hash[:12] + "-" + original,
+		OriginalName: original,
+		Size:         size,
+		ContentType:  contentType,
+		Hash:         hash,
+		UploadedBy:   user,
+		UploadedAt:   time.Now().Unix(),
+	}
+	var previous string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "kind"}, {Name: "filename"}},
+			DoUpdates: clause.AssignmentColumns([]string{"uploadedby", "uploadedat"}),
+		}).Create(&attachment).Error
+		if err != nil {
+			return err
+		}
+		if err := storage.Save(kind, attachment.FileName, file); err != nil {
+			return err
+		}
+		previous, err = setHarnessAttachment(tx, &vehicle, kind, attachment.FileName, "Uploaded "+kind+" "+original, user)
+		return err
+	})
+	if err != nil {
+		removeOrphanedAttachment(kind, attachment.FileName)
+		respondSettingsWriteError(c, err, "Failed to upload "+kind)
+		return
+	}
+	if previous != attachment.FileName {
+		removeOrphanedAttachment(kind, previous)
+	}
+	c.JSON(http.StatusOK, gin.H{"attachment": attachment, "vehicle": toVehicleResponse(vehicle)})
+}
+
+// DeleteHarnessAttachment removes a harness's image or diagram in a new
+// revision. The file is kept for the earlier revisions that reference it
+// until they are archived with the last harness of the PH code.
+func DeleteHarnessAttachment(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	kind, ok := attachmentKind(c)
+	if !ok {
+		return
+	}
+
+	var previous string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		previous, err = setHarnessAttachment(tx, &vehicle, kind, "", "Removed "+kind, c.GetString("email"))
+		if err == nil && previous == "" {
+			return errNoAttachment
+		}
+		return err
+	})
+	if errors.Is(err, errNoAttachment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Harness has no " + kind})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to delete "+kind)
+		return
+	}
+	removeOrphanedAttachment(kind, previous)
+	c.JSON(http.StatusOK, toVehicleResponse(vehicle))
+}
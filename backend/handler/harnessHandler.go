@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"filepackage/config"
 	"filepackage/model"
@@ -13,93 +16,242 @@ import (
 	"gorm.io/gorm"
 )
 
+type vehicleResponse struct {
+	model.Harness
+	Key         string                `json:"key"`
+	ModelName   string                `json:"modelname"`
+	DisplayName string                `json:"displayname"`
+	Descriptor  model.ModelDescriptor `json:"descriptor"`
+}
+
+// vehicleKey returns the deprecated comma-joined path parameter addressing h
+// by natural key. Commas inside a field are escaped with a backslash.
+func vehicleKey(h model.Harness) string {
+	return model.JoinEscaped(',', h.PhCode, h.VehicleOem, h.VehicleModel, h.VehicleVariant, h.YearOfMfg)
+}
+
+func toVehicleResponse(h model.Harness) vehicleResponse {
+	d := h.Descriptor()
+	return vehicleResponse{Harness: h, Key: vehicleKey(h), ModelName: d.String(), DisplayName: d.DisplayName(), Descriptor: d}
+}
+
+// harnessKeyFields are the query parameters naming a harness by its
+// natural key.
+var harnessKeyFields = []string{"phcode", "vehicleoem", "vehiclemodel", "vehiclevariant", "yearofmfg"}
+
+// findHarness loads the harness addressed by the request: by id when the
+// harnessid path parameter is numeric, otherwise by natural key taken from
+// the query parameters or from the deprecated comma-joined path form.
+func findHarness(c *gin.Context) (model.Harness, bool) {
+	var vehicle model.Harness
+	param := c.Param("harnessid")
+	if harnessId, err := strconv.ParseInt(param, 10, 64); err == nil {
+		if err := config.DB.First(&vehicle, "harnessid = ?", harnessId).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+			return vehicle, false
+		}
+		return vehicle, true
+	}
+
+	var parts []string
+	if param != "" {
+		parts = model.SplitEscaped(param, ',')
+	} else {
+		for _, field := range harnessKeyFields {
+			if value, ok := c.GetQuery(field); ok {
+				parts = append(parts, value)
+			}
+		}
+	}
+	if len(parts) != len(harnessKeyFields) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "All parameters are required"})
+		return vehicle, false
+	}
+
+	result := config.DB.First(&vehicle, model.Harness{
+		PhCode:         parts[0],
+		VehicleOem:     parts[1],
+		VehicleModel:   parts[2],
+		VehicleVariant: parts[3],
+		YearOfMfg:      parts[4],
+	})
+	if result.Error != nil {
+		log.Printf("Error fetching vehicle: %v", result.Error)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return vehicle, false
+	}
+	return vehicle, true
+}
+
+// duplicateHarness reports whether a harness other than h has its natural
+// key.
+func duplicateHarness(tx *gorm.DB, h model.Harness) (bool, error) {
+	var count int64
+	err := tx.Model(&model.Harness{}).Where("phcode = ? AND vehicleoem = ? AND vehiclemodel = ? AND vehiclevariant = ? AND yearofmfg = ? AND harnessid <> ?",
+		h.PhCode, h.VehicleOem, h.VehicleModel, h.VehicleVariant, h.YearOfMfg, h.HarnessId).Count(&count).Error
+	return count > 0, err
+}
+
 func CreateVehicle(c *gin.Context) {
 	var vehicle model.Harness
 	if err := c.ShouldBindJSON(&vehicle); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	vehicle.HarnessId = 0
 
-	result := config.DB.Model(&vehicle).Omit("slno").Create(&vehicle)
-	if result.Error != nil {
+	if err := linkHarnessVehicle(config.DB, &vehicle); err != nil {
+		respondSettingsWriteError(c, err, "Failed to create vehicle")
+		return
+	}
+	if dup, err := duplicateHarness(config.DB, vehicle); err != nil || dup {
+		if err == nil {
+			err = &settingsConflictError{"Vehicle " + vehicleKey(vehicle) + " already exists"}
+		}
+		respondSettingsWriteError(c, err, "Failed to create vehicle")
+		return
+	}
+
+	// Initial stock is booked through the ledger like any other movement.
+	stock := vehicle.CurrentStock
+	if stock < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current stock cannot be negative"})
+		return
+	}
+	vehicle.CurrentStock = 0
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The new harness shares the design of its PH code's revisions.
+		if err := reviseHarnessDesign(tx, &vehicle, vehicle.Design(), "", c.GetString("email")); err != nil {
+			return err
+		}
+		if err := tx.Model(&vehicle).Omit("slno").Create(&vehicle).Error; err != nil {
+			return err
+		}
+		if stock == 0 {
+			return nil
+		}
+		movement := model.StockMovement{
+			HarnessId: vehicle.HarnessId,
+			Type:      model.StockIn,
+			Quantity:  stock,
+			Reason:    "Initial stock",
+			CreatedBy: c.GetString("email"),
+			CreatedAt: time.Now().Unix(),
+		}
+		if err := postStockMovement(tx, &movement); err != nil {
+			return err
+		}
+		vehicle.CurrentStock = movement.Balance
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create vehicle"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, vehicle)
+	c.JSON(http.StatusCreated, toVehicleResponse(vehicle))
 }
 
 func GetVehicle(c *gin.Context) {
-	params := c.Param("vehicledetails")
-	parts := strings.Split(params, ",")
-
-	if len(parts) != 5 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "All parameters are required"})
+	vehicle, ok := findHarness(c)
+	if !ok {
 		return
 	}
 
-	phcode := parts[0]
-	vehicleoem := parts[1]
-	vehiclemodel := parts[2]
-	vehiclevariant := parts[3]
-	yearofmfg := parts[4]
+	c.JSON(http.StatusOK, toVehicleResponse(vehicle))
+}
 
-	var vehicle model.Harness
-	result := config.DB.First(&vehicle, model.Harness{
-		PhCode:         phcode,
-		VehicleOem:     vehicleoem,
-		VehicleModel:   vehiclemodel,
-		VehicleVariant: vehiclevariant,
-		YearOfMfg:      yearofmfg,
-	})
+// vehicleFilters are the harness columns that GET /vehicles filters on and
+// reports facets for.
+var vehicleFilters = []string{"vehicletype", "vehicleoem", "vehiclemodel", "fueltype", "transmissiontype", "ignitiontype", "devicetype", "immotype", "can", "panic"}
 
-	if result.Error != nil {
-		log.Printf("Error fetching vehicle: %v", result.Error)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
-		return
+type vehicleFacet struct {
+	Value string `gorm:"column:value" json:"value"`
+	Count int64  `gorm:"column:count" json:"count"`
+}
+
+// vehicleQuery narrows the harnesses to the request's filters, skipping the
+// filter on skip. Each filter column takes one or more values; q searches
+// the description and specification.
+func vehicleQuery(c *gin.Context, skip string) *gorm.DB {
+	query := config.DB.Model(&model.Harness{})
+	for _, column := range vehicleFilters {
+		if values := c.QueryArray(column); column != skip && len(values) > 0 {
+			query = query.Where(column+" IN ?", values)
+		}
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("(description ILIKE ? OR specification ILIKE ?)", pattern, pattern)
 	}
+	return query
+}
 
-	c.JSON(http.StatusOK, vehicle)
+// vehicleFacets counts the harnesses per value of each filter column. The
+// counts for a column apply every filter but its own, so that they show what
+// selecting another value would match.
+func vehicleFacets(c *gin.Context) (map[string][]vehicleFacet, error) {
+	facets := make(map[string][]vehicleFacet, len(vehicleFilters))
+	for _, column := range vehicleFilters {
+		values := []vehicleFacet{}
+		err := vehicleQuery(c, column).Select(column + " AS value, COUNT(*) AS count").Group(column).Order("count DESC, value").Scan(&values).Error
+		if err != nil {
+			return nil, err
+		}
+		facets[column] = values
+	}
+	return facets, nil
 }
 
+// GetAllVehicles lists the harnesses matching the filters. Without page,
+// pagesize or facets it returns them all as a plain array; otherwise it
+// returns one page with the total and, when facets=true, the facet counts.
 func GetAllVehicles(c *gin.Context) {
+	_, hasPage := c.GetQuery("page")
+	_, hasSize := c.GetQuery("pagesize")
+	withFacets := c.Query("facets") == "true"
+	paged := hasPage || hasSize || withFacets
+	page, pageSize := paginationParams(c)
+	query := vehicleQuery(c, "").Order("slno, harnessid")
+	var total int64
+	if paged {
+		if err := vehicleQuery(c, "").Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicles"})
+			return
+		}
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
 	var vehicles []model.Harness
-	result := config.DB.Find(&vehicles)
-	if result.Error != nil {
-		log.Printf("Error fetching vehicles: %v", result.Error)
+	if err := query.Find(&vehicles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicles"})
 		return
 	}
-	c.JSON(http.StatusOK, vehicles)
-}
-
-func UpdateVehicle(c *gin.Context) {
-	params := c.Param("vehicledetails")
-	parts := strings.Split(params, ",")
-
-	fmt.Println(parts)
-
-	if len(parts) != 5 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "All parameters are required"})
+	responses := make([]vehicleResponse, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		responses = append(responses, toVehicleResponse(vehicle))
+	}
+	if !paged {
+		c.JSON(http.StatusOK, responses)
 		return
 	}
 
-	phcode := parts[0]
-	vehicleoem := parts[1]
-	vehiclemodel := parts[2]
-	vehiclevariant := parts[3]
-	yearofmfg := parts[4]
-
-	var vehicle model.Harness
-	result := config.DB.First(&vehicle, model.Harness{
-		PhCode:         phcode,
-		VehicleOem:     vehicleoem,
-		VehicleModel:   vehiclemodel,
-		VehicleVariant: vehiclevariant,
-		YearOfMfg:      yearofmfg,
-	})
+	body := gin.H{"page": page, "pagesize": pageSize, "total": total, "results": responses}
+	if withFacets {
+		facets, err := vehicleFacets(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch facets"})
+			return
+		}
+		body["facets"] = facets
+	}
+	c.JSON(http.StatusOK, body)
+}
 
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+func UpdateVehicle(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
 		return
 	}
 
@@ -108,48 +260,142 @@ func UpdateVehicle(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if updates.CurrentStock != 0 && updates.CurrentStock != vehicle.CurrentStock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current stock can only be changed through stock movements"})
+		return
+	}
+	if updates.Rev != 0 && updates.Rev != vehicle.Rev {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Revision can only be changed through harness revisions"})
+		return
+	}
+	if updates.VehicleId != nil {
+		if err := linkHarnessVehicle(config.DB, &updates); err != nil {
+			respondSettingsWriteError(c, err, "Failed to update vehicle")
+			return
+		}
+	}
 
-	result = config.DB.Model(&vehicle).Omit("updatedat, slno").Where("phcode = ? AND vehicleoem = ? AND vehiclemodel = ? AND vehiclevariant = ? AND yearofmfg = ?",
-		phcode, vehicleoem, vehiclemodel, vehiclevariant, yearofmfg).Updates(updates)
+	// Updates leaves fields that are empty in the request unchanged.
+	merged := vehicle
+	if updates.PhCode != "" {
+		merged.PhCode = updates.PhCode
+	}
+	if updates.VehicleOem != "" {
+		merged.VehicleOem = updates.VehicleOem
+	}
+	if updates.VehicleModel != "" {
+		merged.VehicleModel = updates.VehicleModel
+	}
+	if updates.VehicleVariant != "" {
+		merged.VehicleVariant = updates.VehicleVariant
+	}
+	if updates.YearOfMfg != "" {
+		merged.YearOfMfg = updates.YearOfMfg
+	}
+	if dup, err := duplicateHarness(config.DB, merged); err != nil || dup {
+		if err == nil {
+			err = &settingsConflictError{"Vehicle " + vehicleKey(merged) + " already exists"}
+		}
+		respondSettingsWriteError(c, err, "Failed to update vehicle")
+		return
+	}
 
-	if result.Error != nil {
+	// Design changes go through the revisions of the harness's PH code,
+	// which may be a new one.
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Harness{}).Omit("updatedat, slno, currentstock, rev").Where("harnessid = ?", vehicle.HarnessId).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&vehicle, "harnessid = ?", vehicle.HarnessId).Error; err != nil {
+			return err
+		}
+		if err := reviseHarnessDesign(tx, &vehicle, updates.Design(), "", c.GetString("email")); err != nil {
+			return err
+		}
+		columns := vehicle.Design().Columns()
+		columns["rev"] = vehicle.Rev
+		return tx.Model(&model.Harness{}).Where("harnessid = ?", vehicle.HarnessId).Updates(columns).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vehicle"})
 		return
 	}
 
-	c.JSON(http.StatusOK, vehicle)
+	// Without an explicit catalogue vehicle the harness is matched again,
+	// since the update may have changed its fields.
+	if updates.VehicleId == nil {
+		vehicle.VehicleId = nil
+		if err := linkHarnessVehicle(config.DB, &vehicle); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to match vehicle to catalogue"})
+			return
+		}
+		if err := config.DB.Model(&model.Harness{}).Where("harnessid = ?", vehicle.HarnessId).Update("vehicleid", vehicle.VehicleId).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vehicle"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, toVehicleResponse(vehicle))
 }
 
+// DeleteVehicle deletes a harness without stock history. Harnesses with
+// stock movements stay, so that the ledger keeps its harnesses. The revisions
+// of its PH code are kept; when the last harness with the PH code goes they
+// are archived, and the attachment files nothing else references are removed.
 func DeleteVehicle(c *gin.Context) {
-	params := c.Param("vehicledetails")
-	parts := strings.Split(params, ",")
-
-	if len(parts) != 5 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "All parameters are required"})
+	vehicle, ok := findHarness(c)
+	if !ok {
 		return
 	}
 
-	phcode := parts[0]
-	vehicleoem := parts[1]
-	vehiclemodel := parts[2]
-	vehiclevariant := parts[3]
-	yearofmfg := parts[4]
-
-	result := config.DB.Delete(&model.Harness{}, model.Harness{
-		PhCode:         phcode,
-		VehicleOem:     vehicleoem,
-		VehicleModel:   vehiclemodel,
-		VehicleVariant: vehiclevariant,
-		YearOfMfg:      yearofmfg,
+	var deleted int64
+	var orphans map[string][]string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if _, _, err := lockHarnessStock(tx, vehicle.HarnessId); err != nil {
+			return err
+		}
+		var movements int64
+		if err := tx.Model(&model.StockMovement{}).Where("harnessid = ?", vehicle.HarnessId).Count(&movements).Error; err != nil {
+			return err
+		}
+		if movements > 0 {
+			return &settingsConflictError{fmt.Sprintf("Harness %d has %d stock movements and cannot be deleted", vehicle.HarnessId, movements)}
+		}
+		// Locking the current revision holds back harnesses being added with
+		// the PH code until it is known whether its revisions are archived.
+		if _, err := currentHarnessRevision(tx, vehicle.PhCode); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		result := tx.Delete(&model.Harness{}, "harnessid = ?", vehicle.HarnessId)
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		var remaining int64
+		if err := tx.Model(&model.Harness{}).Where("phcode = ?", vehicle.PhCode).Count(&remaining).Error; err != nil || remaining > 0 {
+			return err
+		}
+		var err error
+		orphans, err = archiveHarnessRevisions(tx, vehicle.PhCode)
+		return err
 	})
-
-	if result.Error != nil {
-		log.Printf("Error Deleting Vehicle: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vehicle"})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error Deleting Vehicle: %v", err)
+		respondSettingsWriteError(c, err, "Failed to delete vehicle")
 		return
 	}
 
-	if result.RowsAffected > 0 {
+	for kind, names := range orphans {
+		for _, name := range names {
+			removeOrphanedAttachment(kind, name)
+		}
+	}
+
+	if deleted > 0 {
 		if err := ResetSlnoSequence(config.DB); err != nil {
 			log.Printf("Error resetting slno sequence: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset slno sequence"})
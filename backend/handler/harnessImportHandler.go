@@ -0,0 +1,539 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	spreadsheetCsv  = "csv"
+	spreadsheetXlsx = "xlsx"
+)
+
+// harnessImportColumns are the harness columns that can be imported and are
+// exported, in export order.
+var harnessImportColumns = []string{
+	"phcode", "ahcode", "currentstock", "vehicletype", "vehicleoem", "vehiclemodel", "vehiclevariant", "yearofmfg",
+	"fueltype", "transmissiontype", "ignitiontype", "devicetype", "specification", "immotype", "immorelayvoltage",
+	"can", "panic", "devversion", "harnessimage", "diagram", "description",
+}
+
+var errImportRollback = errors.New("import rolled back")
+
+type importRow struct {
+	Row    int
+	Values map[string]string
+}
+
+type importIssue struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type importResult struct {
+	DryRun    bool          `json:"dryrun"`
+	Rows      int           `json:"rows"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Ignored   []string      `json:"ignored"`
+	Issues    []importIssue `json:"issues"`
+}
+
+// harnessField returns the text field of h stored in column, or nil for
+// currentstock and unknown columns.
+func harnessField(h *model.Harness, column string) *string {
+	switch column {
+	case "phcode":
+		return &h.PhCode
+	case "ahcode":
+		return &h.AhCode
+	case "vehicletype":
+		return &h.VehicleType
+	case "vehicleoem":
+		return &h.VehicleOem
+	case "vehiclemodel":
+		return &h.VehicleModel
+	case "vehiclevariant":
+		return &h.VehicleVariant
+	case "yearofmfg":
+		return &h.YearOfMfg
+	case "fueltype":
+		return &h.FuelType
+	case "transmissiontype":
+		return &h.TransmissionType
+	case "ignitiontype":
+		return &h.IgnitionType
+	case "devicetype":
+		return &h.DeviceType
+	case "specification":
+		return &h.Specification
+	case "immotype":
+		return &h.ImmoType
+	case "immorelayvoltage":
+		return &h.ImmoRelayVoltage
+	case "can":
+		return &h.Can
+	case "panic":
+		return &h.Panic
+	case "devversion":
+		return &h.DevVersion
+	case "harnessimage":
+		return &h.HarnessImage
+	case "diagram":
+		return &h.Diagram
+	case "description":
+		return &h.Description
+	}
+	return nil
+}
+
+// readSpreadsheet returns the rows of an uploaded CSV file or of a sheet of
+// an XLSX workbook, the first sheet by default.
+func readSpreadsheet(header *multipart.FileHeader, sheet string) ([][]string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case "." + spreadsheetCsv:
+		r := csv.NewReader(file)
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		if err != nil {
+			return nil, &settingsRequestError{"Invalid CSV: " + err.Error()}
+		}
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+		}
+		return rows, nil
+	case "." + spreadsheetXlsx:
+		workbook, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, &settingsRequestError{"Invalid XLSX: " + err.Error()}
+		}
+		defer workbook.Close()
+		if sheet == "" {
+			sheet = workbook.GetSheetName(0)
+		}
+		rows, err := workbook.GetRows(sheet)
+		if err != nil {
+			return nil, &settingsRequestError{"Sheet " + sheet + " not found"}
+		}
+		return rows, nil
+	}
+	return nil, &settingsRequestError{"File must be .csv or .xlsx"}
+}
+
+// writeSpreadsheet responds with rows as a CSV file or XLSX workbook.
+func writeSpreadsheet(c *gin.Context, status int, format, name string, rows [][]string) {
+	var buf bytes.Buffer
+	contentType := "text/csv"
+	if format == spreadsheetXlsx {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		workbook := excelize.NewFile()
+		defer workbook.Close()
+		sheet := workbook.GetSheetName(0)
+		for i, row := range rows {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := workbook.SetSheetRow(sheet, cell, &row); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write spreadsheet"})
+				return
+			}
+		}
+		if err := workbook.Write(&buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write spreadsheet"})
+			return
+		}
+	} else {
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write spreadsheet"})
+			return
+		}
+	}
+	c.Header("Content-Disposition", "attachment; filename=\""+name+"."+format+"\"")
+	c.Data(status, contentType, buf.Bytes())
+}
+
+func spreadsheetFormat(c *gin.Context, param, defaultFormat string) (string, bool) {
+	format := c.DefaultQuery(param, defaultFormat)
+	if format != defaultFormat && format != spreadsheetCsv && format != spreadsheetXlsx {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or xlsx"})
+		return "", false
+	}
+	return format, true
+}
+
+func normalizeHeader(header string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, header)
+}
+
+// importMapping maps the spreadsheet columns to harness columns. The mapping
+// is a JSON object from header to harness column, or to "" to skip the
+// header; other headers match the harness column of the same name, ignoring
+// case, spaces and punctuation. It returns the headers left unmapped.
+func importMapping(headers []string, mapping string) (map[int]string, []string, error) {
+	known := map[string]bool{}
+	for _, column := range harnessImportColumns {
+		known[column] = true
+	}
+
+	var explicit map[string]string
+	if mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &explicit); err != nil {
+			return nil, nil, &settingsRequestError{"Mapping must be a JSON object of header to column"}
+		}
+	}
+
+	columns := map[int]string{}
+	mapped := map[string]string{}
+	ignored := []string{}
+	for i, header := range headers {
+		header = strings.TrimSpace(header)
+		column := normalizeHeader(header)
+		if to, ok := explicit[header]; ok {
+			if to != "" && !known[to] {
+				return nil, nil, &settingsRequestError{fmt.Sprintf("Header %q maps to unknown column %q", header, to)}
+			}
+			column = to
+			delete(explicit, header)
+		}
+		if !known[column] {
+			ignored = append(ignored, header)
+			continue
+		}
+		if other, ok := mapped[column]; ok {
+			return nil, nil, &settingsRequestError{fmt.Sprintf("Headers %q and %q both map to %s", other, header, column)}
+		}
+		mapped[column] = header
+		columns[i] = column
+	}
+	for header := range explicit {
+		return nil, nil, &settingsRequestError{fmt.Sprintf("Header %q is not in the file", header)}
+	}
+	for _, column := range harnessKeyFields {
+		if _, ok := mapped[column]; !ok {
+			return nil, nil, &settingsRequestError{"The natural key columns " + strings.Join(harnessKeyFields, ", ") + " must be mapped"}
+		}
+	}
+	return columns, ignored, nil
+}
+
+// parseImportRows validates the data rows. Rows are numbered as in the
+// spreadsheet, the header being row 1; blank rows are skipped.
+func parseImportRows(rows [][]string, columns map[int]string) ([]importRow, []importIssue) {
+	var records []importRow
+	var issues []importIssue
+	keys := map[string]int{}
+	designs := map[string]importRow{}
+	for i, cells := range rows {
+		row := importRow{Row: i + 2, Values: map[string]string{}}
+		for j, cell := range cells {
+			if column, ok := columns[j]; ok && strings.TrimSpace(cell) != "" {
+				row.Values[column] = strings.TrimSpace(cell)
+			}
+		}
+		if len(row.Values) == 0 {
+			continue
+		}
+
+		var h model.Harness
+		for column, value := range row.Values {
+			if field := harnessField(&h, column); field != nil {
+				*field = value
+			}
+		}
+		if h.PhCode == "" {
+			issues = append(issues, importIssue{Row: row.Row, Column: "phcode", Message: "PH code is required"})
+		}
+		if value, ok := row.Values["currentstock"]; ok {
+			if stock, err := strconv.Atoi(value); err != nil || stock < 0 {
+				issues = append(issues, importIssue{Row: row.Row, Column: "currentstock", Message: "Current stock must be a whole number of at least 0"})
+			}
+		}
+		key := vehicleKey(h)
+		if other, ok := keys[key]; ok {
+			issues = append(issues, importIssue{Row: row.Row, Message: fmt.Sprintf("Duplicates row %d", other)})
+		} else {
+			keys[key] = row.Row
+		}
+		// Harnesses with the same PH code share one design.
+		if other, ok := designs[h.PhCode]; ok {
+			for column := range h.Design().Columns() {
+				if value, set := row.Values[column]; set && other.Values[column] != "" && other.Values[column] != value {
+					issues = append(issues, importIssue{Row: row.Row, Column: column, Message: fmt.Sprintf("Differs from row %d with the same PH code", other.Row)})
+				}
+			}
+		} else {
+			designs[h.PhCode] = row
+		}
+		records = append(records, row)
+	}
+	return records, issues
+}
+
+// applyImportRow creates or updates the harness with the row's natural key.
+// Empty cells leave the harness unchanged. Design columns go through the
+// revisions of the PH code and current stock through the ledger. It returns
+// whether the harness was created, updated or unchanged.
+func applyImportRow(tx *gorm.DB, row importRow, user string) (string, error) {
+	var fromRow model.Harness
+	for column, value := range row.Values {
+		if field := harnessField(&fromRow, column); field != nil {
+			*field = value
+		}
+	}
+	stock, hasStock := 0, false
+	if value, ok := row.Values["currentstock"]; ok {
+		stock, _ = strconv.Atoi(value)
+		hasStock = true
+	}
+	now := time.Now().Unix()
+
+	var h model.Harness
+	err := tx.Where("phcode = ? AND vehicleoem = ? AND vehiclemodel = ? AND vehiclevariant = ? AND yearofmfg = ?",
+		fromRow.PhCode, fromRow.VehicleOem, fromRow.VehicleModel, fromRow.VehicleVariant, fromRow.YearOfMfg).First(&h).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h = fromRow
+		h.UpdatedBy = user
+		h.UpdatedAt = now
+		if err := linkHarnessVehicle(tx, &h); err != nil {
+			return "", err
+		}
+		if err := reviseHarnessDesign(tx, &h, h.Design(), "Imported", user); err != nil {
+			return "", err
+		}
+		if err := tx.Model(&h).Omit("slno").Create(&h).Error; err != nil {
+			return "", err
+		}
+		if stock > 0 {
+			movement := model.StockMovement{HarnessId: h.HarnessId, Type: model.StockIn, Quantity: stock, Reason: "Initial stock", Reference: "Import", CreatedBy: user, CreatedAt: now}
+			if err := postStockMovement(tx, &movement); err != nil {
+				return "", err
+			}
+		}
+		return "created", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	before := h
+	for column, value := range row.Values {
+		if field := harnessField(&h, column); field != nil {
+			*field = value
+		}
+	}
+	if h.Descriptor() != before.Descriptor() {
+		h.VehicleId = nil
+		if err := linkHarnessVehicle(tx, &h); err != nil {
+			return "", err
+		}
+	}
+	if err := reviseHarnessDesign(tx, &h, fromRow.Design(), "Imported", user); err != nil {
+		return "", err
+	}
+	changed := h != before
+	if changed {
+		h.UpdatedBy = user
+		h.UpdatedAt = now
+		columns := []string{"vehicleid", "rev", "updatedby", "updatedat"}
+		for _, column := range harnessImportColumns {
+			if column != "currentstock" {
+				columns = append(columns, column)
+			}
+		}
+		if err := tx.Model(&model.Harness{}).Where("harnessid = ?", h.HarnessId).Select(columns).Updates(&h).Error; err != nil {
+			return "", err
+		}
+	}
+	if hasStock {
+		_, current, err := lockHarnessStock(tx, h.HarnessId)
+		if err != nil {
+			return "", err
+		}
+		if stock != current {
+			movement := model.StockMovement{HarnessId: h.HarnessId, Type: model.StockAdjustment, Quantity: stock - current, Reason: "Import", CreatedBy: user, CreatedAt: now}
+			if err := postStockMovement(tx, &movement); err != nil {
+				return "", err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return "unchanged", nil
+	}
+	return "updated", nil
+}
+
+func importErrorMessage(err error) string {
+	var conflictErr *settingsConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr.message
+	}
+	var requestErr *settingsRequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.message
+	}
+	log.Printf("Error importing harness row: %v", err)
+	return "Failed to save row"
+}
+
+// importReport lists the rows with issues as they were in the file, with
+// their row numbers and issues.
+func importReport(rows [][]string, issues []importIssue) [][]string {
+	messages := map[int][]string{}
+	var order []int
+	for _, issue := range issues {
+		if _, ok := messages[issue.Row]; !ok {
+			order = append(order, issue.Row)
+		}
+		message := issue.Message
+		if issue.Column != "" {
+			message = issue.Column + ": " + message
+		}
+		messages[issue.Row] = append(messages[issue.Row], message)
+	}
+
+	header := append([]string{"row"}, rows[0]...)
+	report := [][]string{append(header, "errors")}
+	for _, row := range order {
+		line := append([]string{strconv.Itoa(row)}, rows[row-1]...)
+		for len(line) < len(header) {
+			line = append(line, "")
+		}
+		report = append(report, append(line, strings.Join(messages[row], "; ")))
+	}
+	return report
+}
+
+// ImportVehicles upserts harnesses from an uploaded CSV or XLSX file on their
+// natural key, all rows or none. With dryrun=true the import is validated and
+// rolled back. When rows have issues nothing is saved, and report=csv or
+// report=xlsx returns them as a file instead of JSON.
+func ImportVehicles(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	report, ok := spreadsheetFormat(c, "report", "")
+	if !ok {
+		return
+	}
+	rows, err := readSpreadsheet(header, c.PostForm("sheet"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to read file")
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+		return
+	}
+	columns, ignored, err := importMapping(rows[0], c.PostForm("mapping"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to read file")
+		return
+	}
+
+	result := importResult{DryRun: c.Query("dryrun") == "true", Ignored: ignored}
+	records, issues := parseImportRows(rows[1:], columns)
+	result.Rows = len(records)
+	result.Issues = issues
+	if len(issues) == 0 {
+		user := c.GetString("email")
+		err = config.DB.Transaction(func(tx *gorm.DB) error {
+			for _, record := range records {
+				var outcome string
+				err := tx.Transaction(func(rowTx *gorm.DB) error {
+					var err error
+					outcome, err = applyImportRow(rowTx, record, user)
+					return err
+				})
+				switch {
+				case err != nil:
+					result.Issues = append(result.Issues, importIssue{Row: record.Row, Message: importErrorMessage(err)})
+				case outcome == "created":
+					result.Created++
+				case outcome == "updated":
+					result.Updated++
+				default:
+					result.Unchanged++
+				}
+			}
+			if len(result.Issues) > 0 || result.DryRun {
+				return errImportRollback
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errImportRollback) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import vehicles"})
+			return
+		}
+	}
+
+	if len(result.Issues) > 0 {
+		if report != "" {
+			writeSpreadsheet(c, http.StatusUnprocessableEntity, report, "import-errors", importReport(rows, result.Issues))
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportVehicles writes the harnesses matching the GET /vehicles filters as
+// CSV or XLSX, in the columns ImportVehicles reads.
+func ExportVehicles(c *gin.Context) {
+	format, ok := spreadsheetFormat(c, "format", spreadsheetCsv)
+	if !ok {
+		return
+	}
+	var vehicles []model.Harness
+	if err := vehicleQuery(c, "").Order("slno, harnessid").Find(&vehicles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicles"})
+		return
+	}
+
+	header := append([]string{"harnessid"}, harnessImportColumns...)
+	rows := [][]string{append(header, "rev")}
+	for _, v := range vehicles {
+		row := []string{strconv.FormatInt(v.HarnessId, 10)}
+		for _, column := range harnessImportColumns {
+			if field := harnessField(&v, column); field != nil {
+				row = append(row, *field)
+			} else {
+				row = append(row, strconv.Itoa(v.CurrentStock))
+			}
+		}
+		rows = append(rows, append(row, strconv.Itoa(v.Rev)))
+	}
+	writeSpreadsheet(c, http.StatusOK, format, "harnesses", rows)
+}
@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type harnessRevisionRequest struct {
+	model.HarnessDesign
+	Notes string `json:"notes"`
+}
+
+type harnessRevisionResponse struct {
+	model.HarnessRevision
+	Stock int `json:"stock"`
+}
+
+type revisionStock struct {
+	Rev   int `gorm:"column:rev" json:"rev"`
+	Stock int `gorm:"column:stock" json:"stock"`
+}
+
+// currentHarnessRevision returns the current revision of a PH code, locked
+// until tx ends.
+func currentHarnessRevision(tx *gorm.DB, phcode string) (model.HarnessRevision, error) {
+	var current model.HarnessRevision
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("phcode = ? AND iscurrent", phcode).First(&current).Error
+	return current, err
+}
+
+// applyHarnessRevision copies the design of rev to every harness with its
+// PH code.
+func applyHarnessRevision(tx *gorm.DB, rev model.HarnessRevision) error {
+	columns := rev.Columns()
+	columns["rev"] = rev.Rev
+	return tx.Model(&model.Harness{}).Where("phcode = ?", rev.PhCode).Updates(columns).Error
+}
+
+// createHarnessRevision adds the revision after current with design and
+// makes it current.
+func createHarnessRevision(tx *gorm.DB, current model.HarnessRevision, design model.HarnessDesign, notes, user string) (model.HarnessRevision, error) {
+	var maxRev int
+	if err := tx.Model(&model.HarnessRevision{}).Where("phcode = ?", current.PhCode).Select("COALESCE(MAX(rev), 0)").Scan(&maxRev).Error; err != nil {
+		return model.HarnessRevision{}, err
+	}
+	if err := tx.Model(&current).Update("iscurrent", false).Error; err != nil {
+		return model.HarnessRevision{}, err
+	}
+	next := model.HarnessRevision{
+		PhCode:        current.PhCode,
+		Rev:           maxRev + 1,
+		IsCurrent:     true,
+		HarnessDesign: design,
+		Notes:         notes,
+		CreatedBy:     user,
+		CreatedAt:     time.Now().Unix(),
+	}
+	if err := tx.Create(&next).Error; err != nil {
+		return model.HarnessRevision{}, err
+	}
+	return next, applyHarnessRevision(tx, next)
+}
+
+// reviseHarnessDesign applies changes to h's design and brings h in line
+// with the revisions of its PH code. Empty fields in changes keep the current
+// revision's values; if the others differ from it, they become a new
+// revision. A PH code without revisions starts at revision 1 with h's design
+// and changes, or after its archived revisions if it had harnesses before.
+func reviseHarnessDesign(tx *gorm.DB, h *model.Harness, changes model.HarnessDesign, notes, user string) error {
+	current, err := currentHarnessRevision(tx, h.PhCode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var maxRev int
+		if err := tx.Model(&model.HarnessRevision{}).Where("phcode = ?", h.PhCode).Select("COALESCE(MAX(rev), 0)").Scan(&maxRev).Error; err != nil {
+			return err
+		}
+		first := model.HarnessRevision{
+			PhCode:        h.PhCode,
+			Rev:           maxRev + 1,
+			IsCurrent:     true,
+			HarnessDesign: changes.Merge(h.Design()),
+			Notes:         notes,
+			CreatedBy:     user,
+			CreatedAt:     time.Now().Unix(),
+		}
+		h.SetDesign(first.HarnessDesign)
+		h.Rev = first.Rev
+		return tx.Create(&first).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	design := changes.Merge(current.HarnessDesign)
+	h.SetDesign(design)
+	h.Rev = current.Rev
+	if design == current.HarnessDesign {
+		return nil
+	}
+	next, err := createHarnessRevision(tx, current, design, notes, user)
+	h.Rev = next.Rev
+	return err
+}
+
+// archiveHarnessRevisions archives the revisions of a PH code whose last
+// harness is gone and returns the attachment files they referenced by kind.
+// Archived revisions stay readable but no longer keep their files.
+func archiveHarnessRevisions(tx *gorm.DB, phcode string) (map[string][]string, error) {
+	var revisions []model.HarnessRevision
+	if err := tx.Where("phcode = ? AND archivedat IS NULL", phcode).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	err := tx.Model(&model.HarnessRevision{}).Where("phcode = ? AND archivedat IS NULL", phcode).
+		Updates(map[string]interface{}{"iscurrent": false, "archivedat": time.Now().Unix()}).Error
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]string{}
+	seen := map[string]bool{}
+	for _, r := range revisions {
+		for _, kind := range []string{model.AttachmentHarnessImage, model.AttachmentDiagram} {
+			name := *r.Attachment(kind)
+			if name != "" && !seen[kind+"/"+name] {
+				seen[kind+"/"+name] = true
+				files[kind] = append(files[kind], name)
+			}
+		}
+	}
+	return files, nil
+}
+
+// revisionStocks returns the stock of each revision held by the harnesses
+// matching where, counting movements up to asOf.
+func revisionStocks(asOf int64, where string, args ...interface{}) ([]revisionStock, error) {
+	stock := []revisionStock{}
+	err := config.DB.Table(`"Harness"."stockmovements" m`).
+		Select("m.rev, COALESCE(SUM(m.quantity), 0) AS stock").
+		Joins(`JOIN "Harness"."harness" h ON h.harnessid = m.harnessid`).
+		Where(where, args...).Where("m.createdat <= ?", asOf).
+		Group("m.rev").Order("m.rev").Scan(&stock).Error
+	return stock, err
+}
+
+func harnessRevisionParams(c *gin.Context) (string, int, bool) {
+	phcode := c.Param("phcode")
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Revision must be numeric"})
+		return phcode, 0, false
+	}
+	return phcode, rev, true
+}
+
+func GetHarnessRevisions(c *gin.Context) {
+	phcode := c.Param("phcode")
+	var revisions []model.HarnessRevision
+	if err := config.DB.Where("phcode = ?", phcode).Order("rev DESC").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	if len(revisions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Harness " + phcode + " has no revisions"})
+		return
+	}
+	stock, err := revisionStocks(time.Now().Unix(), "h.phcode = ?", phcode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	byRev := map[int]int{}
+	for _, s := range stock {
+		byRev[s.Rev] = s.Stock
+	}
+
+	responses := make([]harnessRevisionResponse, 0, len(revisions))
+	for _, rev := range revisions {
+		responses = append(responses, harnessRevisionResponse{HarnessRevision: rev, Stock: byRev[rev.Rev]})
+	}
+	respondList(c, responses)
+}
+
+func GetHarnessRevision(c *gin.Context) {
+	phcode, rev, ok := harnessRevisionParams(c)
+	if !ok {
+		return
+	}
+	var revision model.HarnessRevision
+	if err := config.DB.Where("phcode = ? AND rev = ?", phcode, rev).First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	stock, err := revisionStocks(time.Now().Unix(), "h.phcode = ? AND m.rev = ?", phcode, rev)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	response := harnessRevisionResponse{HarnessRevision: revision}
+	if len(stock) > 0 {
+		response.Stock = stock[0].Stock
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateHarnessRevision snapshots a new design for a PH code and applies it
+// to its harnesses. Fields left empty keep the current revision's values.
+func CreateHarnessRevision(c *gin.Context) {
+	phcode := c.Param("phcode")
+	var req harnessRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var next model.HarnessRevision
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := currentHarnessRevision(tx, phcode)
+		if err != nil {
+			return err
+		}
+		design := req.HarnessDesign.Merge(current.HarnessDesign)
+		if design == current.HarnessDesign {
+			return &settingsConflictError{"Design is unchanged from revision " + strconv.Itoa(current.Rev)}
+		}
+		next, err = createHarnessRevision(tx, current, design, strings.TrimSpace(req.Notes), c.GetString("email"))
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Harness " + phcode + " has no revisions"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to create revision")
+		return
+	}
+	c.JSON(http.StatusCreated, harnessRevisionResponse{HarnessRevision: next})
+}
+
+// SetCurrentHarnessRevision makes an earlier or later revision current again
+// and applies its design to the harnesses of the PH code. Archived revisions
+// may have lost their attachments and cannot be made current.
+func SetCurrentHarnessRevision(c *gin.Context) {
+	phcode, rev, ok := harnessRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	var target model.HarnessRevision
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := currentHarnessRevision(tx, phcode)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("phcode = ? AND rev = ?", phcode, rev).First(&target).Error; err != nil {
+			return err
+		}
+		if target.ID == current.ID {
+			return nil
+		}
+		if target.ArchivedAt != nil {
+			return &settingsConflictError{"Revision " + strconv.Itoa(rev) + " is archived"}
+		}
+		if err := tx.Model(&current).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+		target.IsCurrent = true
+		if err := tx.Model(&target).Update("iscurrent", true).Error; err != nil {
+			return err
+		}
+		return applyHarnessRevision(tx, target)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to set current revision")
+		return
+	}
+	c.JSON(http.StatusOK, harnessRevisionResponse{HarnessRevision: target})
+}
+
+// DiffHarnessRevisions compares the designs of the from and to revisions of
+// a PH code. To defaults to the current revision.
+func DiffHarnessRevisions(c *gin.Context) {
+	phcode := c.Param("phcode")
+	from := diffSide{FileName: phcode, Version: c.Query("from")}
+	to := diffSide{FileName: phcode, Version: c.Query("to")}
+	if from.Version == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "From revision is required"})
+		return
+	}
+
+	docs := make([]interface{}, 2)
+	for i, side := range []*diffSide{&from, &to} {
+		query := config.DB.Where("phcode = ?", phcode)
+		if side.Version == "" {
+			query = query.Where("iscurrent")
+		} else {
+			rev, err := strconv.Atoi(side.Version)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Revision must be numeric"})
+				return
+			}
+			query = query.Where("rev = ?", rev)
+		}
+		var revision model.HarnessRevision
+		if err := query.First(&revision).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision " + side.label() + " not found"})
+			return
+		}
+		side.Version = strconv.Itoa(revision.Rev)
+
+		var err error
+		if docs[i], err = genericDocument(revision.HarnessDesign); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare revisions"})
+			return
+		}
+	}
+
+	respondDiff(c, from, to, docs[0], docs[1])
+}
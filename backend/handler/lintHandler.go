@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/lint"
+	"filepackage/model"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type lintReport struct {
+	GroupId  int            `json:"groupid"`
+	Findings []lint.Finding `json:"findings"`
+}
+
+type lintRuleConfigRequest struct {
+	Enabled  *bool                  `json:"enabled"`
+	Severity string                 `json:"severity"`
+	Params   map[string]interface{} `json:"params"`
+}
+
+type lintRuleResponse struct {
+	lint.Rule
+	Enabled bool `json:"enabled"`
+}
+
+// lintConfigs returns the rule overrides for a group. A group's own row
+// replaces the row shared by all groups (group 0).
+func lintConfigs(groupId int) (map[string]lint.Config, error) {
+	var rows []model.LintRuleConfig
+	if err := config.DB.Where("groupid IN ?", []int{0, groupId}).Order("groupid").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	configs := map[string]lint.Config{}
+	for _, row := range rows {
+		enabled := row.Enabled
+		configs[row.RuleId] = lint.Config{Enabled: &enabled, Severity: lint.Severity(row.Severity), Params: row.Params}
+	}
+	return configs, nil
+}
+
+// lintGroups returns the requested group, or the groups of the packages
+// using the file, or 0 when no package uses it.
+func lintGroups(groupParam string, usages []packageUsage) ([]int, error) {
+	if groupParam != "" {
+		groupId, err := strconv.Atoi(groupParam)
+		if err != nil {
+			return nil, &settingsRequestError{"Group id must be a number"}
+		}
+		return []int{groupId}, nil
+	}
+
+	seen := map[int]bool{}
+	groups := []int{}
+	for _, u := range usages {
+		if !seen[u.Groupid] {
+			seen[u.Groupid] = true
+			groups = append(groups, u.Groupid)
+		}
+	}
+	sort.Ints(groups)
+	if len(groups) == 0 {
+		groups = []int{0}
+	}
+	return groups, nil
+}
+
+func lintFile(c *gin.Context, kind, fileName string, target lint.Target) ([]lintReport, error) {
+	usages := canSettingsUsages
+	if kind == settingsTypeNrf {
+		usages = nrfSettingsUsages
+	}
+	var list []packageUsage
+	if c.Query("groupid") == "" {
+		var err error
+		if list, err = usages(fileName); err != nil {
+			return nil, err
+		}
+	}
+	groups, err := lintGroups(c.Query("groupid"), list)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]lintReport, 0, len(groups))
+	for _, groupId := range groups {
+		configs, err := lintConfigs(groupId)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, lintReport{GroupId: groupId, Findings: lint.Run(kind, target, configs)})
+	}
+	return reports, nil
+}
+
+func lintHasErrors(reports []lintReport) bool {
+	for _, r := range reports {
+		if lint.HasErrors(r.Findings) {
+			return true
+		}
+	}
+	return false
+}
+
+func addLintReports(response gin.H, reports []lintReport) {
+	for _, r := range reports {
+		if len(r.Findings) > 0 {
+			response["lint"] = reports
+			return
+		}
+	}
+}
+
+// pairedCanSignals returns the signal names of the CAN settings packaged
+// together with the NRF file.
+func pairedCanSignals(fileName string) (map[string]bool, error) {
+	var pkgs []model.FilePackage
+	err := config.DB.Select("mainsettingsname", "mainsettingsversion").Where("coprocsettingsname = ? AND mainsettingsname <> ''", fileName).Find(&pkgs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	signals := map[string]bool{}
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		version := ""
+		if pkg.Mainsettingsversion > 0 {
+			version = strconv.Itoa(pkg.Mainsettingsversion)
+		}
+		key := pkg.Mainsettingsname + "@" + version
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		canSettings, err := findCanSettings(pkg.Mainsettingsname, version)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if canSettings.JSONData == nil {
+			continue
+		}
+		messages, _ := canSettings.JSONData.CanMessages()
+		for _, m := range messages {
+			for _, s := range m.Message.Signals {
+				signals[s.Name] = true
+			}
+		}
+	}
+	return signals, nil
+}
+
+func nrfLintTarget(nrfSettings model.NrfSettings) (lint.Target, error) {
+	doc := nrfSettings.Document()
+	signals, err := pairedCanSignals(nrfSettings.FileName)
+	return lint.Target{Nrf: &doc, Signals: signals}, err
+}
+
+type lintFailedError struct {
+	Reports []lintReport
+}
+
+func (e *lintFailedError) Error() string {
+	return "settings failed lint checks"
+}
+
+// lintSettings lints a document about to be saved. Error findings reject the
+// document unless force=true is given.
+func lintSettings(c *gin.Context, kind, fileName string, target lint.Target) ([]lintReport, error) {
+	reports, err := lintFile(c, kind, fileName, target)
+	if err != nil {
+		return nil, err
+	}
+	if lintHasErrors(reports) && c.Query("force") != "true" {
+		return nil, &lintFailedError{Reports: reports}
+	}
+	return reports, nil
+}
+
+func lintUpload(c *gin.Context, kind, fileName string, target lint.Target) ([]lintReport, bool) {
+	reports, err := lintSettings(c, kind, fileName, target)
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to lint settings")
+		return nil, false
+	}
+	return reports, true
+}
+
+func LintCanSettings(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	canSettings, err := findCanSettings(fileName, c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Can settings not found"})
+		return
+	}
+
+	reports, err := lintFile(c, settingsTypeCan, fileName, lint.Target{Can: canSettings.JSONData})
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to lint can settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"filename": fileName, "version": canSettings.Version, "valid": !lintHasErrors(reports), "reports": reports})
+}
+
+func LintNrfSettings(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	nrfSettings, err := findNrfSettings(fileName, c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+
+	target, err := nrfLintTarget(nrfSettings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lint nrf settings"})
+		return
+	}
+	reports, err := lintFile(c, settingsTypeNrf, fileName, target)
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to lint nrf settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"filename": fileName, "version": nrfSettings.Version, "valid": !lintHasErrors(reports), "reports": reports})
+}
+
+func GetLintRules(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && kind != settingsTypeCan && kind != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be can or nrf"})
+		return
+	}
+	groupId, err := strconv.Atoi(c.DefaultQuery("groupid", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be a number"})
+		return
+	}
+
+	configs, err := lintConfigs(groupId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lint rule configuration"})
+		return
+	}
+
+	rules := []lintRuleResponse{}
+	for _, rule := range lint.Rules(kind) {
+		resp := lintRuleResponse{Rule: rule, Enabled: true}
+		if cfg, ok := configs[rule.ID]; ok {
+			resp.Enabled = cfg.Enabled == nil || *cfg.Enabled
+			if cfg.Severity != "" {
+				resp.Severity = cfg.Severity
+			}
+			if len(cfg.Params) > 0 {
+				params := lint.Params{}
+				for k, v := range rule.Params {
+					params[k] = v
+				}
+				for k, v := range cfg.Params {
+					params[k] = v
+				}
+				resp.Params = params
+			}
+		}
+		rules = append(rules, resp)
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func GetLintRuleConfigs(c *gin.Context) {
+	query := config.DB.Order("groupid, ruleid")
+	if groupParam := c.Query("groupid"); groupParam != "" {
+		groupId, err := strconv.Atoi(groupParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be a number"})
+			return
+		}
+		query = query.Where("groupid = ?", groupId)
+	}
+
+	var rows []model.LintRuleConfig
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lint rule configuration"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func SaveLintRuleConfig(c *gin.Context) {
+	groupId, err := strconv.Atoi(c.Param("groupid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be a number"})
+		return
+	}
+	ruleId := c.Param("ruleid")
+	rule, ok := lint.Lookup(ruleId)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lint rule not found"})
+		return
+	}
+
+	var req lintRuleConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Severity != "" && !lint.ValidSeverity(lint.Severity(req.Severity)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be error, warning or info"})
+		return
+	}
+	for k := range req.Params {
+		if _, ok := rule.Params[k]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown parameter " + k + " for rule " + ruleId})
+			return
+		}
+	}
+
+	row := model.LintRuleConfig{
+		GroupId:   groupId,
+		RuleId:    ruleId,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		Severity:  req.Severity,
+		Params:    req.Params,
+		UpdatedBy: c.GetString("email"),
+		UpdatedAt: time.Now().Unix(),
+	}
+	err = config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "groupid"}, {Name: "ruleid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "severity", "params", "updatedby", "updatedat"}),
+	}).Create(&row).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save lint rule configuration"})
+		return
+	}
+	c.JSON(http.StatusOK, row)
+}
+
+func DeleteLintRuleConfig(c *gin.Context) {
+	groupId, err := strconv.Atoi(c.Param("groupid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group id must be a number"})
+		return
+	}
+
+	result := config.DB.Where("groupid = ? AND ruleid = ?", groupId, c.Param("ruleid")).Delete(&model.LintRuleConfig{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete lint rule configuration"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lint rule configuration not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lint rule configuration deleted"})
+}
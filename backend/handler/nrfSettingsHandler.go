@@ -2,14 +2,192 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"filepackage/config"
 	"filepackage/model"
+	"filepackage/utils"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type nrfSettingsRequest struct {
+	FileName  string          `json:"filename"`
+	FileType  *int            `json:"filetype"`
+	JSONData  json.RawMessage `json:"jsondata"`
+	SleepCdns json.RawMessage `json:"sleepcdns"`
+}
+
+func findNrfSettings(fileName, version string) (model.NrfSettings, error) {
+	var nrfSettings model.NrfSettings
+	query := config.DB.Where("filename = ?", fileName)
+	if version != "" {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nrfSettings, fmt.Errorf("invalid version %q", version)
+		}
+		query = query.Where("version = ?", v)
+	} else {
+		query = query.Where("iscurrent")
+	}
+	err := query.First(&nrfSettings).Error
+	return nrfSettings, err
+}
+
+// applyNrfRequest decodes the jsondata and sleepcdns members of a request
+// into next. Sleep conditions may also be sent inside jsondata; they are
+// checked before decoding so that their issues are reported in full.
+func applyNrfRequest(next *model.NrfSettings, jsonData, sleepCdns json.RawMessage) error {
+	if len(jsonData) > 0 {
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(jsonData, &members); err != nil || members == nil {
+			return &settingsRequestError{"JSON data must be an object"}
+		}
+		if sleep, ok := members["sleepcdns"]; ok {
+			if len(sleepCdns) == 0 {
+				sleepCdns = sleep
+			}
+			delete(members, "sleepcdns")
+			jsonData, _ = json.Marshal(members)
+		}
+	}
+	if len(sleepCdns) > 0 {
+		if issues := validateSleepConditions(sleepCdns); len(issues) > 0 {
+			return &sleepConditionError{Issues: issues}
+		}
+	}
+
+	if len(jsonData) > 0 {
+		var doc model.NrfSettingsData
+		if err := json.Unmarshal(jsonData, &doc); err != nil {
+			return &settingsRequestError{"Invalid JSON data: " + err.Error()}
+		}
+		next.JSONData = &doc
+	}
+	if len(sleepCdns) > 0 {
+		var conditions model.SleepConditions
+		if err := json.Unmarshal(sleepCdns, &conditions); err != nil {
+			return &settingsRequestError{"Invalid sleep conditions: " + err.Error()}
+		}
+		next.SleepCdns = conditions
+	}
+	return nil
+}
+
+// saveNrfSettingsVersion stores next as the new current version of its file
+// unless it is identical to the current one.
+func saveNrfSettingsVersion(next model.NrfSettings, user string) (model.NrfSettings, bool, error) {
+	if next.JSONData == nil {
+		return next, false, &settingsRequestError{"JSON data must be an object"}
+	}
+	jsonData, err := json.Marshal(next.JSONData)
+	if err != nil {
+		return next, false, err
+	}
+	sleepCdns, err := json.Marshal(next.SleepCdns)
+	if err != nil {
+		return next, false, err
+	}
+	if issues := validateSleepConditions(sleepCdns); len(issues) > 0 {
+		return next, false, &sleepConditionError{Issues: issues}
+	}
+	var count int64
+	if err := config.DB.Model(&model.NrfFileType{}).Where("id = ?", next.FileType).Count(&count).Error; err != nil {
+		return next, false, err
+	}
+	if count == 0 {
+		return next, false, &settingsRequestError{"Unknown file type " + strconv.Itoa(next.FileType)}
+	}
+
+	merged, err := json.Marshal(next.Document())
+	if err != nil {
+		return next, false, err
+	}
+	schemaId, err := validateSettings(settingsTypeNrf, &next.FileType, merged)
+	if err != nil {
+		return next, false, err
+	}
+
+	hash := utils.NrfContentHash(jsonData, sleepCdns)
+	var saved model.NrfSettings
+	created := false
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var current model.NrfSettings
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("filename = ? AND iscurrent", next.FileName).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && current.ContentHash == hash && current.FileType == next.FileType {
+			saved = current
+			return nil
+		}
+
+		// Retired versions keep their numbers, so a file created again
+		// continues after them.
+		var maxVersion int
+		if err := tx.Unscoped().Model(&model.NrfSettings{}).Where("filename = ?", next.FileName).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NrfSettings{}).Where("filename = ? AND iscurrent", next.FileName).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().Unix()
+		saved = model.NrfSettings{
+			FileName:    next.FileName,
+			FileType:    next.FileType,
+			Version:     maxVersion + 1,
+			ContentHash: hash,
+			IsCurrent:   true,
+			JSONData:    next.JSONData,
+			SleepCdns:   next.SleepCdns,
+			SchemaId:    schemaId,
+			CreatedBy:   user,
+			UpdatedBy:   user,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		created = true
+		return tx.Create(&saved).Error
+	})
+	return saved, created, err
+}
+
+// lintNrfUpload lints next before it is saved; see lintUpload.
+func lintNrfUpload(c *gin.Context, next model.NrfSettings) ([]lintReport, bool) {
+	target, err := nrfLintTarget(next)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lint nrf settings"})
+		return nil, false
+	}
+	return lintUpload(c, settingsTypeNrf, next.FileName, target)
+}
+
+func respondNrfSaved(c *gin.Context, nrfSettings model.NrfSettings, created bool, reports []lintReport) {
+	if !created {
+		response := gin.H{"message": "Nrf settings " + nrfSettings.FileName + " unchanged", "version": nrfSettings.Version}
+		addLintReports(response, reports)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	status := http.StatusOK
+	if nrfSettings.Version == 1 {
+		status = http.StatusCreated
+	}
+	response := gin.H{"message": "Nrf settings " + nrfSettings.FileName + " saved as version " + strconv.Itoa(nrfSettings.Version), "version": nrfSettings.Version}
+	addLintReports(response, reports)
+	if warnings := usageWarnings(nrfSettingsUsages, nrfSettings.FileName); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(status, response)
+}
+
 func GetNrfSettingsByFileName(c *gin.Context) {
 	fileName := c.Param("filename")
 	fileName = strings.TrimSpace(fileName)
@@ -18,30 +196,22 @@ func GetNrfSettingsByFileName(c *gin.Context) {
 		return
 	}
 
-	var nrfSettings model.NrfSettings
-	if err := config.DB.Where("filename = ?", fileName).First(&nrfSettings).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
-		return
+	version := c.Param("version")
+	if version == "" {
+		version = c.Query("version")
 	}
-
-	var response map[string]interface{}
-	if err := json.Unmarshal(nrfSettings.JSONData, &response); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse JSON data"})
+	nrfSettings, err := findNrfSettings(fileName, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
 		return
 	}
 
-	var sleepCnds map[string]interface{}
-	if err := json.Unmarshal(nrfSettings.SleepCdns, &sleepCnds); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse SleepCnds"})
-		return
-	}
-	response["sleepcdns"] = sleepCnds
-	c.JSON(http.StatusOK, response)
+	respondMappedSettings(c, settingsTypeNrf, nrfSettings.Document())
 }
 
 func GetAllNrfFileNames(c *gin.Context) {
 	var nrfSettings []model.NrfSettings
-	if err := config.DB.Select("filename").Find(&nrfSettings).Error; err != nil {
+	if err := config.DB.Select("filename").Where("iscurrent").Find(&nrfSettings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file names"})
 		return
 	}
@@ -52,3 +222,284 @@ func GetAllNrfFileNames(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, fileNames)
 }
+
+func CreateNrfSettings(c *gin.Context) {
+	var req nrfSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.FileName = strings.TrimSpace(req.FileName)
+	if req.FileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+	if req.FileType == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File type is required"})
+		return
+	}
+	if len(req.JSONData) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON data is required"})
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&model.NrfSettings{}).Where("filename = ?", req.FileName).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save nrf settings"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nrf settings " + req.FileName + " already exists"})
+		return
+	}
+
+	next := model.NrfSettings{FileName: req.FileName, FileType: *req.FileType}
+	if err := applyNrfRequest(&next, req.JSONData, req.SleepCdns); err != nil {
+		respondSettingsWriteError(c, err, "Failed to save nrf settings")
+		return
+	}
+	if next.SleepCdns == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sleep conditions are required"})
+		return
+	}
+	reports, ok := lintNrfUpload(c, next)
+	if !ok {
+		return
+	}
+	nrfSettings, created, err := saveNrfSettingsVersion(next, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to save nrf settings")
+		return
+	}
+	respondNrfSaved(c, nrfSettings, created, reports)
+}
+
+func UpdateNrfSettings(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	var req nrfSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := findNrfSettings(fileName, "")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+
+	next := current
+	if req.FileType != nil {
+		next.FileType = *req.FileType
+	}
+	if err := applyNrfRequest(&next, req.JSONData, req.SleepCdns); err != nil {
+		respondSettingsWriteError(c, err, "Failed to save nrf settings")
+		return
+	}
+
+	reports, ok := lintNrfUpload(c, next)
+	if !ok {
+		return
+	}
+	nrfSettings, created, err := saveNrfSettingsVersion(next, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to save nrf settings")
+		return
+	}
+	respondNrfSaved(c, nrfSettings, created, reports)
+}
+
+func GetNrfSleepConditions(c *gin.Context) {
+	nrfSettings, err := findNrfSettings(strings.TrimSpace(c.Param("filename")), c.Query("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+	c.JSON(http.StatusOK, nrfSettings.SleepCdns)
+}
+
+func UpdateNrfSleepConditions(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	sleepCdns, err := c.GetRawData()
+	if err != nil || !json.Valid(sleepCdns) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid JSON sleep conditions are required"})
+		return
+	}
+
+	current, err := findNrfSettings(fileName, "")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+
+	next := current
+	if err := applyNrfRequest(&next, nil, sleepCdns); err != nil {
+		respondSettingsWriteError(c, err, "Failed to save sleep conditions")
+		return
+	}
+	reports, ok := lintNrfUpload(c, next)
+	if !ok {
+		return
+	}
+	nrfSettings, created, err := saveNrfSettingsVersion(next, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to save sleep conditions")
+		return
+	}
+	respondNrfSaved(c, nrfSettings, created, reports)
+}
+
+// DeleteNrfSettings retires every version of an NRF settings file. Retired
+// versions stay in the table for the packages and history that refer to them
+// but are no longer served; creating the file again starts a new current
+// version after them.
+func DeleteNrfSettings(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	if fileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+
+	usages, err := nrfSettingsUsages(fileName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check package usage"})
+		return
+	}
+	var released []packageUsage
+	for _, u := range usages {
+		if u.Released {
+			released = append(released, u)
+		}
+	}
+	if len(released) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nrf settings " + fileName + " are used by released packages", "packages": released})
+		return
+	}
+
+	var retired int64
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.NrfSettings{}).Where("filename = ? AND iscurrent", fileName).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+		result := tx.Where("filename = ?", fileName).Delete(&model.NrfSettings{})
+		retired = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete nrf settings"})
+		return
+	}
+	if retired == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+	response := gin.H{"message": "Nrf settings " + fileName + " retired", "versions": retired}
+	if len(usages) > 0 {
+		var warnings []string
+		for _, u := range usages {
+			warnings = append(warnings, "Package "+u.Filepackagecode+" still references "+fileName)
+		}
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetNrfSettingsVersions(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	if fileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+
+	var versions []model.NrfSettings
+	result := config.DB.Omit("jsondata", "sleepcdns").Where("filename = ?", fileName).Order("version DESC").Find(&versions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nrf settings versions"})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func SetCurrentNrfSettingsVersion(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	version, err := strconv.Atoi(c.Param("version"))
+	if fileName == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name and a numeric version are required"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var target model.NrfSettings
+		if err := tx.Omit("jsondata", "sleepcdns").Where("filename = ? AND version = ?", fileName, version).First(&target).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NrfSettings{}).Where("filename = ? AND iscurrent", fileName).Update("iscurrent", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.NrfSettings{}).Where("filename = ? AND version = ?", fileName, version).
+			Updates(map[string]interface{}{"iscurrent": true, "updatedby": c.GetString("email"), "updatedat": time.Now().Unix()}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nrf settings version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set current version"})
+		return
+	}
+
+	response := gin.H{"message": "Nrf settings " + fileName + " version " + strconv.Itoa(version) + " is now current"}
+	if warnings := usageWarnings(nrfSettingsUsages, fileName); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetNrfFileTypes(c *gin.Context) {
+	fileTypes := []model.NrfFileType{}
+	if err := config.DB.Order("id").Find(&fileTypes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file types"})
+		return
+	}
+	c.JSON(http.StatusOK, fileTypes)
+}
+
+func CreateNrfFileType(c *gin.Context) {
+	var req struct {
+		ID          *int   `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.ID == nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File type id and name are required"})
+		return
+	}
+	fileType := model.NrfFileType{ID: *req.ID, Name: req.Name, Description: req.Description}
+
+	var count int64
+	if err := config.DB.Model(&model.NrfFileType{}).Where("id = ? OR name = ?", fileType.ID, fileType.Name).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file type"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "File type " + strconv.Itoa(fileType.ID) + " or name " + fileType.Name + " already exists"})
+		return
+	}
+
+	if err := config.DB.Create(&fileType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file type"})
+		return
+	}
+	c.JSON(http.StatusCreated, fileType)
+}
@@ -1,14 +1,73 @@
 package handler
 
 import (
+	"errors"
 	"filepackage/config"
 	"filepackage/model"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type packageResponse struct {
+	model.FilePackage
+	Descriptor *model.ModelDescriptor `json:"descriptor,omitempty"`
+}
+
+// toPackageResponses attaches the model descriptor of each package, taken
+// from its group model or, for models no longer listed, parsed from its
+// model name.
+func toPackageResponses(pkgs []model.FilePackage) ([]packageResponse, error) {
+	ids := []int{}
+	for _, pkg := range pkgs {
+		if pkg.Modelid > 0 {
+			ids = append(ids, pkg.Modelid)
+		}
+	}
+	var models []model.GroupModels
+	if len(ids) > 0 {
+		if err := config.DB.Where("modelid IN ?", ids).Find(&models).Error; err != nil {
+			return nil, err
+		}
+	}
+	byId := make(map[int]model.ModelDescriptor, len(models))
+	for _, m := range models {
+		byId[m.ModelId] = m.Descriptor()
+	}
+
+	responses := make([]packageResponse, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		resp := packageResponse{FilePackage: pkg}
+		if d, ok := byId[pkg.Modelid]; ok {
+			resp.Descriptor = &d
+		} else if d, err := model.ParseModelName(pkg.Modelname); err == nil {
+			resp.Descriptor = &d
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// resolvePackageModel sets the canonical name of the package's model.
+func resolvePackageModel(pkg *model.FilePackage) error {
+	if pkg.Modelid <= 0 {
+		return nil
+	}
+	var groupModel model.GroupModels
+	err := config.DB.Where("modelid = ?", pkg.Modelid).First(&groupModel).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pkg.Modelname = groupModel.Descriptor().String()
+	return nil
+}
+
 func CreatePackage(c *gin.Context) {
 	var pkg model.FilePackage
 	if err := c.ShouldBindJSON(&pkg); err != nil {
@@ -16,6 +75,15 @@ func CreatePackage(c *gin.Context) {
 		return
 	}
 
+	if err := resolvePinnedSettings(&pkg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := resolvePackageModel(&pkg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create package"})
+		return
+	}
+
 	if err := config.DB.Create(&pkg).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create package"})
 		return
@@ -40,8 +108,12 @@ func GetPackage(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, pkg)
-
+	responses, err := toPackageResponses([]model.FilePackage{pkg})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch package"})
+		return
+	}
+	c.JSON(http.StatusOK, responses[0])
 }
 
 func GetAllPackages(c *gin.Context) {
@@ -52,7 +124,12 @@ func GetAllPackages(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
 		return
 	}
-	c.JSON(http.StatusOK, pkgs)
+	responses, err := toPackageResponses(pkgs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+		return
+	}
+	c.JSON(http.StatusOK, responses)
 }
 
 func UpdatePackage(c *gin.Context) {
@@ -76,6 +153,15 @@ func UpdatePackage(c *gin.Context) {
 		return
 	}
 
+	if err := resolvePinnedSettings(&updatedPkg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := resolvePackageModel(&updatedPkg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update package"})
+		return
+	}
+
 	dbResult := config.DB.Model(&pkg).Where("filepackagecode = ?", fpcode).Omit("filepackagecode").Select("*").Updates(updatedPkg)
 	if dbResult.Error != nil {
 		fmt.Println("Error updating package:", dbResult.Error)
@@ -109,3 +195,23 @@ func DeletePackage(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Package " + fpcode + " deleted successfully"})
 }
+
+func resolvePinnedSettings(pkg *model.FilePackage) error {
+	if pkg.Coprocsettingsversion <= 0 {
+		pkg.Coprocsettingsversion = 0
+	} else if _, err := findNrfSettings(pkg.Coprocsettingsname, strconv.Itoa(pkg.Coprocsettingsversion)); err != nil {
+		return fmt.Errorf("nrf settings %s version %d not found", pkg.Coprocsettingsname, pkg.Coprocsettingsversion)
+	}
+
+	if pkg.Mainsettingsversion <= 0 {
+		pkg.Mainsettingsversion = 0
+		return nil
+	}
+
+	canSettings, err := findCanSettings(pkg.Mainsettingsname, strconv.Itoa(pkg.Mainsettingsversion))
+	if err != nil {
+		return fmt.Errorf("can settings %s version %d not found", pkg.Mainsettingsname, pkg.Mainsettingsversion)
+	}
+	pkg.Mainsettingsid = canSettings.FileId
+	return nil
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func paginationParams(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pagesize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// respondList writes items as a plain array, or as one page of them when the
+// request asks for a page or page size.
+func respondList[T any](c *gin.Context, items []T) {
+	_, hasPage := c.GetQuery("page")
+	_, hasSize := c.GetQuery("pagesize")
+	if !hasPage && !hasSize {
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	page, pageSize := paginationParams(c)
+	total := len(items)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "pagesize": pageSize, "total": total, "results": items[start:end]})
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"filepackage/config"
+	"filepackage/jsonpath"
+	"filepackage/model"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	settingsTypeCan = "can"
+	settingsTypeNrf = "nrf"
+)
+
+type settingsQueryRequest struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`
+	Limit int    `json:"limit"`
+}
+
+type settingsQueryResult struct {
+	Type     string        `json:"type"`
+	FileName string        `json:"filename"`
+	Version  int           `json:"version,omitempty"`
+	Values   []interface{} `json:"values"`
+}
+
+func QuerySettings(c *gin.Context) {
+	var req settingsQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type != "" && req.Type != settingsTypeCan && req.Type != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be can or nrf"})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+
+	path, err := jsonpath.Compile(req.Path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pgPath, pushdown := path.Postgres()
+
+	results := []settingsQueryResult{}
+	if req.Type == "" || req.Type == settingsTypeCan {
+		rows, pushed, err := queryCanSettings(pgPath, pushdown)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query can settings"})
+			return
+		}
+		pushdown = pushdown && pushed
+		for _, row := range rows {
+			doc, err := genericDocument(row.JSONData)
+			if err != nil {
+				continue
+			}
+			if values := path.Eval(doc); len(values) > 0 {
+				results = append(results, settingsQueryResult{Type: settingsTypeCan, FileName: row.FileName, Version: row.Version, Values: values})
+			}
+		}
+	}
+
+	if req.Type == "" || req.Type == settingsTypeNrf {
+		rows, pushed, err := queryNrfSettings(pgPath, pushdown)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query nrf settings"})
+			return
+		}
+		pushdown = pushdown && pushed
+		for _, row := range rows {
+			doc, err := genericDocument(row.Document())
+			if err != nil {
+				continue
+			}
+			if values := path.Eval(doc); len(values) > 0 {
+				results = append(results, settingsQueryResult{Type: settingsTypeNrf, FileName: row.FileName, Version: row.Version, Values: values})
+			}
+		}
+	}
+
+	total := len(results)
+	if len(results) > req.Limit {
+		results = results[:req.Limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path.String(), "pushdown": pushdown, "total": total, "results": results})
+}
+
+func queryCanSettings(pgPath string, pushdown bool) ([]model.CanSettings, bool, error) {
+	var rows []model.CanSettings
+	if pushdown {
+		err := config.DB.Where("iscurrent").Where("jsonb_path_exists(jsondata::jsonb, ?::text::jsonpath)", pgPath).Order("filename").Find(&rows).Error
+		if err == nil {
+			return rows, true, nil
+		}
+		log.Printf("Falling back to in-memory settings query: %v", err)
+	}
+	err := config.DB.Where("iscurrent").Order("filename").Find(&rows).Error
+	return rows, false, err
+}
+
+func queryNrfSettings(pgPath string, pushdown bool) ([]model.NrfSettings, bool, error) {
+	var rows []model.NrfSettings
+	if pushdown {
+		err := config.DB.Where("iscurrent").Where("jsonb_path_exists(jsondata::jsonb || jsonb_build_object('sleepcdns', sleepcdns::jsonb), ?::text::jsonpath)", pgPath).Order("filename").Find(&rows).Error
+		if err == nil {
+			return rows, true, nil
+		}
+		log.Printf("Falling back to in-memory settings query: %v", err)
+	}
+	err := config.DB.Where("iscurrent").Order("filename").Find(&rows).Error
+	return rows, false, err
+}
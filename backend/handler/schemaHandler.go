@@ -0,0 +1,394 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"filepackage/schemas"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type schemaValidationError struct {
+	SchemaId int
+	Issues   []schemas.Issue
+}
+
+func (e *schemaValidationError) Error() string {
+	return "settings do not match schema " + strconv.Itoa(e.SchemaId)
+}
+
+type settingsSchemaRequest struct {
+	SettingsType string          `json:"settingstype"`
+	FileType     *int            `json:"filetype"`
+	Schema       json.RawMessage `json:"schema"`
+	Description  string          `json:"description"`
+	CreatedBy    string          `json:"createdby"`
+}
+
+type settingsSchemaResponse struct {
+	model.SettingsSchema
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type schemaReportEntry struct {
+	Type          string          `json:"type"`
+	FileName      string          `json:"filename"`
+	Version       int             `json:"version,omitempty"`
+	SchemaId      int             `json:"schemaid"`
+	SchemaVersion int             `json:"schemaversion"`
+	Valid         bool            `json:"valid"`
+	Issues        []schemas.Issue `json:"issues,omitempty"`
+}
+
+// rawSettingsRow reads settings columns without decoding them so that the
+// report can include documents that no longer parse.
+type rawSettingsRow struct {
+	FileName  string `gorm:"column:filename"`
+	FileType  int    `gorm:"column:filetype"`
+	Version   int    `gorm:"column:version"`
+	SchemaId  int    `gorm:"column:schemaid"`
+	JSONData  []byte `gorm:"column:jsondata"`
+	SleepCdns []byte `gorm:"column:sleepcdns"`
+}
+
+func toSchemaResponse(s model.SettingsSchema) settingsSchemaResponse {
+	resp := settingsSchemaResponse{SettingsSchema: s}
+	if len(s.Schema) > 0 {
+		resp.Schema = s.Schema
+	}
+	return resp
+}
+
+func schemaScope(db *gorm.DB, settingsType string, fileType *int) *gorm.DB {
+	db = db.Where("settingstype = ?", settingsType)
+	if fileType == nil {
+		return db.Where("filetype IS NULL")
+	}
+	return db.Where("filetype = ?", *fileType)
+}
+
+func activeSchema(settingsType string, fileType *int) (*model.SettingsSchema, error) {
+	var schema model.SettingsSchema
+	if fileType != nil {
+		err := schemaScope(config.DB, settingsType, fileType).Where("isactive").First(&schema).Error
+		if err == nil {
+			return &schema, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	err := schemaScope(config.DB, settingsType, nil).Where("isactive").First(&schema).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func loadSchema(id int) (*model.SettingsSchema, error) {
+	var schema model.SettingsSchema
+	if err := config.DB.First(&schema, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func validateWithSchema(schema *model.SettingsSchema, doc []byte) ([]schemas.Issue, error) {
+	compiled, err := schemas.Compile(schema.ID, schema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return schemas.Validate(compiled, doc)
+}
+
+// validateSettings checks doc against the active schema for the settings type
+// and returns the id of the schema it was validated with, or 0 if none exists.
+func validateSettings(settingsType string, fileType *int, doc []byte) (int, error) {
+	schema, err := activeSchema(settingsType, fileType)
+	if err != nil || schema == nil {
+		return 0, err
+	}
+
+	issues, err := validateWithSchema(schema, doc)
+	if err != nil {
+		return 0, err
+	}
+	if len(issues) > 0 {
+		return 0, &schemaValidationError{SchemaId: schema.ID, Issues: issues}
+	}
+	return schema.ID, nil
+}
+
+type settingsRequestError struct {
+	message string
+}
+
+func (e *settingsRequestError) Error() string {
+	return e.message
+}
+
+type settingsConflictError struct {
+	message string
+}
+
+func (e *settingsConflictError) Error() string {
+	return e.message
+}
+
+func respondSettingsWriteError(c *gin.Context, err error, message string) {
+	var validationErr *schemaValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Settings failed schema validation", "schemaid": validationErr.SchemaId, "issues": validationErr.Issues})
+		return
+	}
+	var sleepErr *sleepConditionError
+	if errors.As(err, &sleepErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid sleep conditions", "issues": sleepErr.Issues})
+		return
+	}
+	var lintErr *lintFailedError
+	if errors.As(err, &lintErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Settings failed lint checks", "lint": lintErr.Reports})
+		return
+	}
+	var paramsErr *templateParamsError
+	if errors.As(err, &paramsErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid template parameters", "issues": paramsErr.Issues})
+		return
+	}
+	var conflictErr *settingsConflictError
+	if errors.As(err, &conflictErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": conflictErr.message})
+		return
+	}
+	var requestErr *settingsRequestError
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": requestErr.message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+func CreateSettingsSchema(c *gin.Context) {
+	var req settingsSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SettingsType != settingsTypeCan && req.SettingsType != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Settings type must be can or nrf"})
+		return
+	}
+	if req.SettingsType == settingsTypeCan && req.FileType != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File type only applies to nrf schemas"})
+		return
+	}
+	if _, err := schemas.Compile(0, req.Schema); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON schema", "details": err.Error()})
+		return
+	}
+	if email := c.GetString("email"); email != "" {
+		req.CreatedBy = email
+	}
+
+	var schema model.SettingsSchema
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var maxVersion int
+		if err := schemaScope(tx.Model(&model.SettingsSchema{}), req.SettingsType, req.FileType).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		if err := schemaScope(tx.Model(&model.SettingsSchema{}), req.SettingsType, req.FileType).Where("isactive").Update("isactive", false).Error; err != nil {
+			return err
+		}
+
+		schema = model.SettingsSchema{
+			SettingsType: req.SettingsType,
+			FileType:     req.FileType,
+			Version:      maxVersion + 1,
+			Schema:       req.Schema,
+			IsActive:     true,
+			Description:  req.Description,
+			CreatedBy:    req.CreatedBy,
+			CreatedAt:    time.Now().Unix(),
+		}
+		return tx.Omit("id").Create(&schema).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schema"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toSchemaResponse(schema))
+}
+
+func GetSettingsSchemas(c *gin.Context) {
+	query := config.DB.Omit("schema").Order("settingstype, filetype NULLS FIRST, version DESC")
+	if settingsType := c.Query("settingstype"); settingsType != "" {
+		query = query.Where("settingstype = ?", settingsType)
+	}
+	if fileType := c.Query("filetype"); fileType != "" {
+		query = query.Where("filetype = ?", fileType)
+	}
+
+	var list []model.SettingsSchema
+	if err := query.Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schemas"})
+		return
+	}
+
+	response := make([]settingsSchemaResponse, 0, len(list))
+	for _, s := range list {
+		response = append(response, toSchemaResponse(s))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetSettingsSchema(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Schema id must be numeric"})
+		return
+	}
+
+	schema, err := loadSchema(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+	c.JSON(http.StatusOK, toSchemaResponse(*schema))
+}
+
+func ActivateSettingsSchema(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Schema id must be numeric"})
+		return
+	}
+
+	schema, err := loadSchema(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := schemaScope(tx.Model(&model.SettingsSchema{}), schema.SettingsType, schema.FileType).Where("isactive").Update("isactive", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.SettingsSchema{}).Where("id = ?", id).Update("isactive", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schema " + strconv.Itoa(id) + " activated"})
+}
+
+func GetSchemaValidationReport(c *gin.Context) {
+	settingsType := c.Query("settingstype")
+	if settingsType != "" && settingsType != settingsTypeCan && settingsType != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Settings type must be can or nrf"})
+		return
+	}
+
+	schemaCache := map[int]*model.SettingsSchema{}
+	schemaFor := func(id int, settingsType string, fileType *int) (*model.SettingsSchema, error) {
+		if id == 0 {
+			return activeSchema(settingsType, fileType)
+		}
+		if s, ok := schemaCache[id]; ok {
+			return s, nil
+		}
+		s, err := loadSchema(id)
+		if err != nil {
+			return nil, err
+		}
+		schemaCache[id] = s
+		return s, nil
+	}
+
+	entries := []schemaReportEntry{}
+	check := func(entry schemaReportEntry, fileType *int, doc []byte) error {
+		schema, err := schemaFor(entry.SchemaId, entry.Type, fileType)
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			return nil
+		}
+		entry.SchemaId = schema.ID
+		entry.SchemaVersion = schema.Version
+		issues, err := validateWithSchema(schema, doc)
+		if err != nil {
+			issues = []schemas.Issue{{Path: "/", Message: err.Error()}}
+		}
+		entry.Valid = len(issues) == 0
+		entry.Issues = issues
+		entries = append(entries, entry)
+		return nil
+	}
+
+	if settingsType == "" || settingsType == settingsTypeCan {
+		var rows []rawSettingsRow
+		if err := config.DB.Model(&model.CanSettings{}).Order("filename, version").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch can settings"})
+			return
+		}
+		for _, row := range rows {
+			entry := schemaReportEntry{Type: settingsTypeCan, FileName: row.FileName, Version: row.Version, SchemaId: row.SchemaId}
+			if err := check(entry, nil, row.JSONData); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate can settings"})
+				return
+			}
+		}
+	}
+
+	if settingsType == "" || settingsType == settingsTypeNrf {
+		var rows []rawSettingsRow
+		if err := config.DB.Model(&model.NrfSettings{}).Order("filename, version").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nrf settings"})
+			return
+		}
+		for _, row := range rows {
+			var members map[string]json.RawMessage
+			err := json.Unmarshal(row.JSONData, &members)
+			if err == nil && members == nil {
+				err = errors.New("JSON data must be an object")
+			}
+			var raw []byte
+			if err == nil {
+				members["sleepcdns"] = row.SleepCdns
+				raw, err = json.Marshal(members)
+			}
+			if err != nil {
+				entries = append(entries, schemaReportEntry{Type: settingsTypeNrf, FileName: row.FileName, Version: row.Version, SchemaId: row.SchemaId, Issues: []schemas.Issue{{Path: "/", Message: err.Error()}}})
+				continue
+			}
+			fileType := row.FileType
+			entry := schemaReportEntry{Type: settingsTypeNrf, FileName: row.FileName, Version: row.Version, SchemaId: row.SchemaId}
+			if err := check(entry, &fileType, raw); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate nrf settings"})
+				return
+			}
+		}
+	}
+
+	invalid := 0
+	for _, e := range entries {
+		if !e.Valid {
+			invalid++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"checked": len(entries), "invalid": invalid, "results": entries})
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"filepackage/config"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	canContentVector = `jsonb_to_tsvector('simple', jsondata::jsonb, '["key", "string"]')`
+	nrfContentVector = `(jsonb_to_tsvector('simple', jsondata::jsonb, '["key", "string"]') || jsonb_to_tsvector('simple', sleepcdns::jsonb, '["key", "string"]'))`
+	contentDocument  = `jsondata::text`
+	nrfDocument      = `(jsondata::text || ' ' || sleepcdns::text)`
+
+	// ts_headline marks matches with control characters, which JSON text
+	// never contains raw, so that the snippet can be escaped before the marks
+	// become HTML.
+	headlineStart   = "\x01"
+	headlineStop    = "\x02"
+	headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MaxFragments=3, MaxWords=15, MinWords=5"
+)
+
+var searchWordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+type settingsSearchResult struct {
+	Type              string  `gorm:"column:type" json:"type"`
+	FileName          string  `gorm:"column:filename" json:"filename"`
+	Version           int     `gorm:"column:version" json:"version,omitempty"`
+	CreatedBy         string  `gorm:"column:createdby" json:"createdby"`
+	CreatedAt         int64   `gorm:"column:createdat" json:"createdat"`
+	Score             float64 `gorm:"column:score" json:"score"`
+	Snippet           string  `gorm:"column:snippet" json:"snippet,omitempty"`
+	FileNameHighlight string  `gorm:"-" json:"filenamehighlight,omitempty"`
+}
+
+type searchSource struct {
+	kind     string
+	table    string
+	version  string
+	where    string
+	vector   string
+	document string
+}
+
+var searchSources = []searchSource{
+	{kind: settingsTypeCan, table: `"FileTracker"."parsedfiles"`, version: "version", where: "iscurrent", vector: canContentVector, document: contentDocument},
+	{kind: settingsTypeNrf, table: `"NrfSettings"."parsedfiles"`, version: "version", where: "iscurrent", vector: nrfContentVector, document: nrfDocument},
+}
+
+func SearchSettings(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	kind := c.Query("type")
+	if kind != "" && kind != settingsTypeCan && kind != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be can or nrf"})
+		return
+	}
+
+	searchNames, searchContent := true, true
+	if fields := c.Query("fields"); fields != "" {
+		searchNames = strings.Contains(fields, "filename")
+		searchContent = strings.Contains(fields, "content")
+	}
+	fuzzy := c.Query("fuzzy") == "true"
+
+	createdFrom, err := parseSearchDate(c.Query("createdfrom"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid createdfrom: " + err.Error()})
+		return
+	}
+	createdTo, err := parseSearchDateEnd(c.Query("createdto"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid createdto: " + err.Error()})
+		return
+	}
+
+	args := map[string]interface{}{
+		"q":        q,
+		"prefix":   escapeLike(q) + "%",
+		"contains": "%" + escapeLike(q) + "%",
+		"tsquery":  prefixTsQuery(q),
+		"headline": headlineOptions,
+	}
+	hasTsQuery := args["tsquery"] != ""
+
+	var filters []string
+	if createdBy := c.Query("createdby"); createdBy != "" {
+		filters = append(filters, "createdby = @createdby")
+		args["createdby"] = createdBy
+	}
+	if createdFrom > 0 {
+		filters = append(filters, "createdat >= @createdfrom")
+		args["createdfrom"] = createdFrom
+	}
+	if createdTo > 0 {
+		filters = append(filters, "createdat <= @createdto")
+		args["createdto"] = createdTo
+	}
+
+	var selects []string
+	for _, src := range searchSources {
+		if kind != "" && kind != src.kind {
+			continue
+		}
+
+		score := "0"
+		snippet := "''"
+		var matches []string
+		if q != "" && searchNames {
+			matches = append(matches, "filename ILIKE @contains")
+			if fuzzy {
+				matches = append(matches, "filename % @q")
+			}
+			score = "(CASE WHEN filename ILIKE @prefix THEN 1 ELSE 0 END + similarity(filename, @q)) * 2"
+		}
+		if q != "" && searchContent && hasTsQuery {
+			query := "to_tsquery('simple', @tsquery)"
+			matches = append(matches, src.vector+" @@ "+query)
+			score += fmt.Sprintf(" + ts_rank(%s, %s)", src.vector, query)
+			snippet = fmt.Sprintf("CASE WHEN %s @@ %s THEN ts_headline('simple', %s, %s, @headline) ELSE '' END", src.vector, query, src.document, query)
+		}
+
+		conditions := append([]string{src.where}, filters...)
+		if q != "" {
+			if len(matches) == 0 {
+				continue
+			}
+			conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+		}
+
+		selects = append(selects, fmt.Sprintf(
+			"SELECT '%s' AS type, filename, %s AS version, createdby, createdat, %s AS score, %s AS snippet FROM %s WHERE %s",
+			src.kind, src.version, score, snippet, src.table, strings.Join(conditions, " AND ")))
+	}
+
+	page, pageSize := paginationParams(c)
+	if len(selects) == 0 {
+		c.JSON(http.StatusOK, gin.H{"page": page, "pagesize": pageSize, "total": 0, "results": []settingsSearchResult{}})
+		return
+	}
+	union := strings.Join(selects, " UNION ALL ")
+
+	var total int64
+	if err := config.DB.Raw("SELECT COUNT(*) FROM ("+union+") s", args).Scan(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search settings"})
+		return
+	}
+
+	args["limit"] = pageSize
+	args["offset"] = (page - 1) * pageSize
+	results := []settingsSearchResult{}
+	err = config.DB.Raw("SELECT * FROM ("+union+") s ORDER BY score DESC, filename LIMIT @limit OFFSET @offset", args).Scan(&results).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search settings"})
+		return
+	}
+
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+		results[i].FileNameHighlight = highlightMatch(results[i].FileName, q)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"page": page, "pagesize": pageSize, "total": total, "results": results})
+}
+
+func parseSearchDate(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return unix, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected unix seconds or YYYY-MM-DD")
+	}
+	return t.Unix(), nil
+}
+
+// parseSearchDateEnd parses an inclusive upper bound; a date includes the
+// whole day.
+func parseSearchDateEnd(value string) (int64, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.AddDate(0, 0, 1).Unix() - 1, nil
+	}
+	return parseSearchDate(value)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func prefixTsQuery(q string) string {
+	words := searchWordPattern.FindAllString(strings.ToLower(q), -1)
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// highlightSnippet escapes a ts_headline snippet and turns its match
+// delimiters into marks.
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// highlightMatch escapes s and marks the first case-insensitive occurrence
+// of q. Case folding maps rune to rune, so a match spans as many runes as q,
+// though not necessarily as many bytes.
+func highlightMatch(s, q string) string {
+	n := utf8.RuneCountInString(q)
+	if n == 0 {
+		return html.EscapeString(s)
+	}
+	for start := 0; start < len(s); {
+		end := start
+		for i := 0; i < n && end < len(s); i++ {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+		}
+		if strings.EqualFold(s[start:end], q) {
+			return html.EscapeString(s[:start]) + "<mark>" + html.EscapeString(s[start:end]) + "</mark>" + html.EscapeString(s[end:])
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		start += size
+	}
+	return html.EscapeString(s)
+}
@@ -0,0 +1,16 @@
+package handler
+
+import "encoding/json"
+
+// genericDocument converts a typed settings document into the
+// map[string]interface{} tree used by the jsonpath, jsondiff and valuemap
+// packages.
+func genericDocument(doc interface{}) (interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
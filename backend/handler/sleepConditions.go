@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"filepackage/model"
+	"filepackage/schemas"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var sleepConditionLists = map[string]bool{"cdn_and": true, "cdn_or": true, "cdn_targ": true}
+
+type sleepConditionError struct {
+	Issues []schemas.Issue
+}
+
+func (e *sleepConditionError) Error() string {
+	return "invalid sleep conditions"
+}
+
+// validateSleepConditions checks that every state transition is present and
+// that its cdn_and, cdn_or and cdn_targ lists are consistent: no blank or
+// repeated conditions and nothing in both cdn_and and cdn_or.
+func validateSleepConditions(raw []byte) []schemas.Issue {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return []schemas.Issue{{Path: "/", Keyword: "type", Message: "sleep conditions must be an object"}}
+	}
+
+	var issues []schemas.Issue
+	known := map[string]bool{}
+	for _, transition := range model.SleepTransitions {
+		known[transition] = true
+		value, ok := doc[transition]
+		if !ok {
+			issues = append(issues, schemas.Issue{Path: "/", Keyword: "required", Message: "missing transition " + transition})
+			continue
+		}
+		issues = append(issues, validateSleepTransition("/"+transition, value)...)
+	}
+
+	var unknown []string
+	for key := range doc {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		issues = append(issues, schemas.Issue{Path: "/" + key, Keyword: "additionalProperties", Message: "unknown transition " + key})
+	}
+	return issues
+}
+
+func validateSleepTransition(path string, raw json.RawMessage) []schemas.Issue {
+	var transition map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &transition); err != nil || transition == nil {
+		return []schemas.Issue{{Path: path, Keyword: "type", Message: "transition must be an object"}}
+	}
+
+	var issues []schemas.Issue
+	lists := map[string][]string{}
+	keys := make([]string, 0, len(transition))
+	for key := range transition {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !sleepConditionLists[key] {
+			issues = append(issues, schemas.Issue{Path: path + "/" + key, Keyword: "additionalProperties", Message: "unknown condition list " + key})
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(transition[key], &list); err != nil {
+			issues = append(issues, schemas.Issue{Path: path + "/" + key, Keyword: "type", Message: key + " must be an array of strings"})
+			continue
+		}
+		seen := map[string]bool{}
+		for i, cond := range list {
+			name := strings.TrimSpace(cond)
+			switch {
+			case name == "":
+				issues = append(issues, schemas.Issue{Path: path + "/" + key + "/" + strconv.Itoa(i), Keyword: "minLength", Message: "condition must not be blank"})
+			case seen[name]:
+				issues = append(issues, schemas.Issue{Path: path + "/" + key + "/" + strconv.Itoa(i), Keyword: "uniqueItems", Message: "duplicate condition " + name})
+			}
+			seen[name] = true
+		}
+		lists[key] = list
+	}
+
+	inAnd := map[string]bool{}
+	for _, cond := range lists["cdn_and"] {
+		inAnd[strings.TrimSpace(cond)] = true
+	}
+	for i, cond := range lists["cdn_or"] {
+		name := strings.TrimSpace(cond)
+		if name != "" && inAnd[name] {
+			issues = append(issues, schemas.Issue{Path: path + "/cdn_or/" + strconv.Itoa(i), Keyword: "conflict", Message: "condition " + name + " is in both cdn_and and cdn_or"})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"filepackage/notify"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stockAlertLock is the advisory lock key held while stock alerts are
+// evaluated and sent, so that several server instances do not notify twice.
+const stockAlertLock = 0x5354434b
+
+// stockAlertNotifyTimeout bounds the sending of one round of alerts.
+const stockAlertNotifyTimeout = 30 * time.Second
+
+var errStockAlertsBusy = errors.New("stock alerts are being evaluated elsewhere")
+
+var stockAlertNotifier notify.Notifier = notify.Log{}
+
+type stockThresholdRequest struct {
+	PhCode          string `json:"phcode"`
+	AhCode          string `json:"ahcode"`
+	MinStock        int    `json:"minstock"`
+	ReorderQuantity int    `json:"reorderquantity"`
+}
+
+type thresholdStock struct {
+	ID              int    `gorm:"column:id"`
+	PhCode          string `gorm:"column:phcode"`
+	AhCode          string `gorm:"column:ahcode"`
+	MinStock        int    `gorm:"column:minstock"`
+	ReorderQuantity int    `gorm:"column:reorderquantity"`
+	Stock           int    `gorm:"column:stock"`
+}
+
+type stockAlertEvaluation struct {
+	Raised   int                `json:"raised"`
+	Resolved int                `json:"resolved"`
+	Notified int                `json:"notified"`
+	Open     []model.StockAlert `json:"open"`
+}
+
+// StartStockAlerts evaluates the stock thresholds now and then every
+// interval, sending new alerts through notifier.
+func StartStockAlerts(interval time.Duration, notifier notify.Notifier) {
+	stockAlertNotifier = notifier
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := evaluateStockAlerts(context.Background()); err != nil && !errors.Is(err, errStockAlertsBusy) {
+				log.Printf("Error evaluating stock alerts: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// evaluateStockAlerts compares the stock of each threshold's harnesses with
+// its minimum, raising and resolving alerts, and notifies the open alerts
+// not yet sent. The alerts are committed before they are sent and marked as
+// notified afterwards, so no transaction stays open while the notifier
+// waits; alerts whose notification fails are sent again next time. A
+// session advisory lock on one connection keeps other instances out until
+// the round is done.
+func evaluateStockAlerts(ctx context.Context) (stockAlertEvaluation, error) {
+	var result stockAlertEvaluation
+	err := config.DB.Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", stockAlertLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return errStockAlertsBusy
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", stockAlertLock).Error; err != nil {
+				log.Printf("Error releasing stock alert lock: %v", err)
+			}
+		}()
+
+		if err := conn.Transaction(func(tx *gorm.DB) error {
+			return raiseStockAlerts(tx, &result)
+		}); err != nil {
+			return err
+		}
+
+		var pending []model.StockAlert
+		ids := []int{}
+		for _, alert := range result.Open {
+			if alert.NotifiedAt == nil {
+				pending = append(pending, alert)
+				ids = append(ids, alert.ID)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		notifyCtx, cancel := context.WithTimeout(ctx, stockAlertNotifyTimeout)
+		defer cancel()
+		if err := stockAlertNotifier.Notify(notifyCtx, stockAlertMessage(pending)); err != nil {
+			log.Printf("Error sending stock alerts: %v", err)
+			return nil
+		}
+
+		now := time.Now().Unix()
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			return tx.Model(&model.StockAlert{}).Where("id IN ? AND notifiedat IS NULL", ids).Update("notifiedat", now).Error
+		})
+		if err != nil {
+			return err
+		}
+		result.Notified = len(pending)
+		for i := range result.Open {
+			if result.Open[i].NotifiedAt == nil {
+				result.Open[i].NotifiedAt = &now
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// raiseStockAlerts raises alerts for the thresholds whose stock is below
+// their minimum, updates the open ones and resolves those that recovered.
+// It counts the changes in result and loads the open alerts into it.
+func raiseStockAlerts(tx *gorm.DB, result *stockAlertEvaluation) error {
+	var thresholds []thresholdStock
+	err := tx.Raw(`SELECT t.id, t.phcode, t.ahcode, t.minstock, t.reorderquantity,
+			COALESCE((SELECT SUM(h.currentstock) FROM "Harness"."harness" h
+				WHERE h.phcode = t.phcode AND (t.ahcode = '' OR h.ahcode = t.ahcode)), 0) AS stock
+		FROM "Harness"."stockthresholds" t ORDER BY t.phcode, t.ahcode`).Scan(&thresholds).Error
+	if err != nil {
+		return err
+	}
+	var alerts []model.StockAlert
+	if err := tx.Where("resolvedat IS NULL").Find(&alerts).Error; err != nil {
+		return err
+	}
+	open := make(map[int]model.StockAlert, len(alerts))
+	for _, alert := range alerts {
+		open[alert.ThresholdId] = alert
+	}
+
+	now := time.Now().Unix()
+	for _, t := range thresholds {
+		alert, isOpen := open[t.ID]
+		switch {
+		case t.Stock < t.MinStock && isOpen:
+			err = tx.Model(&alert).Updates(map[string]interface{}{"stock": t.Stock, "minstock": t.MinStock, "reorderquantity": t.ReorderQuantity}).Error
+		case t.Stock < t.MinStock:
+			alert = model.StockAlert{ThresholdId: t.ID, PhCode: t.PhCode, AhCode: t.AhCode, Stock: t.Stock, MinStock: t.MinStock, ReorderQuantity: t.ReorderQuantity, RaisedAt: now}
+			err = tx.Create(&alert).Error
+			result.Raised++
+		case isOpen:
+			err = tx.Model(&alert).Update("resolvedat", now).Error
+			result.Resolved++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("resolvedat IS NULL").Order("phcode, ahcode").Find(&result.Open).Error
+}
+
+func stockAlertMessage(alerts []model.StockAlert) notify.Message {
+	var b strings.Builder
+	for _, alert := range alerts {
+		code := alert.PhCode
+		if alert.AhCode != "" {
+			code += " / " + alert.AhCode
+		}
+		fmt.Fprintf(&b, "%s: %d in stock, minimum %d, reorder %d\n", code, alert.Stock, alert.MinStock, alert.ReorderQuantity)
+	}
+	return notify.Message{
+		Subject: fmt.Sprintf("Low stock: %d harnesses below minimum", len(alerts)),
+		Body:    b.String(),
+		Data:    alerts,
+	}
+}
+
+// GetStockAlerts lists alerts by status: open (the default), resolved or
+// all.
+func GetStockAlerts(c *gin.Context) {
+	query := config.DB.Order("raisedat DESC, id DESC")
+	switch c.DefaultQuery("status", "open") {
+	case "open":
+		query = query.Where("resolvedat IS NULL")
+	case "resolved":
+		query = query.Where("resolvedat IS NOT NULL")
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be open, resolved or all"})
+		return
+	}
+	if phcode := c.Query("phcode"); phcode != "" {
+		query = query.Where("phcode = ?", phcode)
+	}
+
+	var alerts []model.StockAlert
+	if err := query.Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock alerts"})
+		return
+	}
+	respondList(c, alerts)
+}
+
+func EvaluateStockAlerts(c *gin.Context) {
+	result, err := evaluateStockAlerts(c.Request.Context())
+	if errors.Is(err, errStockAlertsBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock alerts are already being evaluated"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate stock alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func GetStockThresholds(c *gin.Context) {
+	query := config.DB.Order("phcode, ahcode")
+	if phcode := c.Query("phcode"); phcode != "" {
+		query = query.Where("phcode = ?", phcode)
+	}
+	var thresholds []model.StockThreshold
+	if err := query.Find(&thresholds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock thresholds"})
+		return
+	}
+	respondList(c, thresholds)
+}
+
+// SaveStockThreshold creates or replaces the threshold for a PhCode and
+// AhCode.
+func SaveStockThreshold(c *gin.Context) {
+	var req stockThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PhCode = strings.TrimSpace(req.PhCode)
+	req.AhCode = strings.TrimSpace(req.AhCode)
+	switch {
+	case req.PhCode == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PH code is required"})
+		return
+	case req.MinStock < 0 || req.ReorderQuantity < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum stock and reorder quantity cannot be negative"})
+		return
+	}
+
+	threshold := model.StockThreshold{
+		PhCode:          req.PhCode,
+		AhCode:          req.AhCode,
+		MinStock:        req.MinStock,
+		ReorderQuantity: req.ReorderQuantity,
+		UpdatedBy:       c.GetString("email"),
+		UpdatedAt:       time.Now().Unix(),
+	}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "phcode"}, {Name: "ahcode"}},
+		DoUpdates: clause.AssignmentColumns([]string{"minstock", "reorderquantity", "updatedby", "updatedat"}),
+	}).Create(&threshold).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save stock threshold"})
+		return
+	}
+	c.JSON(http.StatusOK, threshold)
+}
+
+func DeleteStockThreshold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Threshold id must be numeric"})
+		return
+	}
+	result := config.DB.Delete(&model.StockThreshold{}, "id = ?", id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stock threshold"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threshold not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Threshold " + strconv.Itoa(id) + " deleted"})
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type stockMovementRequest struct {
+	Type            string `json:"type"`
+	Quantity        int    `json:"quantity"`
+	Reason          string `json:"reason"`
+	Reference       string `json:"reference"`
+	Rev             int    `json:"rev"`
+	TargetHarnessId *int64 `json:"targetharnessid"`
+}
+
+type harnessStock struct {
+	HarnessId int64  `gorm:"column:harnessid" json:"harnessid"`
+	PhCode    string `gorm:"column:phcode" json:"phcode"`
+	AhCode    string `gorm:"column:ahcode" json:"ahcode"`
+	Stock     int    `gorm:"column:stock" json:"stock"`
+}
+
+// lockHarnessStock locks a harness row until tx ends and returns it with its
+// stock as recorded by the ledger. Movements are only posted under this
+// lock, so the balance cannot change before tx ends.
+func lockHarnessStock(tx *gorm.DB, harnessId int64) (model.Harness, int, error) {
+	var h model.Harness
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&h, "harnessid = ?", harnessId).Error; err != nil {
+		return h, 0, err
+	}
+	var stock int
+	err := tx.Model(&model.StockMovement{}).Where("harnessid = ?", harnessId).Select("COALESCE(SUM(quantity), 0)").Scan(&stock).Error
+	return h, stock, err
+}
+
+// postStockMovement records m in the ledger and sets the harness's current
+// stock to the resulting ledger balance, which must not be negative.
+// Movements belong to the harness's current revision unless m names another,
+// and stock out of a revision is limited to what it holds. The harness row
+// stays locked until tx ends.
+func postStockMovement(tx *gorm.DB, m *model.StockMovement) error {
+	h, stock, err := lockHarnessStock(tx, m.HarnessId)
+	if err != nil {
+		return err
+	}
+	if m.Rev == 0 {
+		m.Rev = h.Rev
+	} else if m.Rev != h.Rev {
+		var count int64
+		if err := tx.Model(&model.HarnessRevision{}).Where("phcode = ? AND rev = ?", h.PhCode, m.Rev).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return &settingsRequestError{fmt.Sprintf("Harness %s has no revision %d", h.PhCode, m.Rev)}
+		}
+	}
+	if m.Quantity < 0 {
+		var revStock int
+		if err := tx.Model(&model.StockMovement{}).Where("harnessid = ? AND rev = ?", h.HarnessId, m.Rev).Select("COALESCE(SUM(quantity), 0)").Scan(&revStock).Error; err != nil {
+			return err
+		}
+		if revStock+m.Quantity < 0 {
+			return &settingsConflictError{fmt.Sprintf("Harness %d has only %d of revision %d in stock", h.HarnessId, revStock, m.Rev)}
+		}
+	}
+	balance := stock + m.Quantity
+	if balance < 0 {
+		return &settingsConflictError{fmt.Sprintf("Harness %d has only %d in stock", h.HarnessId, stock)}
+	}
+	m.Balance = balance
+	if err := tx.Model(&model.Harness{}).Where("harnessid = ?", h.HarnessId).Update("currentstock", balance).Error; err != nil {
+		return err
+	}
+	return tx.Create(m).Error
+}
+
+// parseAsOf parses the asof query parameter as unix seconds or a date, which
+// means the end of that day. It defaults to now.
+func parseAsOf(value string) (int64, error) {
+	if value == "" {
+		return time.Now().Unix(), nil
+	}
+	return parseSearchDateEnd(value)
+}
+
+// CreateStockMovement records a stock-in, stock-out, adjustment or transfer
+// for a harness. Quantities are positive except for adjustments, which give
+// the signed change. A transfer moves stock to the harness named by
+// targetharnessid. Rev selects the revision moved; it defaults to the current
+// one, and a transfer lands in the target's current revision.
+func CreateStockMovement(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	var req stockMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Reference = strings.TrimSpace(req.Reference)
+
+	quantity := req.Quantity
+	switch req.Type {
+	case model.StockIn:
+	case model.StockOut, model.StockTransfer:
+		quantity = -quantity
+	case model.StockAdjustment:
+		if req.Quantity == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Adjustment quantity must not be zero"})
+			return
+		}
+		if req.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required for adjustments"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be in, out, adjustment or transfer"})
+		return
+	}
+	if req.Type != model.StockAdjustment && req.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	if req.Type == model.StockTransfer && (req.TargetHarnessId == nil || *req.TargetHarnessId == vehicle.HarnessId) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfers need a target harness other than the source"})
+		return
+	}
+
+	now := time.Now().Unix()
+	user := c.GetString("email")
+	movements := []model.StockMovement{{
+		HarnessId: vehicle.HarnessId,
+		Type:      req.Type,
+		Rev:       req.Rev,
+		Quantity:  quantity,
+		Reason:    req.Reason,
+		Reference: req.Reference,
+		CreatedBy: user,
+		CreatedAt: now,
+	}}
+	if req.Type == model.StockTransfer {
+		movements[0].CounterpartId = req.TargetHarnessId
+		movements = append(movements, model.StockMovement{
+			HarnessId:     *req.TargetHarnessId,
+			Type:          req.Type,
+			Quantity:      req.Quantity,
+			Reason:        req.Reason,
+			Reference:     req.Reference,
+			CounterpartId: &vehicle.HarnessId,
+			CreatedBy:     user,
+			CreatedAt:     now,
+		})
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock both sides of a transfer in id order so that opposite
+		// transfers cannot deadlock.
+		ids := []int64{}
+		for _, m := range movements {
+			ids = append(ids, m.HarnessId)
+		}
+		var locked []model.Harness
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("harnessid IN ?", ids).Order("harnessid").Find(&locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != len(ids) {
+			return &settingsRequestError{fmt.Sprintf("Harnesses %v do not all exist", ids)}
+		}
+		for i := range movements {
+			if err := postStockMovement(tx, &movements[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to record stock movement")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"movements": movements, "currentstock": movements[0].Balance})
+}
+
+// GetStockMovements returns a harness's movement history, newest first,
+// optionally limited to a type and to movements between from and to.
+func GetStockMovements(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	from, err := parseSearchDate(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseAsOf(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+		return
+	}
+
+	query := config.DB.Where("harnessid = ? AND createdat >= ? AND createdat <= ?", vehicle.HarnessId, from, to)
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+	var movements []model.StockMovement
+	if err := query.Order("id DESC").Find(&movements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+	respondList(c, movements)
+}
+
+// GetStockAsOf returns a harness's stock at the end of the asof date: the
+// balance after its last movement up to then, and how much of it each
+// revision held.
+func GetStockAsOf(c *gin.Context) {
+	vehicle, ok := findHarness(c)
+	if !ok {
+		return
+	}
+	asOf, err := parseAsOf(c.Query("asof"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asof: " + err.Error()})
+		return
+	}
+
+	stock := 0
+	var last model.StockMovement
+	err = config.DB.Where("harnessid = ? AND createdat <= ?", vehicle.HarnessId, asOf).Order("id DESC").First(&last).Error
+	switch {
+	case err == nil:
+		stock = last.Balance
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	revisions, err := revisionStocks(asOf, "m.harnessid = ?", vehicle.HarnessId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"harnessid": vehicle.HarnessId, "asof": asOf, "stock": stock, "revisions": revisions})
+}
+
+func GetAllStockAsOf(c *gin.Context) {
+	asOf, err := parseAsOf(c.Query("asof"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asof: " + err.Error()})
+		return
+	}
+
+	var stock []harnessStock
+	err = config.DB.Raw(`SELECT h.harnessid, h.phcode, h.ahcode, COALESCE(m.balance, 0) AS stock
+		FROM "Harness"."harness" h
+		LEFT JOIN (
+			SELECT DISTINCT ON (harnessid) harnessid, balance FROM "Harness"."stockmovements"
+			WHERE createdat <= ? ORDER BY harnessid, id DESC
+		) m ON m.harnessid = h.harnessid
+		ORDER BY h.harnessid`, asOf).Scan(&stock).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	respondList(c, stock)
+}
@@ -0,0 +1,442 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"filepackage/config"
+	"filepackage/lint"
+	"filepackage/model"
+	"filepackage/templates"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type settingsTemplateRequest struct {
+	Name         string                    `json:"name"`
+	SettingsType string                    `json:"settingstype"`
+	FileType     *int                      `json:"filetype"`
+	Description  string                    `json:"description"`
+	Parameters   []model.TemplateParameter `json:"parameters"`
+	Body         json.RawMessage           `json:"body"`
+	CreatedBy    string                    `json:"createdby"`
+}
+
+type settingsTemplateResponse struct {
+	model.SettingsTemplate
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+type templateGenerateRequest struct {
+	FileName string                 `json:"filename"`
+	FileType *int                   `json:"filetype"`
+	Params   map[string]interface{} `json:"params"`
+}
+
+type templateGeneration struct {
+	model.SettingsGeneration
+	Stale bool `json:"stale"`
+}
+
+type generatedFile struct {
+	FileName string       `json:"filename"`
+	Version  int          `json:"version,omitempty"`
+	Created  bool         `json:"created"`
+	Lint     []lintReport `json:"lint,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type templateParamsError struct {
+	Issues []templates.Issue
+}
+
+func (e *templateParamsError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.Parameter + ": " + issue.Message
+	}
+	return "invalid template parameters: " + strings.Join(messages, "; ")
+}
+
+func toTemplateResponse(t model.SettingsTemplate) settingsTemplateResponse {
+	resp := settingsTemplateResponse{SettingsTemplate: t}
+	if len(t.Body) > 0 {
+		resp.Body = t.Body
+	}
+	if resp.Parameters == nil {
+		resp.Parameters = []model.TemplateParameter{}
+	}
+	return resp
+}
+
+func loadTemplate(c *gin.Context) (model.SettingsTemplate, bool) {
+	var tmpl model.SettingsTemplate
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template id must be numeric"})
+		return tmpl, false
+	}
+	if err := config.DB.First(&tmpl, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return tmpl, false
+	}
+	return tmpl, true
+}
+
+func validateTemplateRequest(c *gin.Context, req *settingsTemplateRequest) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template name is required"})
+		return false
+	}
+	if req.SettingsType != settingsTypeCan && req.SettingsType != settingsTypeNrf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Settings type must be can or nrf"})
+		return false
+	}
+	if req.SettingsType == settingsTypeCan && req.FileType != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File type only applies to nrf templates"})
+		return false
+	}
+	if issues := templates.Check(req.Parameters, req.Body); len(issues) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid template", "issues": issues})
+		return false
+	}
+	if email := c.GetString("email"); email != "" {
+		req.CreatedBy = email
+	}
+	return true
+}
+
+// generateSettings renders the template with values and saves the result as
+// a new version of fileName. Files that exist but were not generated from
+// the template are left alone.
+func generateSettings(c *gin.Context, tmpl model.SettingsTemplate, fileName string, fileType *int, values map[string]interface{}, user string) (generatedFile, error) {
+	result := generatedFile{FileName: fileName}
+
+	var generation model.SettingsGeneration
+	err := config.DB.Where("settingstype = ? AND filename = ?", tmpl.SettingsType, fileName).First(&generation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	generated := err == nil
+	if generated && generation.TemplateId != tmpl.ID {
+		return result, &settingsConflictError{"Settings " + fileName + " were generated from template " + strconv.Itoa(generation.TemplateId)}
+	}
+
+	resolved, issues := templates.Resolve(tmpl.Parameters, values)
+	if len(issues) > 0 {
+		return result, &templateParamsError{Issues: issues}
+	}
+	body, err := templates.Render(tmpl.Body, resolved)
+	if err != nil {
+		return result, &settingsRequestError{"Failed to render template: " + err.Error()}
+	}
+
+	switch tmpl.SettingsType {
+	case settingsTypeCan:
+		if !generated {
+			if _, err := findCanSettings(fileName, ""); err == nil {
+				return result, &settingsConflictError{"Can settings " + fileName + " already exist and were not generated from this template"}
+			}
+		}
+		var data model.CanSettingsData
+		if err := json.Unmarshal(body, &data); err != nil {
+			return result, &settingsRequestError{"Invalid JSON data: " + err.Error()}
+		}
+		if result.Lint, err = lintSettings(c, settingsTypeCan, fileName, lint.Target{Can: &data}); err != nil {
+			return result, err
+		}
+		canSettings, created, err := createCanSettingsVersion(fileName, body, user)
+		if err != nil {
+			return result, err
+		}
+		result.Version, result.Created = canSettings.Version, created
+
+	case settingsTypeNrf:
+		next, err := findNrfSettings(fileName, "")
+		if err == nil && !generated {
+			return result, &settingsConflictError{"Nrf settings " + fileName + " already exist and were not generated from this template"}
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return result, err
+		}
+		if err != nil {
+			next = model.NrfSettings{FileName: fileName}
+			if fileType == nil {
+				fileType = tmpl.FileType
+			}
+			if fileType == nil {
+				return result, &settingsRequestError{"File type is required"}
+			}
+		}
+		if fileType != nil {
+			next.FileType = *fileType
+		}
+		if err := applyNrfRequest(&next, body, nil); err != nil {
+			return result, err
+		}
+		if next.SleepCdns == nil {
+			return result, &settingsRequestError{"Sleep conditions are required"}
+		}
+		target, err := nrfLintTarget(next)
+		if err != nil {
+			return result, err
+		}
+		if result.Lint, err = lintSettings(c, settingsTypeNrf, fileName, target); err != nil {
+			return result, err
+		}
+		nrfSettings, created, err := saveNrfSettingsVersion(next, user)
+		if err != nil {
+			return result, err
+		}
+		result.Version, result.Created = nrfSettings.Version, created
+	}
+
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	generation = model.SettingsGeneration{
+		TemplateId:      tmpl.ID,
+		TemplateVersion: tmpl.Version,
+		SettingsType:    tmpl.SettingsType,
+		FileName:        fileName,
+		FileVersion:     result.Version,
+		Params:          values,
+		GeneratedBy:     user,
+		GeneratedAt:     time.Now().Unix(),
+	}
+	err = config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "settingstype"}, {Name: "filename"}},
+		DoUpdates: clause.AssignmentColumns([]string{"templateid", "templateversion", "fileversion", "params", "generatedby", "generatedat"}),
+	}).Omit("id").Create(&generation).Error
+	return result, err
+}
+
+func GetSettingsTemplates(c *gin.Context) {
+	query := config.DB.Omit("body").Order("name")
+	if settingsType := c.Query("settingstype"); settingsType != "" {
+		query = query.Where("settingstype = ?", settingsType)
+	}
+
+	var list []model.SettingsTemplate
+	if err := query.Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	response := make([]settingsTemplateResponse, 0, len(list))
+	for _, t := range list {
+		response = append(response, toTemplateResponse(t))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetSettingsTemplate(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toTemplateResponse(tmpl))
+}
+
+func CreateSettingsTemplate(c *gin.Context) {
+	var req settingsTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateTemplateRequest(c, &req) {
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&model.SettingsTemplate{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template " + req.Name + " already exists"})
+		return
+	}
+
+	now := time.Now().Unix()
+	tmpl := model.SettingsTemplate{
+		Name:         req.Name,
+		SettingsType: req.SettingsType,
+		FileType:     req.FileType,
+		Version:      1,
+		Description:  req.Description,
+		Parameters:   req.Parameters,
+		Body:         req.Body,
+		CreatedBy:    req.CreatedBy,
+		UpdatedBy:    req.CreatedBy,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := config.DB.Omit("id").Create(&tmpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	c.JSON(http.StatusCreated, toTemplateResponse(tmpl))
+}
+
+// UpdateSettingsTemplate replaces a template. Changing the body or the
+// parameters bumps the template version, which marks the files generated
+// from earlier versions as stale.
+func UpdateSettingsTemplate(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+	var req settingsTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SettingsType == "" {
+		req.SettingsType = tmpl.SettingsType
+	}
+	if req.SettingsType != tmpl.SettingsType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Settings type of a template cannot be changed"})
+		return
+	}
+	if !validateTemplateRequest(c, &req) {
+		return
+	}
+
+	oldParams, _ := json.Marshal(tmpl.Parameters)
+	newParams, _ := json.Marshal(req.Parameters)
+	var oldBody, newBody bytes.Buffer
+	json.Compact(&oldBody, tmpl.Body)
+	json.Compact(&newBody, req.Body)
+	if !bytes.Equal(oldParams, newParams) || !bytes.Equal(oldBody.Bytes(), newBody.Bytes()) {
+		tmpl.Version++
+	}
+
+	tmpl.Name = req.Name
+	tmpl.FileType = req.FileType
+	tmpl.Description = req.Description
+	tmpl.Parameters = req.Parameters
+	tmpl.Body = req.Body
+	tmpl.UpdatedBy = req.CreatedBy
+	tmpl.UpdatedAt = time.Now().Unix()
+	if err := config.DB.Save(&tmpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	var stale int64
+	config.DB.Model(&model.SettingsGeneration{}).Where("templateid = ? AND templateversion < ?", tmpl.ID, tmpl.Version).Count(&stale)
+	c.JSON(http.StatusOK, gin.H{"template": toTemplateResponse(tmpl), "stale": stale})
+}
+
+func DeleteSettingsTemplate(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&model.SettingsGeneration{}).Where("templateid = ?", tmpl.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template " + tmpl.Name + " has generated " + strconv.FormatInt(count, 10) + " settings files"})
+		return
+	}
+	if err := config.DB.Delete(&model.SettingsTemplate{}, "id = ?", tmpl.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template " + tmpl.Name + " deleted"})
+}
+
+func GenerateFromTemplate(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+	var req templateGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.FileName = strings.TrimSpace(req.FileName)
+	if req.FileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File name is required"})
+		return
+	}
+
+	result, err := generateSettings(c, tmpl, req.FileName, req.FileType, req.Params, c.GetString("email"))
+	if err != nil {
+		respondSettingsWriteError(c, err, "Failed to generate settings")
+		return
+	}
+	status := http.StatusOK
+	if result.Created && result.Version == 1 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, result)
+}
+
+func GetTemplateGenerations(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+
+	var rows []model.SettingsGeneration
+	if err := config.DB.Where("templateid = ?", tmpl.ID).Order("filename").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch generated settings"})
+		return
+	}
+	list := make([]templateGeneration, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, templateGeneration{SettingsGeneration: row, Stale: row.TemplateVersion < tmpl.Version})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RefreshTemplateGenerations regenerates the stale files of a template with
+// the parameter values they were generated with. A failure is reported for
+// its file and does not stop the others.
+func RefreshTemplateGenerations(c *gin.Context) {
+	tmpl, ok := loadTemplate(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("templateid = ? AND templateversion < ?", tmpl.ID, tmpl.Version)
+	if fileName := strings.TrimSpace(c.Query("filename")); fileName != "" {
+		query = query.Where("filename = ?", fileName)
+	}
+	var rows []model.SettingsGeneration
+	if err := query.Order("filename").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch generated settings"})
+		return
+	}
+
+	files := []generatedFile{}
+	refreshed := 0
+	for _, row := range rows {
+		result, err := generateSettings(c, tmpl, row.FileName, nil, row.Params, c.GetString("email"))
+		if err != nil {
+			result.Error = err.Error()
+			var lintErr *lintFailedError
+			if errors.As(err, &lintErr) {
+				result.Lint = lintErr.Reports
+			}
+		} else {
+			refreshed++
+		}
+		files = append(files, result)
+	}
+	c.JSON(http.StatusOK, gin.H{"template": tmpl.Name, "version": tmpl.Version, "refreshed": refreshed, "files": files})
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"filepackage/config"
+	"filepackage/model"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var releasedStatuses = []string{"production", "eol"}
+
+type packageUsage struct {
+	Filepackagecode string `json:"filepackagecode"`
+	Groupid         int    `json:"groupid"`
+	Groupname       string `json:"groupname"`
+	Modelname       string `json:"modelname"`
+	Status          string `json:"status"`
+	Released        bool   `json:"released"`
+	Field           string `json:"field"`
+	Version         int    `json:"version,omitempty"`
+}
+
+func isReleased(status string) bool {
+	for _, s := range releasedStatuses {
+		if strings.EqualFold(strings.TrimSpace(status), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageUsages returns the packages whose given columns equal name.
+func packageUsages(name string, columns ...string) ([]packageUsage, error) {
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " = ?"
+		args[i] = name
+	}
+
+	var pkgs []model.FilePackage
+	if err := config.DB.Where(strings.Join(conditions, " OR "), args...).Order("filepackagecode").Find(&pkgs).Error; err != nil {
+		return nil, err
+	}
+
+	usages := []packageUsage{}
+	for _, pkg := range pkgs {
+		fields := map[string]string{
+			"mainsettingsname":       pkg.Mainsettingsname,
+			"coprocsettingsname":     pkg.Coprocsettingsname,
+			"mainfirmware":           pkg.Mainfirmware,
+			"mainfirmwarebootloader": pkg.Mainfirmwarebootloader,
+			"coprocfirmware":         pkg.Coprocfirmware,
+		}
+		for _, column := range columns {
+			if fields[column] != name {
+				continue
+			}
+			usage := packageUsage{
+				Filepackagecode: pkg.Filepackagecode,
+				Groupid:         pkg.Groupid,
+				Groupname:       pkg.Groupname,
+				Modelname:       pkg.Modelname,
+				Status:          pkg.Status,
+				Released:        isReleased(pkg.Status),
+				Field:           column,
+			}
+			switch column {
+			case "mainsettingsname":
+				usage.Version = pkg.Mainsettingsversion
+			case "coprocsettingsname":
+				usage.Version = pkg.Coprocsettingsversion
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+func canSettingsUsages(fileName string) ([]packageUsage, error) {
+	return packageUsages(fileName, "mainsettingsname")
+}
+
+func nrfSettingsUsages(fileName string) ([]packageUsage, error) {
+	return packageUsages(fileName, "coprocsettingsname")
+}
+
+// releasedUsageWarnings describes the released packages that follow the
+// current version of a settings file, i.e. those not pinned to a version.
+func releasedUsageWarnings(usages []packageUsage) []string {
+	var warnings []string
+	for _, u := range usages {
+		if u.Released && u.Version == 0 {
+			warnings = append(warnings, fmt.Sprintf("Used by released package %s (%s)", u.Filepackagecode, u.Status))
+		}
+	}
+	return warnings
+}
+
+func usageWarnings(usages func(string) ([]packageUsage, error), fileName string) []string {
+	list, err := usages(fileName)
+	if err != nil {
+		return []string{"Could not check package usage: " + err.Error()}
+	}
+	return releasedUsageWarnings(list)
+}
+
+func respondUsage(c *gin.Context, name string, usages []packageUsage, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch package usage"})
+		return
+	}
+	released := 0
+	for _, u := range usages {
+		if u.Released {
+			released++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"name": name, "total": len(usages), "released": released, "packages": usages})
+}
+
+func GetCanSettingsUsage(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	usages, err := canSettingsUsages(fileName)
+	respondUsage(c, fileName, usages, err)
+}
+
+func GetNrfSettingsUsage(c *gin.Context) {
+	fileName := strings.TrimSpace(c.Param("filename"))
+	usages, err := nrfSettingsUsages(fileName)
+	respondUsage(c, fileName, usages, err)
+}
+
+func GetFirmwareUsage(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
+	usages, err := packageUsages(name, "mainfirmware", "mainfirmwarebootloader", "coprocfirmware")
+	respondUsage(c, name, usages, err)
+}
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"errors"
+	"filepackage/config"
+	"filepackage/model"
+	"filepackage/valuemap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type valueMappingEntryRequest struct {
+	Key          string      `json:"key"`
+	Value        interface{} `json:"value"`
+	DisplayValue string      `json:"displayvalue"`
+}
+
+type valueMappingRequest struct {
+	Name         string                     `json:"name"`
+	Description  string                     `json:"description"`
+	SettingsType string                     `json:"settingstype"`
+	Visibility   string                     `json:"visibility"`
+	Mappings     []valueMappingEntryRequest `json:"mappings"`
+}
+
+func visibleMappings(c *gin.Context) *gorm.DB {
+	if strings.EqualFold(c.GetString("role"), "admin") {
+		return config.DB
+	}
+	return config.DB.Where("visibility = ? OR owner = ?", model.VisibilityShared, c.GetString("email"))
+}
+
+func canEditMapping(c *gin.Context, dict model.ValueMappingDictionary) bool {
+	return strings.EqualFold(c.GetString("role"), "admin") || dict.Owner == c.GetString("email")
+}
+
+func (req *valueMappingRequest) entries() ([]model.ValueMappingEntry, error) {
+	entries := make([]model.ValueMappingEntry, 0, len(req.Mappings))
+	seen := map[string]bool{}
+	for _, m := range req.Mappings {
+		key := strings.TrimSpace(m.Key)
+		if key == "" {
+			return nil, errors.New("Mapping key is required")
+		}
+		value, ok := valuemap.ValueString(m.Value)
+		if !ok {
+			return nil, errors.New("Mapping value for " + key + " must be a string, number or boolean")
+		}
+		if seen[key+"\x00"+value] {
+			return nil, errors.New("Duplicate mapping for " + key + " = " + value)
+		}
+		seen[key+"\x00"+value] = true
+		entries = append(entries, model.ValueMappingEntry{KeyPath: key, Value: value, DisplayValue: m.DisplayValue})
+	}
+	return entries, nil
+}
+
+func (req *valueMappingRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("Name is required")
+	}
+	if req.SettingsType != "" && req.SettingsType != settingsTypeCan && req.SettingsType != settingsTypeNrf {
+		return errors.New("Settings type must be can, nrf or empty")
+	}
+	if req.Visibility == "" {
+		req.Visibility = model.VisibilityPrivate
+	}
+	if req.Visibility != model.VisibilityPrivate && req.Visibility != model.VisibilityShared {
+		return errors.New("Visibility must be private or shared")
+	}
+	return nil
+}
+
+func mappingNameTaken(name string, exceptId int) (bool, error) {
+	var count int64
+	err := config.DB.Model(&model.ValueMappingDictionary{}).Where("name = ? AND id <> ?", name, exceptId).Count(&count).Error
+	return count > 0, err
+}
+
+// loadValueMappings merges the dictionaries listed in the mappings query
+// parameter, in order, for the given settings type.
+func loadValueMappings(c *gin.Context, settingsType string) (valuemap.Dictionary, error) {
+	var ids []int
+	for _, part := range strings.Split(c.Query("mappings"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, errors.New("Mapping ids must be numeric")
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var dicts []model.ValueMappingDictionary
+	err := visibleMappings(c).Preload("Mappings").Where("id IN ?", ids).Where("settingstype IN ?", []string{"", settingsType}).Find(&dicts).Error
+	if err != nil {
+		return nil, err
+	}
+	byId := map[int]model.ValueMappingDictionary{}
+	for _, d := range dicts {
+		byId[d.ID] = d
+	}
+
+	dict := valuemap.Dictionary{}
+	for _, id := range ids {
+		d, ok := byId[id]
+		if !ok {
+			return nil, errors.New("Value mapping " + strconv.Itoa(id) + " not found")
+		}
+		for _, m := range d.Mappings {
+			dict.Add(m.KeyPath, m.Value, m.DisplayValue)
+		}
+	}
+	return dict, nil
+}
+
+// respondMappedSettings writes doc, applying or annotating value mappings
+// when the request asks for them.
+func respondMappedSettings(c *gin.Context, settingsType string, doc interface{}) {
+	mode := c.DefaultQuery("mappingmode", "apply")
+	if mode != "apply" && mode != "annotate" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mapping mode must be apply or annotate"})
+		return
+	}
+
+	dict, err := loadValueMappings(c, settingsType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if dict == nil {
+		c.JSON(http.StatusOK, doc)
+		return
+	}
+
+	generic, err := genericDocument(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode settings"})
+		return
+	}
+	if mode == "annotate" {
+		c.JSON(http.StatusOK, valuemap.Annotate(generic, dict))
+		return
+	}
+	c.JSON(http.StatusOK, valuemap.Apply(generic, dict))
+}
+
+func GetValueMappings(c *gin.Context) {
+	query := visibleMappings(c).Order("name")
+	if settingsType := c.Query("settingstype"); settingsType != "" {
+		query = query.Where("settingstype IN ?", []string{"", settingsType})
+	}
+	if owner := c.Query("owner"); owner != "" {
+		query = query.Where("owner = ?", owner)
+	}
+
+	dicts := []model.ValueMappingDictionary{}
+	if err := query.Find(&dicts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch value mappings"})
+		return
+	}
+	c.JSON(http.StatusOK, dicts)
+}
+
+func GetValueMapping(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value mapping id must be numeric"})
+		return
+	}
+
+	var dict model.ValueMappingDictionary
+	if err := visibleMappings(c).Preload("Mappings").First(&dict, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Value mapping not found"})
+		return
+	}
+	c.JSON(http.StatusOK, dict)
+}
+
+func CreateValueMapping(c *gin.Context) {
+	var req valueMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := req.entries()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	taken, err := mappingNameTaken(req.Name, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create value mapping"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Value mapping " + req.Name + " already exists"})
+		return
+	}
+
+	now := time.Now().Unix()
+	dict := model.ValueMappingDictionary{
+		Name:         req.Name,
+		Description:  req.Description,
+		SettingsType: req.SettingsType,
+		Owner:        c.GetString("email"),
+		Visibility:   req.Visibility,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Mappings:     entries,
+	}
+	if err := config.DB.Omit("id").Create(&dict).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create value mapping"})
+		return
+	}
+	c.JSON(http.StatusCreated, dict)
+}
+
+func UpdateValueMapping(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value mapping id must be numeric"})
+		return
+	}
+
+	var req valueMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := req.entries()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dict model.ValueMappingDictionary
+	if err := visibleMappings(c).First(&dict, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Value mapping not found"})
+		return
+	}
+	if !canEditMapping(c, dict) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can edit this value mapping"})
+		return
+	}
+	taken, err := mappingNameTaken(req.Name, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update value mapping"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Value mapping " + req.Name + " already exists"})
+		return
+	}
+
+	dict.Name = req.Name
+	dict.Description = req.Description
+	dict.SettingsType = req.SettingsType
+	dict.Visibility = req.Visibility
+	dict.UpdatedAt = time.Now().Unix()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dict).Select("name", "description", "settingstype", "visibility", "updatedat").Updates(&dict).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dictid = ?", id).Delete(&model.ValueMappingEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		for i := range entries {
+			entries[i].DictId = id
+		}
+		return tx.Omit("id").Create(&entries).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update value mapping"})
+		return
+	}
+
+	dict.Mappings = entries
+	c.JSON(http.StatusOK, dict)
+}
+
+func DeleteValueMapping(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value mapping id must be numeric"})
+		return
+	}
+
+	var dict model.ValueMappingDictionary
+	if err := visibleMappings(c).First(&dict, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Value mapping not found"})
+		return
+	}
+	if !canEditMapping(c, dict) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can delete this value mapping"})
+		return
+	}
+
+	if err := config.DB.Delete(&model.ValueMappingDictionary{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete value mapping"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Value mapping " + dict.Name + " deleted"})
+}
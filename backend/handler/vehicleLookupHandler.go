@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"filepackage/config"
+	"filepackage/model"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// settingsRef identifies a settings file referenced by packages. Version 0
+// refers to the current version.
+type settingsRef struct {
+	FileName string
+	Version  int
+}
+
+type lookupCanSettings struct {
+	model.CanSettings
+	Packages []string `json:"packages"`
+	Missing  bool     `json:"missing,omitempty"`
+}
+
+type lookupNrfSettings struct {
+	model.NrfSettings
+	Packages []string `json:"packages"`
+	Missing  bool     `json:"missing,omitempty"`
+}
+
+// lookupVehicles returns the catalogue entries named by the vehicleid query
+// parameter or matching the oem, model, variant and year parameters. OEM and
+// model are required in the latter case; the others narrow the match.
+func lookupVehicles(c *gin.Context) (*gorm.DB, []model.CatalogueVehicle, bool) {
+	var vehicles []model.CatalogueVehicle
+	if value := c.Query("vehicleid"); value != "" {
+		vehicleId, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vehicle id must be numeric"})
+			return nil, nil, false
+		}
+		if err := config.DB.Where("vehicleid = ?", vehicleId).Find(&vehicles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch catalogue"})
+			return nil, nil, false
+		}
+		return nil, vehicles, true
+	}
+
+	oem := strings.TrimSpace(c.Query("oem"))
+	vehicleModel := strings.TrimSpace(c.Query("model"))
+	if oem == "" || vehicleModel == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vehicle id or OEM and model are required"})
+		return nil, nil, false
+	}
+	query := config.DB.Where("LOWER(oem) = LOWER(?) AND LOWER(model) = LOWER(?)", oem, vehicleModel)
+	harnesses := config.DB.Where("vehicleid IS NULL AND LOWER(TRIM(vehicleoem)) = LOWER(?) AND LOWER(TRIM(vehiclemodel)) = LOWER(?)", oem, vehicleModel)
+	if value, ok := c.GetQuery("variant"); ok {
+		query = query.Where("LOWER(variant) = LOWER(?)", strings.TrimSpace(value))
+		harnesses = harnesses.Where("LOWER(TRIM(vehiclevariant)) = LOWER(?)", strings.TrimSpace(value))
+	}
+	if value := c.Query("year"); value != "" {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Year must be numeric"})
+			return nil, nil, false
+		}
+		query = query.Where("year = ?", year)
+		harnesses = harnesses.Where("TRIM(yearofmfg) = ?", strconv.Itoa(year))
+	}
+	if err := query.Order("vehicletype, oem, model, variant, year, fueltype, transmission, vehicleid").Find(&vehicles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch catalogue"})
+		return nil, nil, false
+	}
+	return harnesses, vehicles, true
+}
+
+// referencedSettings groups the packages by the CAN and NRF settings files
+// they reference, sorted by file name and version.
+func referencedSettings(pkgs []model.FilePackage) (can, nrf map[settingsRef][]string, canRefs, nrfRefs []settingsRef) {
+	can, nrf = map[settingsRef][]string{}, map[settingsRef][]string{}
+	for _, pkg := range pkgs {
+		if pkg.Mainsettingsname != "" {
+			ref := settingsRef{pkg.Mainsettingsname, pkg.Mainsettingsversion}
+			if _, ok := can[ref]; !ok {
+				canRefs = append(canRefs, ref)
+			}
+			can[ref] = append(can[ref], pkg.Filepackagecode)
+		}
+		if pkg.Coprocsettingsname != "" {
+			ref := settingsRef{pkg.Coprocsettingsname, pkg.Coprocsettingsversion}
+			if _, ok := nrf[ref]; !ok {
+				nrfRefs = append(nrfRefs, ref)
+			}
+			nrf[ref] = append(nrf[ref], pkg.Filepackagecode)
+		}
+	}
+	for _, refs := range [][]settingsRef{canRefs, nrfRefs} {
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].FileName != refs[j].FileName {
+				return refs[i].FileName < refs[j].FileName
+			}
+			return refs[i].Version < refs[j].Version
+		})
+	}
+	return can, nrf, canRefs, nrfRefs
+}
+
+// settingsRefQuery narrows a settings file query to the current and pinned
+// versions of the files in refs.
+func settingsRefQuery(refs []settingsRef) *gorm.DB {
+	names := []string{}
+	versions := []int{}
+	for _, ref := range refs {
+		names = append(names, ref.FileName)
+		if ref.Version > 0 {
+			versions = append(versions, ref.Version)
+		}
+	}
+	query := config.DB.Where("filename IN ?", names)
+	if len(versions) > 0 {
+		return query.Where("(iscurrent OR version IN ?)", versions)
+	}
+	return query.Where("iscurrent")
+}
+
+// LookupVehicle returns everything held for a vehicle: its catalogue
+// entries, their group models, the packages built for those models with the
+// CAN and NRF settings files they reference, and the compatible harnesses
+// with their stock. Harnesses not yet linked to the catalogue are included
+// when their fields match the query.
+func LookupVehicle(c *gin.Context) {
+	unlinked, vehicles, ok := lookupVehicles(c)
+	if !ok {
+		return
+	}
+
+	vehicleIds := []int{}
+	for _, v := range vehicles {
+		vehicleIds = append(vehicleIds, v.VehicleId)
+	}
+
+	var models []model.GroupModels
+	var harnesses []model.Harness
+	if len(vehicleIds) > 0 {
+		if err := config.DB.Where("vehicleid IN ?", vehicleIds).Order("groupname, modelid").Find(&models).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch models"})
+			return
+		}
+		if err := config.DB.Where("vehicleid IN ?", vehicleIds).Order("slno").Find(&harnesses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harnesses"})
+			return
+		}
+	}
+	if unlinked != nil {
+		var rows []model.Harness
+		if err := unlinked.Order("slno").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch harnesses"})
+			return
+		}
+		harnesses = append(harnesses, rows...)
+	}
+	if len(vehicles) == 0 && len(harnesses) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	modelIds := []int{}
+	modelResponses := make([]groupModelResponse, 0, len(models))
+	for _, m := range models {
+		modelIds = append(modelIds, m.ModelId)
+		modelResponses = append(modelResponses, toGroupModelResponse(m))
+	}
+
+	var pkgs []model.FilePackage
+	if len(modelIds) > 0 {
+		if err := config.DB.Where("modelid IN ?", modelIds).Order("filepackagecode").Find(&pkgs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+			return
+		}
+	}
+	packageResponses, err := toPackageResponses(pkgs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+		return
+	}
+
+	canPackages, nrfPackages, canRefs, nrfRefs := referencedSettings(pkgs)
+	canSettings := make([]lookupCanSettings, 0, len(canRefs))
+	if len(canRefs) > 0 {
+		var rows []model.CanSettings
+		if err := settingsRefQuery(canRefs).Omit("jsondata").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CAN settings"})
+			return
+		}
+		found := map[settingsRef]model.CanSettings{}
+		for _, row := range rows {
+			found[settingsRef{row.FileName, row.Version}] = row
+			if row.IsCurrent {
+				found[settingsRef{FileName: row.FileName}] = row
+			}
+		}
+		for _, ref := range canRefs {
+			row, ok := found[ref]
+			if !ok {
+				row = model.CanSettings{FileName: ref.FileName, Version: ref.Version}
+			}
+			canSettings = append(canSettings, lookupCanSettings{CanSettings: row, Packages: canPackages[ref], Missing: !ok})
+		}
+	}
+	nrfSettings := make([]lookupNrfSettings, 0, len(nrfRefs))
+	if len(nrfRefs) > 0 {
+		var rows []model.NrfSettings
+		if err := settingsRefQuery(nrfRefs).Omit("jsondata", "sleepcdns").Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NRF settings"})
+			return
+		}
+		found := map[settingsRef]model.NrfSettings{}
+		for _, row := range rows {
+			found[settingsRef{row.FileName, row.Version}] = row
+			if row.IsCurrent {
+				found[settingsRef{FileName: row.FileName}] = row
+			}
+		}
+		for _, ref := range nrfRefs {
+			row, ok := found[ref]
+			if !ok {
+				row = model.NrfSettings{FileName: ref.FileName, Version: ref.Version}
+			}
+			nrfSettings = append(nrfSettings, lookupNrfSettings{NrfSettings: row, Packages: nrfPackages[ref], Missing: !ok})
+		}
+	}
+
+	totalStock := 0
+	vehicleResponses := make([]catalogueVehicleResponse, 0, len(vehicles))
+	for _, v := range vehicles {
+		vehicleResponses = append(vehicleResponses, toCatalogueVehicleResponse(v))
+	}
+	harnessResponses := make([]vehicleResponse, 0, len(harnesses))
+	for _, h := range harnesses {
+		totalStock += h.CurrentStock
+		harnessResponses = append(harnessResponses, toVehicleResponse(h))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vehicles":    vehicleResponses,
+		"models":      modelResponses,
+		"packages":    packageResponses,
+		"harnesses":   harnessResponses,
+		"totalstock":  totalStock,
+		"cansettings": canSettings,
+		"nrfsettings": nrfSettings,
+	})
+}
@@ -0,0 +1,187 @@
+// Package jsondiff produces path-level differences between two decoded JSON
+// documents.
+package jsondiff
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+)
+
+type Change struct {
+	Path     string      `json:"path"`
+	Type     ChangeType  `json:"type"`
+	OldValue interface{} `json:"oldvalue,omitempty"`
+	NewValue interface{} `json:"newvalue,omitempty"`
+}
+
+type Options struct {
+	// ArrayKeys lists identifier fields used to match array elements instead
+	// of comparing them by position.
+	ArrayKeys []string
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Compare returns the changes needed to turn a into b.
+func Compare(a, b interface{}, opts Options) []Change {
+	changes := []Change{}
+	compare("$", a, b, opts, &changes)
+	return changes
+}
+
+func compare(path string, a, b interface{}, opts Options, changes *[]Change) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range unionKeys(av, bv) {
+			childPath := objectPath(path, k)
+			aChild, inA := av[k]
+			bChild, inB := bv[k]
+			switch {
+			case inA && !inB:
+				*changes = append(*changes, Change{Path: childPath, Type: Removed, OldValue: aChild})
+			case !inA && inB:
+				*changes = append(*changes, Change{Path: childPath, Type: Added, NewValue: bChild})
+			default:
+				compare(childPath, aChild, bChild, opts, changes)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if key := commonKey(av, bv, opts.ArrayKeys); key != "" {
+			compareKeyed(path, key, av, bv, opts, changes)
+			return
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bv):
+				*changes = append(*changes, Change{Path: childPath, Type: Removed, OldValue: av[i]})
+			case i >= len(av):
+				*changes = append(*changes, Change{Path: childPath, Type: Added, NewValue: bv[i]})
+			default:
+				compare(childPath, av[i], bv[i], opts, changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Type: Modified, OldValue: a, NewValue: b})
+	}
+}
+
+func compareKeyed(path, key string, a, b []interface{}, opts Options, changes *[]Change) {
+	aByKey := map[string]interface{}{}
+	bByKey := map[string]interface{}{}
+	var order []string
+
+	for _, el := range a {
+		id := keyValue(el, key)
+		aByKey[id] = el
+		order = append(order, id)
+	}
+	for _, el := range b {
+		id := keyValue(el, key)
+		bByKey[id] = el
+		if _, ok := aByKey[id]; !ok {
+			order = append(order, id)
+		}
+	}
+
+	for _, id := range order {
+		childPath := fmt.Sprintf("%s[%s=%s]", path, key, id)
+		aEl, inA := aByKey[id]
+		bEl, inB := bByKey[id]
+		switch {
+		case inA && !inB:
+			*changes = append(*changes, Change{Path: childPath, Type: Removed, OldValue: aEl})
+		case !inA && inB:
+			*changes = append(*changes, Change{Path: childPath, Type: Added, NewValue: bEl})
+		default:
+			compare(childPath, aEl, bEl, opts, changes)
+		}
+	}
+}
+
+func commonKey(a, b []interface{}, keys []string) string {
+	if len(keys) == 0 || (len(a) == 0 && len(b) == 0) {
+		return ""
+	}
+	for _, key := range keys {
+		if uniqueKeyIn(a, key) && uniqueKeyIn(b, key) {
+			return key
+		}
+	}
+	return ""
+}
+
+func uniqueKeyIn(elements []interface{}, key string) bool {
+	seen := map[string]bool{}
+	for _, el := range elements {
+		obj, ok := el.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		v, ok := obj[key]
+		if !ok {
+			return false
+		}
+		id := formatKey(v)
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+func keyValue(el interface{}, key string) string {
+	return formatKey(el.(map[string]interface{})[key])
+}
+
+func formatKey(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func objectPath(path, key string) string {
+	if identifierPattern.MatchString(key) {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%q]", path, key)
+}
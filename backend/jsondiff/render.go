@@ -0,0 +1,62 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// RenderText formats changes as a unified-diff style listing suitable for
+// pasting into review comments.
+func RenderText(changes []Change, fromLabel, toLabel string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, ch := range changes {
+		fmt.Fprintf(&sb, "@@ %s @@\n", ch.Path)
+		if ch.Type != Added {
+			writeLines(&sb, "-", ch.OldValue)
+		}
+		if ch.Type != Removed {
+			writeLines(&sb, "+", ch.NewValue)
+		}
+	}
+	return sb.String()
+}
+
+// RenderHTML formats changes as a self-contained HTML fragment.
+func RenderHTML(changes []Change, fromLabel, toLabel string) string {
+	var sb strings.Builder
+	sb.WriteString(`<pre class="jsondiff">`)
+	fmt.Fprintf(&sb, `<span class="jsondiff-header">--- %s</span>`+"\n", html.EscapeString(fromLabel))
+	fmt.Fprintf(&sb, `<span class="jsondiff-header">+++ %s</span>`+"\n", html.EscapeString(toLabel))
+	for _, ch := range changes {
+		fmt.Fprintf(&sb, `<span class="jsondiff-path">@@ %s @@</span>`+"\n", html.EscapeString(ch.Path))
+		if ch.Type != Added {
+			for _, line := range valueLines(ch.OldValue) {
+				fmt.Fprintf(&sb, `<span class="jsondiff-removed" style="color:#b91c1c">- %s</span>`+"\n", html.EscapeString(line))
+			}
+		}
+		if ch.Type != Removed {
+			for _, line := range valueLines(ch.NewValue) {
+				fmt.Fprintf(&sb, `<span class="jsondiff-added" style="color:#15803d">+ %s</span>`+"\n", html.EscapeString(line))
+			}
+		}
+	}
+	sb.WriteString("</pre>")
+	return sb.String()
+}
+
+func writeLines(sb *strings.Builder, prefix string, v interface{}) {
+	for _, line := range valueLines(v) {
+		sb.WriteString(prefix + " " + line + "\n")
+	}
+}
+
+func valueLines(v interface{}) []string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprint(v)}
+	}
+	return strings.Split(string(b), "\n")
+}
@@ -0,0 +1,258 @@
+package jsonpath
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type expr interface {
+	eval(node interface{}) bool
+}
+
+type operand interface {
+	resolve(node interface{}) (interface{}, bool)
+}
+
+type relativePath struct {
+	segments []segment
+}
+
+type literal struct {
+	value interface{}
+}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+type notExpr struct {
+	inner expr
+}
+
+type existsExpr struct {
+	path relativePath
+}
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+// Eval returns every value in doc selected by the path. doc is expected to be
+// the output of encoding/json unmarshalling into interface{}.
+func (p *Path) Eval(doc interface{}) []interface{} {
+	return apply(p.segments, []interface{}{doc})
+}
+
+func apply(segments []segment, nodes []interface{}) []interface{} {
+	for _, seg := range segments {
+		var next []interface{}
+		for _, node := range nodes {
+			next = append(next, seg.apply(node)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (s segment) apply(node interface{}) []interface{} {
+	switch s.kind {
+	case segChild:
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		var out []interface{}
+		for _, name := range s.names {
+			if v, ok := obj[name]; ok {
+				out = append(out, v)
+			}
+		}
+		return out
+	case segWildcard:
+		return children(node)
+	case segIndex:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil
+		}
+		i := s.index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i >= len(arr) {
+			return nil
+		}
+		return []interface{}{arr[i]}
+	case segRecursiveChild:
+		var out []interface{}
+		for _, d := range descendants(node, true) {
+			if obj, ok := d.(map[string]interface{}); ok {
+				if v, ok := obj[s.names[0]]; ok {
+					out = append(out, v)
+				}
+			}
+		}
+		return out
+	case segRecursiveWildcard:
+		return descendants(node, false)
+	case segFilter:
+		candidates := []interface{}{node}
+		if arr, ok := node.([]interface{}); ok {
+			candidates = arr
+		}
+		var out []interface{}
+		for _, c := range candidates {
+			if s.filter.eval(c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func children(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, v[k])
+		}
+		return out
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+func descendants(node interface{}, includeSelf bool) []interface{} {
+	var out []interface{}
+	if includeSelf {
+		out = append(out, node)
+	}
+	for _, child := range children(node) {
+		out = append(out, descendants(child, true)...)
+	}
+	return out
+}
+
+func (r relativePath) resolve(node interface{}) (interface{}, bool) {
+	values := apply(r.segments, []interface{}{node})
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+func (l literal) resolve(interface{}) (interface{}, bool) {
+	return l.value, true
+}
+
+func (e logicalExpr) eval(node interface{}) bool {
+	if e.op == "&&" {
+		return e.left.eval(node) && e.right.eval(node)
+	}
+	return e.left.eval(node) || e.right.eval(node)
+}
+
+func (e notExpr) eval(node interface{}) bool {
+	return !e.inner.eval(node)
+}
+
+func (e existsExpr) eval(node interface{}) bool {
+	_, ok := e.path.resolve(node)
+	return ok
+}
+
+func (e compareExpr) eval(node interface{}) bool {
+	left, ok := e.left.resolve(node)
+	if !ok {
+		return false
+	}
+	right, ok := e.right.resolve(node)
+	if !ok {
+		return false
+	}
+	if n, ok := hexValue(left); ok && isNumber(e.right) {
+		left = n
+	}
+	if n, ok := hexValue(right); ok && isNumber(e.left) {
+		right = n
+	}
+
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		return compareOrdered(e.op, l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		return compareOrdered(e.op, l, r)
+	case bool, nil:
+		if _, ok := right.(bool); !ok && right != nil {
+			return false
+		}
+		switch e.op {
+		case "==":
+			return left == right
+		case "!=":
+			return left != right
+		}
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+// hexValue returns the number written by a hex string such as "0x18FEF100".
+// Settings store CAN identifiers that way, so they compare with numeric
+// literals as numbers.
+func hexValue(v interface{}) (float64, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) < 3 || !strings.EqualFold(s[:2], "0x") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float64(n), true
+}
+
+func isNumber(o operand) bool {
+	lit, ok := o.(literal)
+	if !ok {
+		return false
+	}
+	_, ok = lit.value.(float64)
+	return ok
+}
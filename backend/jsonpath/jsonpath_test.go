@@ -0,0 +1,78 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const canDoc = `{
+  "cantx": {
+    "txparams": [
+      {"paramID": 1, "arbID": "0x18FEF100", "name": "Status"},
+      {"paramID": 2, "arbID": "0x18fef200", "name": "Lower"},
+      {"paramID": 3, "arbID": 419361536, "name": "Numeric"},
+      {"paramID": 4, "arbID": "18FEF100", "name": "NoPrefix"}
+    ]
+  }
+}`
+
+func names(t *testing.T, src string) []interface{} {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal([]byte(canDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+	path, err := Compile(src)
+	if err != nil {
+		t.Fatalf("Compile(%s): %v", src, err)
+	}
+	return path.Eval(doc)
+}
+
+func TestEvalComparesNumbersWithHexStrings(t *testing.T) {
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$..txparams[?(@.arbID == 0x18FEF100)].name`, []interface{}{"Status"}},
+		{`$..txparams[?(@.arbID == 419361024)].name`, []interface{}{"Status"}},
+		{`$..txparams[?(0x18FEF200 == @.arbID)].name`, []interface{}{"Lower"}},
+		{`$..txparams[?(@.arbID == 0x18FEF300)].name`, []interface{}{"Numeric"}},
+		{`$..txparams[?(@.arbID > 0x18FEF100)].name`, []interface{}{"Lower", "Numeric"}},
+		{`$..txparams[?(@.arbID != 0x18FEF100)].name`, []interface{}{"Lower", "Numeric"}},
+		{`$..txparams[?(@.arbID == "0x18FEF100")].name`, []interface{}{"Status"}},
+		{`$..txparams[?(@.arbID == "0x18fef100")].name`, nil},
+	}
+	for _, tt := range tests {
+		if got := names(t, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPostgres(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{`$.cantx.txparams[*].name`, `$."cantx"."txparams"[*]."name"`, true},
+		{`$..txparams[?(@.arbID == 0x18FEF100)]`, `$.**."txparams"[*] ? ((@."arbID" == 419361024 || @."arbID" like_regex "^0x0*18fef100$" flag "i"))`, true},
+		{`$..txparams[?(0 == @.paramID)]`, `$.**."txparams"[*] ? ((@."paramID" == 0 || @."paramID" like_regex "^0x0*0$" flag "i"))`, true},
+		{`$..txparams[?(@.name == "Status")]`, `$.**."txparams"[*] ? (@."name" == "Status")`, true},
+		{`$..txparams[?(@.arbID > 0x18FEF100)]`, "", false},
+		{`$..txparams[?(@.paramID == 1.5)]`, "", false},
+		{`$..txparams[?(@.paramID == -1)]`, "", false},
+	}
+	for _, tt := range tests {
+		path, err := Compile(tt.path)
+		if err != nil {
+			t.Fatalf("Compile(%s): %v", tt.path, err)
+		}
+		got, ok := path.Postgres()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Postgres(%s) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Package jsonpath implements the subset of JSONPath used to query settings
+// documents: child and recursive member access, wildcards, indexes, name
+// unions and filter expressions. Paths can also be translated into Postgres
+// SQL/JSON path syntax so that row selection can be pushed down to JSONB.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type segmentKind int
+
+const (
+	segChild segmentKind = iota
+	segWildcard
+	segIndex
+	segRecursiveChild
+	segRecursiveWildcard
+	segFilter
+)
+
+type segment struct {
+	kind    segmentKind
+	bracket bool
+	names   []string
+	index   int
+	filter  expr
+}
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	source   string
+	segments []segment
+}
+
+func (p *Path) String() string {
+	return p.source
+}
+
+// Compile parses a JSONPath expression such as
+// $.cantx.txparams[?(@.arbID == 0x18FEF100)].name.
+func Compile(src string) (*Path, error) {
+	p := &pathParser{src: strings.TrimSpace(src)}
+	segments, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid path at offset %d: %v", p.pos, err)
+	}
+	return &Path{source: p.src, segments: segments}, nil
+}
+
+type pathParser struct {
+	src string
+	pos int
+}
+
+func (p *pathParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) parse() ([]segment, error) {
+	if !p.consume("$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	segments, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+	return segments, nil
+}
+
+func (p *pathParser) parseSegments(relative bool) ([]segment, error) {
+	var segments []segment
+	for {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ".."):
+			p.pos += 2
+			if p.peek() == '*' {
+				p.pos++
+				segments = append(segments, segment{kind: segRecursiveWildcard})
+				continue
+			}
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment{kind: segRecursiveChild, names: []string{name}})
+		case p.peek() == '.':
+			p.pos++
+			if p.peek() == '*' {
+				p.pos++
+				segments = append(segments, segment{kind: segWildcard})
+				continue
+			}
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment{kind: segChild, names: []string{name}})
+		case p.peek() == '[':
+			p.pos++
+			seg, err := p.parseBracket(relative)
+			if err != nil {
+				return nil, err
+			}
+			if !p.consume("]") {
+				return nil, fmt.Errorf("expected ]")
+			}
+			segments = append(segments, seg)
+		default:
+			return segments, nil
+		}
+	}
+}
+
+func (p *pathParser) parseName() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		r := rune(p.src[p.pos])
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos++
+	}
+	if start == p.pos {
+		return "", fmt.Errorf("expected member name")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *pathParser) parseBracket(relative bool) (segment, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return segment{kind: segWildcard, bracket: true}, nil
+	case c == '?':
+		if relative {
+			return segment{}, fmt.Errorf("nested filters are not supported")
+		}
+		p.pos++
+		if !p.consume("(") {
+			return segment{}, fmt.Errorf("expected ( after ?")
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return segment{}, err
+		}
+		if !p.consume(")") {
+			return segment{}, fmt.Errorf("expected )")
+		}
+		return segment{kind: segFilter, filter: e}, nil
+	case c == '\'' || c == '"':
+		var names []string
+		for {
+			name, err := p.parseString()
+			if err != nil {
+				return segment{}, err
+			}
+			names = append(names, name)
+			if !p.consume(",") {
+				break
+			}
+			p.skipSpace()
+		}
+		return segment{kind: segChild, names: names}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		n, err := strconv.Atoi(p.src[start:p.pos])
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid index %q", p.src[start:p.pos])
+		}
+		return segment{kind: segIndex, index: n}, nil
+	}
+	return segment{}, fmt.Errorf("unexpected %q in brackets", p.peek())
+}
+
+func (p *pathParser) parseString() (string, error) {
+	p.skipSpace()
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", fmt.Errorf("expected string")
+	}
+	p.pos++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		if c == '\\' && !p.eof() {
+			sb.WriteByte(p.src[p.pos])
+			p.pos++
+			continue
+		}
+		if c == quote {
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *pathParser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *pathParser) parseUnary() (expr, error) {
+	if p.consume("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner: inner}, nil
+	}
+	if p.consume("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected )")
+		}
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	rel, ok := left.(relativePath)
+	if !ok {
+		return nil, fmt.Errorf("expected comparison")
+	}
+	return existsExpr{path: rel}, nil
+}
+
+func (p *pathParser) parseOperand() (operand, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@':
+		p.pos++
+		segments, err := p.parseSegments(true)
+		if err != nil {
+			return nil, err
+		}
+		return relativePath{segments: segments}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literal{value: s}, nil
+	case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	}
+
+	for word, value := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(p.src[p.pos:], word) {
+			p.pos += len(word)
+			return literal{value: value}, nil
+		}
+	}
+	return nil, fmt.Errorf("expected operand")
+}
+
+func (p *pathParser) parseNumber() (operand, error) {
+	start := p.pos
+	if p.peek() == '-' || p.peek() == '+' {
+		p.pos++
+	}
+	if strings.HasPrefix(strings.ToLower(p.src[p.pos:]), "0x") {
+		p.pos += 2
+		hexStart := p.pos
+		for !p.eof() && strings.ContainsRune("0123456789abcdefABCDEF", rune(p.peek())) {
+			p.pos++
+		}
+		n, err := strconv.ParseUint(p.src[hexStart:p.pos], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex number %q", p.src[start:p.pos])
+		}
+		v := float64(n)
+		if p.src[start] == '-' {
+			v = -v
+		}
+		return literal{value: v}, nil
+	}
+	for !p.eof() && strings.ContainsRune("0123456789.eE+-", rune(p.peek())) {
+		if (p.peek() == '+' || p.peek() == '-') && p.src[p.pos-1] != 'e' && p.src[p.pos-1] != 'E' {
+			break
+		}
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return literal{value: v}, nil
+}
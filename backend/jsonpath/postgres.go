@@ -0,0 +1,154 @@
+package jsonpath
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Postgres translates the path into a lax-mode SQL/JSON path usable with
+// jsonb_path_exists. The translation selects a superset of the documents
+// matched by Eval; ok is false when the path uses a construct that has no
+// Postgres equivalent.
+func (p *Path) Postgres() (string, bool) {
+	var sb strings.Builder
+	sb.WriteString("$")
+	if !writeSegments(&sb, p.segments) {
+		return "", false
+	}
+	return sb.String(), true
+}
+
+func writeSegments(sb *strings.Builder, segments []segment) bool {
+	for _, seg := range segments {
+		switch seg.kind {
+		case segChild:
+			if len(seg.names) != 1 {
+				return false
+			}
+			sb.WriteString("." + pgString(seg.names[0]))
+		case segWildcard:
+			if seg.bracket {
+				sb.WriteString("[*]")
+			} else {
+				sb.WriteString(".*")
+			}
+		case segIndex:
+			if seg.index < 0 {
+				fmt.Fprintf(sb, "[last - %d]", -seg.index-1)
+			} else {
+				fmt.Fprintf(sb, "[%d]", seg.index)
+			}
+		case segRecursiveChild:
+			sb.WriteString(".**." + pgString(seg.names[0]))
+		case segRecursiveWildcard:
+			sb.WriteString(".**{1 to last}")
+		case segFilter:
+			sb.WriteString("[*] ? (")
+			if !writeExpr(sb, seg.filter) {
+				return false
+			}
+			sb.WriteString(")")
+		}
+	}
+	return true
+}
+
+func writeExpr(sb *strings.Builder, e expr) bool {
+	switch v := e.(type) {
+	case logicalExpr:
+		sb.WriteString("(")
+		if !writeExpr(sb, v.left) {
+			return false
+		}
+		sb.WriteString(" " + v.op + " ")
+		if !writeExpr(sb, v.right) {
+			return false
+		}
+		sb.WriteString(")")
+	case notExpr:
+		sb.WriteString("!(")
+		if !writeExpr(sb, v.inner) {
+			return false
+		}
+		sb.WriteString(")")
+	case existsExpr:
+		sb.WriteString("exists (@")
+		if !writeSegments(sb, v.path.segments) {
+			return false
+		}
+		sb.WriteString(")")
+	case compareExpr:
+		if path, n, ok := numberComparison(v); ok {
+			return writeNumberComparison(sb, v.op, path, n)
+		}
+		if !writeOperand(sb, v.left) {
+			return false
+		}
+		sb.WriteString(" " + v.op + " ")
+		return writeOperand(sb, v.right)
+	default:
+		return false
+	}
+	return true
+}
+
+func writeOperand(sb *strings.Builder, o operand) bool {
+	switch v := o.(type) {
+	case relativePath:
+		sb.WriteString("@")
+		return writeSegments(sb, v.segments)
+	case literal:
+		switch lit := v.value.(type) {
+		case string:
+			sb.WriteString(pgString(lit))
+		case float64:
+			sb.WriteString(strconv.FormatFloat(lit, 'f', -1, 64))
+		case bool:
+			sb.WriteString(strconv.FormatBool(lit))
+		case nil:
+			sb.WriteString("null")
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// numberComparison returns the path and number of a comparison between a
+// path and a numeric literal.
+func numberComparison(e compareExpr) (relativePath, float64, bool) {
+	path, ok := e.left.(relativePath)
+	other := e.right
+	if !ok {
+		path, ok = e.right.(relativePath)
+		other = e.left
+	}
+	if !ok || !isNumber(other) {
+		return relativePath{}, 0, false
+	}
+	return path, other.(literal).value.(float64), true
+}
+
+// writeNumberComparison writes the comparison of a path with the number n
+// so that, as in Eval, hex strings written like n match too. Only equality
+// with a non-negative integer has such a translation.
+func writeNumberComparison(sb *strings.Builder, op string, path relativePath, n float64) bool {
+	if op != "==" || n < 0 || n != math.Trunc(n) || n >= 1<<53 {
+		return false
+	}
+	var at strings.Builder
+	at.WriteString("@")
+	if !writeSegments(&at, path.segments) {
+		return false
+	}
+	fmt.Fprintf(sb, `(%s == %d || %s like_regex "^0x0*%x$" flag "i")`, at.String(), uint64(n), at.String(), uint64(n))
+	return true
+}
+
+func pgString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,291 @@
+package lint
+
+import (
+	"encoding/json"
+	"filepackage/model"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	maxStandardId = 0x7FF
+	maxSignalBits = 512
+)
+
+func init() {
+	Register(Rule{
+		ID:          "can.message-id-unique",
+		Kind:        KindCan,
+		Severity:    Error,
+		Description: "Each CAN message identifier is defined once",
+		Check:       checkMessageIdsUnique,
+	})
+	Register(Rule{
+		ID:          "can.message-id-range",
+		Kind:        KindCan,
+		Severity:    Error,
+		Description: "Standard identifiers fit in 11 bits and extended identifiers in 29 bits",
+		Check:       checkMessageIdRange,
+	})
+	Register(Rule{
+		ID:          "can.rx-filter-overlap",
+		Kind:        KindCan,
+		Severity:    Warning,
+		Description: "Receive filters do not accept the same identifiers and filter ids are not repeated",
+		Check:       checkRxFilterOverlap,
+	})
+	Register(Rule{
+		ID:          "can.signal-bounds",
+		Kind:        KindCan,
+		Severity:    Error,
+		Description: "Signal bit ranges lie within the message DLC",
+		Params:      Params{"dlcs": []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8}},
+		Check:       checkSignalBounds,
+	})
+	Register(Rule{
+		ID:          "can.signal-overlap",
+		Kind:        KindCan,
+		Severity:    Warning,
+		Description: "Signals of a message do not share bits unless they belong to different multiplex values",
+		Check:       checkSignalOverlap,
+	})
+	Register(Rule{
+		ID:          "can.baudrate",
+		Kind:        KindCan,
+		Severity:    Error,
+		Description: "Baud rates are taken from the allowed set",
+		Params:      Params{"allowed": []interface{}{125000, 250000, 500000, 1000000}},
+		Check:       checkBaudRate,
+	})
+}
+
+func checkMessageIdsUnique(t Target, p Params, report Report) {
+	if t.Can == nil {
+		return
+	}
+	type key struct {
+		id       uint32
+		extended bool
+	}
+	messages, _ := t.Can.CanMessages()
+	seen := map[key]model.CanParamMessage{}
+	for _, m := range messages {
+		k := key{m.Message.ID, m.Message.Extended}
+		if first, ok := seen[k]; ok {
+			report(m.Path, "Message %s uses identifier 0x%X already used by %s (%s)", m.Message.Name, m.Message.ID, first.Message.Name, first.Path)
+			continue
+		}
+		seen[k] = m
+	}
+}
+
+func checkMessageIdRange(t Target, p Params, report Report) {
+	if t.Can == nil {
+		return
+	}
+	messages, invalid := t.Can.CanMessages()
+	for _, path := range invalid {
+		report(path, "Parameter does not have a CAN identifier of at most 0x1FFFFFFF")
+	}
+	for _, m := range messages {
+		if !m.Message.Extended && m.Message.ID > maxStandardId {
+			report(m.Path, "Message %s has standard identifier 0x%X above 0x7FF", m.Message.Name, m.Message.ID)
+		}
+	}
+}
+
+func checkRxFilterOverlap(t Target, p Params, report Report) {
+	if t.Can == nil || t.Can.CanRx == nil {
+		return
+	}
+	rx := t.Can.CanRx
+
+	seen := map[uint64]int{}
+	for i, f := range rx.FilterIds {
+		path := fmt.Sprintf("canrx.filterids[%d].filterid", i)
+		id, err := f.FilterId.Uint64()
+		if err != nil {
+			report(path, "Filter id %s is not a number", f.FilterId.String())
+			continue
+		}
+		if first, ok := seen[id]; ok {
+			report(path, "Filter id 0x%X repeats canrx.filterids[%d]", id, first)
+			continue
+		}
+		seen[id] = i
+	}
+
+	type filter struct {
+		index        int
+		mask, filter uint64
+	}
+	var filters []filter
+	for i, param := range rx.RxParams {
+		mask, maskErr := param.ArbIDMask.Uint64()
+		value, filterErr := param.ArbIDFilter.Uint64()
+		if maskErr != nil || filterErr != nil {
+			report(fmt.Sprintf("canrx.rxparams[%d]", i), "Parameter %s has a non-numeric arbitration mask or filter", param.ParamID.String())
+			continue
+		}
+		filters = append(filters, filter{i, mask, value})
+	}
+	for a := 0; a < len(filters); a++ {
+		for b := a + 1; b < len(filters); b++ {
+			fa, fb := filters[a], filters[b]
+			common := fa.mask & fb.mask
+			if (fa.filter^fb.filter)&common == 0 {
+				report(fmt.Sprintf("canrx.rxparams[%d]", fb.index), "Arbitration filter 0x%X/0x%X accepts identifiers also accepted by canrx.rxparams[%d] (0x%X/0x%X)", fb.filter, fb.mask, fa.index, fa.filter, fa.mask)
+			}
+		}
+	}
+}
+
+// signalBits returns the absolute bit positions covered by s, capped at the
+// size of a CAN FD frame. Big endian signals start at their most significant
+// bit and continue in DBC sawtooth order.
+func signalBits(s model.CanSignal) []int {
+	length := s.Length
+	if length > maxSignalBits {
+		length = maxSignalBits
+	}
+	bits := make([]int, 0, length)
+	pos := s.StartBit
+	for i := 0; i < length; i++ {
+		bits = append(bits, pos)
+		if s.ByteOrder == model.ByteOrderBigEndian {
+			if pos%8 == 0 {
+				pos += 15
+			} else {
+				pos--
+			}
+		} else {
+			pos++
+		}
+	}
+	return bits
+}
+
+func checkSignalBounds(t Target, p Params, report Report) {
+	if t.Can == nil {
+		return
+	}
+	dlcs := map[int64]bool{}
+	for _, d := range p.Int64s("dlcs") {
+		dlcs[d] = true
+	}
+
+	messages, _ := t.Can.CanMessages()
+	for _, m := range messages {
+		msg := m.Message
+		if len(dlcs) > 0 && !dlcs[int64(msg.DLC)] {
+			report(m.Path+".dlc", "Message %s has unsupported DLC %d", msg.Name, msg.DLC)
+		}
+		size := msg.DLC * 8
+		for j, s := range msg.Signals {
+			path := fmt.Sprintf("%s.signals[%d]", m.Path, j)
+			if s.Length <= 0 || s.StartBit < 0 {
+				report(path, "Signal %s.%s has start bit %d and length %d", msg.Name, s.Name, s.StartBit, s.Length)
+				continue
+			}
+			for _, bit := range signalBits(s) {
+				if bit < 0 || bit >= size {
+					report(path, "Signal %s.%s (start bit %d, length %d) does not fit in %d bytes", msg.Name, s.Name, s.StartBit, s.Length, msg.DLC)
+					break
+				}
+			}
+		}
+	}
+}
+
+func multiplexConflict(a, b model.CanSignal) bool {
+	if a.MultiplexValue == nil || b.MultiplexValue == nil {
+		return true
+	}
+	return *a.MultiplexValue == *b.MultiplexValue
+}
+
+func checkSignalOverlap(t Target, p Params, report Report) {
+	if t.Can == nil {
+		return
+	}
+	messages, _ := t.Can.CanMessages()
+	for _, m := range messages {
+		msg := m.Message
+		bits := make([]map[int]bool, len(msg.Signals))
+		for j, s := range msg.Signals {
+			bits[j] = map[int]bool{}
+			for _, bit := range signalBits(s) {
+				bits[j][bit] = true
+			}
+		}
+		for a := 0; a < len(msg.Signals); a++ {
+			for b := a + 1; b < len(msg.Signals); b++ {
+				if !multiplexConflict(msg.Signals[a], msg.Signals[b]) {
+					continue
+				}
+				for bit := range bits[b] {
+					if bits[a][bit] {
+						report(fmt.Sprintf("%s.signals[%d]", m.Path, b), "Signal %s.%s overlaps %s", msg.Name, msg.Signals[b].Name, msg.Signals[a].Name)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// baudRates collects the top-level baudrate member of older documents and
+// any globalconfig member whose name mentions baud.
+func baudRates(d *model.CanSettingsData) map[string]json.Number {
+	rates := map[string]json.Number{}
+	if raw, ok := d.Extra("baudrate"); ok {
+		var n json.Number
+		if json.Unmarshal(raw, &n) == nil {
+			rates["baudrate"] = n
+		}
+	}
+	raw, ok := d.Extra("globalconfig")
+	if !ok {
+		return rates
+	}
+	var global map[string]json.RawMessage
+	if json.Unmarshal(raw, &global) != nil {
+		return rates
+	}
+	for name, value := range global {
+		if !strings.Contains(strings.ToLower(name), "baud") {
+			continue
+		}
+		var n json.Number
+		if json.Unmarshal(value, &n) == nil {
+			rates["globalconfig."+name] = n
+		}
+	}
+	return rates
+}
+
+func checkBaudRate(t Target, p Params, report Report) {
+	if t.Can == nil {
+		return
+	}
+	allowed := map[int64]bool{}
+	for _, rate := range p.Int64s("allowed") {
+		allowed[rate] = true
+	}
+	rates := baudRates(t.Can)
+	paths := make([]string, 0, len(rates))
+	for path := range rates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		n, err := rates[path].Int64()
+		if err != nil || n == 0 {
+			continue
+		}
+		if !allowed[n] {
+			report(path, "Baud rate %d is not one of %v", n, p.Int64s("allowed"))
+		}
+	}
+}
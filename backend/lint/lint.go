@@ -0,0 +1,177 @@
+// Package lint checks settings documents against domain rules that a JSON
+// schema cannot express, such as overlapping CAN identifiers or signals that
+// do not fit their message. Rules are registered per settings kind and can be
+// enabled, disabled, re-graded or parameterised through a Config.
+package lint
+
+import (
+	"encoding/json"
+	"filepackage/model"
+	"fmt"
+	"sort"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+
+	KindCan = "can"
+	KindNrf = "nrf"
+)
+
+var severityRank = map[Severity]int{Error: 0, Warning: 1, Info: 2}
+
+// ValidSeverity reports whether s is one of the known severities.
+func ValidSeverity(s Severity) bool {
+	_, ok := severityRank[s]
+	return ok
+}
+
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Target is the document being linted. Signals holds the signal names the
+// document may refer to besides its own, e.g. those of the CAN settings that
+// share a package with an NRF file.
+type Target struct {
+	Can     *model.CanSettingsData
+	Nrf     *model.NrfSettingsData
+	Signals map[string]bool
+}
+
+// Report records a finding at path. The rule id and severity are filled in
+// by the engine.
+type Report func(path, format string, args ...interface{})
+
+type Rule struct {
+	ID          string                                  `json:"id"`
+	Kind        string                                  `json:"kind"`
+	Severity    Severity                                `json:"severity"`
+	Description string                                  `json:"description"`
+	Params      Params                                  `json:"params,omitempty"`
+	Check       func(t Target, p Params, report Report) `json:"-"`
+}
+
+// Config overrides the defaults of one rule. A nil Enabled keeps the rule
+// on, an empty Severity keeps the rule's own, and Params are merged over the
+// rule's default params.
+type Config struct {
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+	Params   Params   `json:"params,omitempty"`
+}
+
+var rules = map[string]Rule{}
+
+// Register adds r to the rule set, replacing any rule with the same id.
+func Register(r Rule) {
+	rules[r.ID] = r
+}
+
+// Rules returns the registered rules for kind, or all rules when kind is
+// empty, ordered by id.
+func Rules(kind string) []Rule {
+	list := []Rule{}
+	for _, r := range rules {
+		if kind == "" || r.Kind == kind {
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Lookup returns the rule registered under id.
+func Lookup(id string) (Rule, bool) {
+	r, ok := rules[id]
+	return r, ok
+}
+
+// Run applies the enabled rules for kind to t and returns the findings,
+// most severe first.
+func Run(kind string, t Target, configs map[string]Config) []Finding {
+	findings := []Finding{}
+	for _, r := range Rules(kind) {
+		cfg := configs[r.ID]
+		if cfg.Enabled != nil && !*cfg.Enabled {
+			continue
+		}
+		severity := r.Severity
+		if cfg.Severity != "" {
+			severity = cfg.Severity
+		}
+		params := r.Params.merge(cfg.Params)
+
+		r.Check(t, params, func(path, format string, args ...interface{}) {
+			findings = append(findings, Finding{Rule: r.ID, Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+		})
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] < severityRank[findings[j].Severity]
+	})
+	return findings
+}
+
+// HasErrors reports whether any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Params are the rule settings decoded from JSON.
+type Params map[string]interface{}
+
+func (p Params) merge(over Params) Params {
+	merged := Params{}
+	for k, v := range p {
+		merged[k] = v
+	}
+	for k, v := range over {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Int64s returns the integers listed under key.
+func (p Params) Int64s(key string) []int64 {
+	list, _ := p[key].([]interface{})
+	values := make([]int64, 0, len(list))
+	for _, v := range list {
+		switch n := v.(type) {
+		case float64:
+			values = append(values, int64(n))
+		case int:
+			values = append(values, int64(n))
+		case int64:
+			values = append(values, n)
+		case json.Number:
+			if i, err := n.Int64(); err == nil {
+				values = append(values, i)
+			}
+		}
+	}
+	return values
+}
+
+// Strings returns the strings listed under key.
+func (p Params) Strings(key string) []string {
+	list, _ := p[key].([]interface{})
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
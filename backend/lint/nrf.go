@@ -0,0 +1,110 @@
+package lint
+
+import (
+	"encoding/json"
+	"filepackage/model"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// conditionName matches the signal a sleep condition refers to, e.g. vbat_v
+// in "vbat_v>".
+var conditionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*`)
+
+func init() {
+	Register(Rule{
+		ID:          "nrf.sleep-condition-signals",
+		Kind:        KindNrf,
+		Severity:    Warning,
+		Description: "Sleep conditions refer to sleep settings, signals of the paired CAN settings or the configured signals",
+		Params:      Params{"signals": []interface{}{}},
+		Check:       checkSleepConditionSignals,
+	})
+	Register(Rule{
+		ID:          "nrf.sleep-threshold-set",
+		Kind:        KindNrf,
+		Severity:    Warning,
+		Description: "Sleep conditions on a threshold have a non-zero value for that transition",
+		Check:       checkSleepThresholdSet,
+	})
+}
+
+func sleepSettingValues(s model.SleepSetting) map[string]json.Number {
+	return map[string]json.Number{
+		"ain0_v":        s.Ain0V,
+		"ain1_v":        s.Ain1V,
+		"vbat_v":        s.VbatV,
+		"vin_v":         s.VinV,
+		"hysteresis":    s.Hysteresis,
+		"mov_timeout":   s.MovTimeout,
+		"state_timeout": s.StateTimeout,
+	}
+}
+
+func sortedTransitions(cdns model.SleepConditions) []string {
+	names := make([]string, 0, len(cdns))
+	for name := range cdns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eachCondition calls fn for the and/or conditions of every transition.
+func eachCondition(cdns model.SleepConditions, fn func(transition, path, name string)) {
+	for _, transition := range sortedTransitions(cdns) {
+		cdn := cdns[transition]
+		lists := []struct {
+			key   string
+			names []string
+		}{{"cdn_and", cdn.And}, {"cdn_or", cdn.Or}}
+		for _, list := range lists {
+			for i, condition := range list.names {
+				path := fmt.Sprintf("sleepcdns.%s.%s[%d]", transition, list.key, i)
+				fn(transition, path, conditionName.FindString(condition))
+			}
+		}
+	}
+}
+
+func checkSleepConditionSignals(t Target, p Params, report Report) {
+	if t.Nrf == nil {
+		return
+	}
+	known := map[string]bool{}
+	for name := range sleepSettingValues(model.SleepSetting{}) {
+		known[name] = true
+	}
+	for name := range t.Signals {
+		known[name] = true
+	}
+	for _, name := range p.Strings("signals") {
+		known[name] = true
+	}
+
+	eachCondition(t.Nrf.SleepCdns, func(transition, path, name string) {
+		if name != "" && !known[name] {
+			report(path, "Condition for %s refers to undefined signal %s", transition, name)
+		}
+	})
+}
+
+func checkSleepThresholdSet(t Target, p Params, report Report) {
+	if t.Nrf == nil {
+		return
+	}
+	eachCondition(t.Nrf.SleepCdns, func(transition, path, name string) {
+		setting, ok := t.Nrf.SleepSettings[transition]
+		if !ok {
+			return
+		}
+		value, ok := sleepSettingValues(setting)[name]
+		if !ok {
+			return
+		}
+		if f, err := value.Float64(); err != nil || f == 0 {
+			report(path, "Condition for %s uses %s but sleepsettings.%s.%s is not set", transition, name, transition, name)
+		}
+	})
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"filepackage/config"
+	"filepackage/handler"
+	"filepackage/notify"
 	"filepackage/routes"
 	"filepackage/utils"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,6 +19,20 @@ func main() {
 	utils.LoadEnv()
 	port := os.Getenv("PORT")
 	config.ConnectDatabase(os.Getenv("DATABASE_URL"))
+	if err := config.RunMigrations(config.DB); err != nil {
+		log.Fatalf("Error running migrations: %v", err)
+	}
+	alertInterval := 15 * time.Minute
+	if value := os.Getenv("STOCK_ALERT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid STOCK_ALERT_INTERVAL: %v", err)
+		}
+		alertInterval = interval
+	}
+	if alertInterval > 0 {
+		handler.StartStockAlerts(alertInterval, notify.FromEnv())
+	}
 	allowedOrigins := os.Getenv("FRONTEND_DOMAIN")
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
@@ -35,5 +53,11 @@ func main() {
 	routes.HarnessRoutes(router)
 	routes.FileRoutes(router)
 	routes.NrfSettingsRoutes(router)
+	routes.SettingsRoutes(router)
+	routes.SchemaRoutes(router)
+	routes.ValueMappingRoutes(router)
+	routes.LintRoutes(router)
+	routes.TemplateRoutes(router)
+	routes.CatalogueRoutes(router)
 	router.Run(":" + port)
 }
@@ -0,0 +1,45 @@
+package model
+
+type CanDocument struct {
+	Version     string                      `json:"version,omitempty"`
+	BaudRate    int                         `json:"baudrate,omitempty"`
+	Nodes       []string                    `json:"nodes,omitempty"`
+	Messages    []CanMessage                `json:"messages"`
+	ValueTables map[string]map[int64]string `json:"valuetables,omitempty"`
+	Comment     string                      `json:"comment,omitempty"`
+}
+
+type CanMessage struct {
+	ID          uint32      `json:"id"`
+	Extended    bool        `json:"extended"`
+	Name        string      `json:"name"`
+	DLC         int         `json:"dlc"`
+	Transmitter string      `json:"transmitter,omitempty"`
+	CycleTime   int         `json:"cycletime,omitempty"`
+	Signals     []CanSignal `json:"signals"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type CanSignal struct {
+	Name           string           `json:"name"`
+	StartBit       int              `json:"startbit"`
+	Length         int              `json:"length"`
+	ByteOrder      string           `json:"byteorder"`
+	Signed         bool             `json:"signed"`
+	Factor         float64          `json:"factor"`
+	Offset         float64          `json:"offset"`
+	Min            float64          `json:"min"`
+	Max            float64          `json:"max"`
+	Unit           string           `json:"unit,omitempty"`
+	Receivers      []string         `json:"receivers,omitempty"`
+	Multiplexer    bool             `json:"multiplexer,omitempty"`
+	MultiplexValue *int             `json:"multiplexvalue,omitempty"`
+	ValueTable     string           `json:"valuetable,omitempty"`
+	Values         map[int64]string `json:"values,omitempty"`
+	Comment        string           `json:"comment,omitempty"`
+}
+
+const (
+	ByteOrderLittleEndian = "little_endian"
+	ByteOrderBigEndian    = "big_endian"
+)
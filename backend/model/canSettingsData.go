@@ -0,0 +1,142 @@
+package model
+
+// CanSettingsData is the document stored in CanSettings.JSONData. The CAN
+// transmit and receive configuration is typed; other sections such as
+// globalconfig are kept as they were uploaded. Dbc holds what a DBC import
+// carries beyond the frames, so that exports reproduce it.
+type CanSettingsData struct {
+	Members
+	CanTx *CanTxConfig `json:"cantx"`
+	CanRx *CanRxConfig `json:"canrx"`
+	Dbc   *CanDbcInfo  `json:"dbc"`
+}
+
+func (d *CanSettingsData) UnmarshalJSON(data []byte) error {
+	type plain CanSettingsData
+	m, err := decodeMembers(data, (*plain)(d))
+	d.Members = m
+	return err
+}
+
+func (d CanSettingsData) MarshalJSON() ([]byte, error) {
+	type plain CanSettingsData
+	return encodeMembers(plain(d), d.Members)
+}
+
+type CanDbcInfo struct {
+	Version     string                      `json:"version,omitempty"`
+	Nodes       []string                    `json:"nodes,omitempty"`
+	ValueTables map[string]map[int64]string `json:"valuetables,omitempty"`
+	Comment     string                      `json:"comment,omitempty"`
+}
+
+type CanTxConfig struct {
+	Members
+	TxParams []CanTxParam `json:"txparams"`
+}
+
+func (c *CanTxConfig) UnmarshalJSON(data []byte) error {
+	type plain CanTxConfig
+	m, err := decodeMembers(data, (*plain)(c))
+	c.Members = m
+	return err
+}
+
+func (c CanTxConfig) MarshalJSON() ([]byte, error) {
+	type plain CanTxConfig
+	return encodeMembers(plain(c), c.Members)
+}
+
+// CanTxParam is a frame the device transmits every TxInterval milliseconds.
+// The fields after TxInterval describe the frame for DBC export.
+type CanTxParam struct {
+	Members
+	ParamID     Scalar      `json:"paramID"`
+	ArbID       Scalar      `json:"arbID"`
+	TxInterval  Scalar      `json:"txInterval"`
+	Name        string      `json:"name"`
+	Extended    bool        `json:"extended"`
+	DLC         int         `json:"dlc"`
+	Transmitter string      `json:"transmitter"`
+	Comment     string      `json:"comment"`
+	Signals     []CanSignal `json:"signals"`
+}
+
+func (p *CanTxParam) UnmarshalJSON(data []byte) error {
+	type plain CanTxParam
+	m, err := decodeMembers(data, (*plain)(p))
+	p.Members = m
+	return err
+}
+
+func (p CanTxParam) MarshalJSON() ([]byte, error) {
+	type plain CanTxParam
+	return encodeMembers(plain(p), p.Members)
+}
+
+type CanRxConfig struct {
+	Members
+	FilterMasks []Scalar      `json:"filtermasks"`
+	FilterIds   []CanFilterId `json:"filterids"`
+	RxParams    []CanRxParam  `json:"rxparams"`
+}
+
+func (c *CanRxConfig) UnmarshalJSON(data []byte) error {
+	type plain CanRxConfig
+	m, err := decodeMembers(data, (*plain)(c))
+	c.Members = m
+	return err
+}
+
+func (c CanRxConfig) MarshalJSON() ([]byte, error) {
+	type plain CanRxConfig
+	return encodeMembers(plain(c), c.Members)
+}
+
+type CanFilterId struct {
+	Members
+	FilterRef Scalar `json:"filterref"`
+	FilterId  Scalar `json:"filterid"`
+}
+
+func (f *CanFilterId) UnmarshalJSON(data []byte) error {
+	type plain CanFilterId
+	m, err := decodeMembers(data, (*plain)(f))
+	f.Members = m
+	return err
+}
+
+func (f CanFilterId) MarshalJSON() ([]byte, error) {
+	type plain CanFilterId
+	return encodeMembers(plain(f), f.Members)
+}
+
+// CanRxParam is a received frame sampled every SnapshotInterval
+// milliseconds. The fields after FilterIDRef describe the frame for DBC
+// export.
+type CanRxParam struct {
+	Members
+	ParamID          Scalar      `json:"paramID"`
+	ArbIDMask        Scalar      `json:"arbIDMask"`
+	ArbIDFilter      Scalar      `json:"arbIDFilter"`
+	SnapshotInterval Scalar      `json:"snapshotInterval"`
+	FilterIDRef      Scalar      `json:"filterIDRef"`
+	Name             string      `json:"name"`
+	Extended         bool        `json:"extended"`
+	DLC              int         `json:"dlc"`
+	Transmitter      string      `json:"transmitter"`
+	Comment          string      `json:"comment"`
+	Signals          []CanSignal `json:"signals"`
+}
+
+func (p *CanRxParam) UnmarshalJSON(data []byte) error {
+	type plain CanRxParam
+	m, err := decodeMembers(data, (*plain)(p))
+	p.Members = m
+	return err
+}
+
+func (p CanRxParam) MarshalJSON() ([]byte, error) {
+	type plain CanRxParam
+	return encodeMembers(plain(p), p.Members)
+}
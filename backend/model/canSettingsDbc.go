@@ -0,0 +1,197 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	canStandardIdMask = 0x7FF
+	canExtendedIdMask = 0x1FFFFFFF
+	defaultCanDLC     = 8
+
+	// canFiltersPerMask is how many canrx.filterids share each filter mask.
+	canFiltersPerMask = 4
+)
+
+// CanParamMessage is the DBC message a transmit or receive parameter
+// describes, with the path of the parameter in the document.
+type CanParamMessage struct {
+	Path    string
+	ParamID Scalar
+	Message CanMessage
+}
+
+func scalarInt(n int) Scalar {
+	return Scalar(strconv.Itoa(n))
+}
+
+func scalarHex(n uint64) Scalar {
+	return Scalar(strconv.Quote(fmt.Sprintf("0x%X", n)))
+}
+
+// CanSettingsFromDocument lays out a parsed DBC file as CAN settings.
+// Messages sent by node become transmit parameters; the others become
+// receive parameters, each accepted by an exact-match filter id, with the
+// filter ids grouped four to a mask. Parameters are numbered in file order.
+func CanSettingsFromDocument(doc CanDocument, node string) CanSettingsData {
+	d := CanSettingsData{
+		CanTx: &CanTxConfig{TxParams: []CanTxParam{}},
+		CanRx: &CanRxConfig{FilterMasks: []Scalar{}, FilterIds: []CanFilterId{}, RxParams: []CanRxParam{}},
+	}
+	if doc.BaudRate > 0 {
+		global, _ := json.Marshal(map[string]int{"baudrate": doc.BaudRate})
+		d.extra = map[string]json.RawMessage{"globalconfig": global}
+	}
+	if doc.Version != "" || len(doc.Nodes) > 0 || len(doc.ValueTables) > 0 || doc.Comment != "" {
+		d.Dbc = &CanDbcInfo{Version: doc.Version, Nodes: doc.Nodes, ValueTables: doc.ValueTables, Comment: doc.Comment}
+	}
+
+	var extended []bool
+	for i, msg := range doc.Messages {
+		signals := msg.Signals
+		if signals == nil {
+			signals = []CanSignal{}
+		}
+		if node != "" && msg.Transmitter == node {
+			d.CanTx.TxParams = append(d.CanTx.TxParams, CanTxParam{
+				ParamID:     scalarInt(i + 1),
+				ArbID:       scalarHex(uint64(msg.ID)),
+				TxInterval:  scalarInt(msg.CycleTime),
+				Name:        msg.Name,
+				Extended:    msg.Extended,
+				DLC:         msg.DLC,
+				Transmitter: msg.Transmitter,
+				Comment:     msg.Comment,
+				Signals:     signals,
+			})
+			continue
+		}
+
+		mask := uint64(canStandardIdMask)
+		if msg.Extended {
+			mask = canExtendedIdMask
+		}
+		ref := scalarInt(len(d.CanRx.FilterIds) + 1)
+		d.CanRx.FilterIds = append(d.CanRx.FilterIds, CanFilterId{FilterRef: ref, FilterId: scalarHex(uint64(msg.ID))})
+		extended = append(extended, msg.Extended)
+		d.CanRx.RxParams = append(d.CanRx.RxParams, CanRxParam{
+			ParamID:          scalarInt(i + 1),
+			ArbIDMask:        scalarHex(mask),
+			ArbIDFilter:      scalarHex(uint64(msg.ID)),
+			SnapshotInterval: scalarInt(msg.CycleTime),
+			FilterIDRef:      ref,
+			Name:             msg.Name,
+			Extended:         msg.Extended,
+			DLC:              msg.DLC,
+			Transmitter:      msg.Transmitter,
+			Comment:          msg.Comment,
+			Signals:          signals,
+		})
+	}
+
+	for start := 0; start < len(extended); start += canFiltersPerMask {
+		mask := uint64(canStandardIdMask)
+		for i := start; i < start+canFiltersPerMask && i < len(extended); i++ {
+			if extended[i] {
+				mask = canExtendedIdMask
+			}
+		}
+		d.CanRx.FilterMasks = append(d.CanRx.FilterMasks, scalarHex(mask))
+	}
+	return d
+}
+
+// paramMessage fills in what a parameter leaves out of its message: the
+// name defaults to the direction and parameter id, the DLC to 8, and
+// identifiers above 11 bits are extended unless the parameter says otherwise.
+func paramMessage(msg CanMessage, direction string, paramID Scalar, hasExtended bool, interval Scalar) CanMessage {
+	if msg.Name == "" {
+		msg.Name = direction + "_" + paramID.String()
+	}
+	if msg.DLC == 0 {
+		msg.DLC = defaultCanDLC
+	}
+	if !hasExtended && msg.ID > canStandardIdMask {
+		msg.Extended = true
+	}
+	if cycle, err := interval.Uint64(); err == nil {
+		msg.CycleTime = int(cycle)
+	}
+	if msg.Signals == nil {
+		msg.Signals = []CanSignal{}
+	}
+	return msg
+}
+
+// CanMessages returns the DBC messages described by the transmit and then
+// the receive parameters. Parameters whose identifier is not a number are
+// left out and their paths returned in invalid.
+func (d CanSettingsData) CanMessages() (messages []CanParamMessage, invalid []string) {
+	if d.CanTx != nil {
+		for i, p := range d.CanTx.TxParams {
+			path := fmt.Sprintf("cantx.txparams[%d]", i)
+			id, err := p.ArbID.Uint64()
+			if err != nil || id > canExtendedIdMask {
+				invalid = append(invalid, path)
+				continue
+			}
+			msg := CanMessage{ID: uint32(id), Extended: p.Extended, Name: p.Name, DLC: p.DLC, Transmitter: p.Transmitter, Comment: p.Comment, Signals: p.Signals}
+			messages = append(messages, CanParamMessage{path, p.ParamID, paramMessage(msg, "TX", p.ParamID, p.present["extended"], p.TxInterval)})
+		}
+	}
+	if d.CanRx != nil {
+		for i, p := range d.CanRx.RxParams {
+			path := fmt.Sprintf("canrx.rxparams[%d]", i)
+			id, err := p.ArbIDFilter.Uint64()
+			if err != nil || id > canExtendedIdMask {
+				invalid = append(invalid, path)
+				continue
+			}
+			msg := CanMessage{ID: uint32(id), Extended: p.Extended, Name: p.Name, DLC: p.DLC, Transmitter: p.Transmitter, Comment: p.Comment, Signals: p.Signals}
+			messages = append(messages, CanParamMessage{path, p.ParamID, paramMessage(msg, "RX", p.ParamID, p.present["extended"], p.SnapshotInterval)})
+		}
+	}
+	return messages, invalid
+}
+
+// baudRate returns globalconfig.baudrate, or the top-level baudrate of
+// older documents.
+func (d CanSettingsData) baudRate() int {
+	var global struct {
+		BaudRate json.Number `json:"baudrate"`
+	}
+	if raw, ok := d.Extra("globalconfig"); ok && json.Unmarshal(raw, &global) == nil && global.BaudRate != "" {
+		rate, _ := global.BaudRate.Int64()
+		return int(rate)
+	}
+	var rate int
+	if raw, ok := d.Extra("baudrate"); ok {
+		json.Unmarshal(raw, &rate)
+	}
+	return rate
+}
+
+// CanDocument returns the DBC view of the settings, with the messages in
+// parameter id order so that imported files export in their original order.
+func (d CanSettingsData) CanDocument() (CanDocument, error) {
+	doc := CanDocument{BaudRate: d.baudRate(), Messages: []CanMessage{}}
+	if d.Dbc != nil {
+		doc.Version, doc.Nodes, doc.ValueTables, doc.Comment = d.Dbc.Version, d.Dbc.Nodes, d.Dbc.ValueTables, d.Dbc.Comment
+	}
+	messages, invalid := d.CanMessages()
+	if len(invalid) > 0 {
+		return doc, fmt.Errorf("%s does not have a numeric CAN identifier", invalid[0])
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, errA := messages[i].ParamID.Uint64()
+		b, errB := messages[j].ParamID.Uint64()
+		return errA == nil && (errB != nil || a < b)
+	})
+	for _, m := range messages {
+		doc.Messages = append(doc.Messages, m.Message)
+	}
+	return doc, nil
+}
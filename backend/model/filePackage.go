@@ -19,8 +19,10 @@ type FilePackage struct {
 	Mainfirmware           string `gorm:"column:mainfirmware" json:"mainfirmware"`
 	Mainsettingsname       string `gorm:"column:mainsettingsname" json:"mainsettingsname"`
 	Mainsettingsid         string `gorm:"column:mainsettingsid" json:"mainsettingsid"`
+	Mainsettingsversion    int    `gorm:"column:mainsettingsversion" json:"mainsettingsversion"`
 	Coprocfirmware         string `gorm:"column:coprocfirmware" json:"coprocfirmware"`
 	Coprocsettingsname     string `gorm:"column:coprocsettingsname" json:"coprocsettingsname"`
+	Coprocsettingsversion  int    `gorm:"column:coprocsettingsversion" json:"coprocsettingsversion"`
 	Plsign                 string `gorm:"column:plsign" json:"plsign"`
 	Isvalid                bool   `gorm:"column:isvalid" json:"isvalid"`
 	Updatedby              string `gorm:"column:updatedby" json:"updatedby"`
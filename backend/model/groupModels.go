@@ -11,8 +11,22 @@ type GroupModels struct {
 	Year         int    `gorm:"column:year" json:"year"`
 	FuelType     string `gorm:"column:fueltype" json:"fueltype"`
 	Transmission string `gorm:"column:transmission" json:"transmission"`
+	VehicleId    *int   `gorm:"column:vehicleid" json:"vehicleid"`
 }
 
 func (GroupModels) TableName() string {
 	return "LAFPackages.groupmodels"
 }
+
+// VehicleGroup names a group of vehicle models. Models reference it by
+// GroupId and carry a copy of its name.
+type VehicleGroup struct {
+	GroupId   int    `gorm:"column:groupid;primary_key;autoIncrement:false" json:"groupid"`
+	GroupName string `gorm:"column:groupname" json:"groupname"`
+	UpdatedBy string `gorm:"column:updatedby" json:"updatedby"`
+	UpdatedAt int64  `gorm:"column:updatedat" json:"updatedat"`
+}
+
+func (VehicleGroup) TableName() string {
+	return "LAFPackages.groups"
+}
@@ -1,6 +1,7 @@
 package model
 
 type Harness struct {
+	HarnessId        int64  `gorm:"column:harnessid;<-:false;default:(-)" json:"harnessid"`
 	SlNo             int    `gorm:"column:slno" json:"slno"`
 	PhCode           string `gorm:"column:phcode" json:"phcode"`
 	AhCode           string `gorm:"column:ahcode" json:"ahcode"`
@@ -12,6 +13,7 @@ type Harness struct {
 	YearOfMfg        string `gorm:"column:yearofmfg" json:"yearofmfg"`
 	FuelType         string `gorm:"column:fueltype" json:"fueltype"`
 	TransmissionType string `gorm:"column:transmissiontype" json:"transmissiontype"`
+	VehicleId        *int   `gorm:"column:vehicleid" json:"vehicleid"`
 	IgnitionType     string `gorm:"column:ignitiontype" json:"ignitiontype"`
 	DeviceType       string `gorm:"column:devicetype" json:"devicetype"`
 	Specification    string `gorm:"column:specification" json:"specification"`
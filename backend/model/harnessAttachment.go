@@ -0,0 +1,37 @@
+package model
+
+const (
+	AttachmentHarnessImage = "harnessimage"
+	AttachmentDiagram      = "diagram"
+)
+
+// HarnessAttachment describes an uploaded harness image or wiring diagram.
+// Kind is also the storage folder holding the file, and FileName the value
+// of the harness field referencing it.
+type HarnessAttachment struct {
+	ID           int    `gorm:"column:id;primary_key" json:"id"`
+	Kind         string `gorm:"column:kind" json:"kind"`
+	FileName     string `gorm:"column:filename" json:"filename"`
+	OriginalName string `gorm:"column:originalname" json:"originalname"`
+	Size         int64  `gorm:"column:size" json:"size"`
+	ContentType  string `gorm:"column:contenttype" json:"contenttype"`
+	Hash         string `gorm:"column:hash" json:"hash"`
+	UploadedBy   string `gorm:"column:uploadedby" json:"uploadedby"`
+	UploadedAt   int64  `gorm:"column:uploadedat" json:"uploadedat"`
+}
+
+func (HarnessAttachment) TableName() string {
+	return "Harness.attachments"
+}
+
+// Attachment returns the field of d holding the file name of the attachment
+// kind, or nil for an unknown kind.
+func (d *HarnessDesign) Attachment(kind string) *string {
+	switch kind {
+	case AttachmentHarnessImage:
+		return &d.HarnessImage
+	case AttachmentDiagram:
+		return &d.Diagram
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package model
+
+// HarnessDesign holds the harness fields that are revisioned. Every harness
+// with the same PhCode shares the design of its current revision.
+type HarnessDesign struct {
+	Specification    string `gorm:"column:specification" json:"specification"`
+	Diagram          string `gorm:"column:diagram" json:"diagram"`
+	HarnessImage     string `gorm:"column:harnessimage" json:"harnessimage"`
+	ImmoType         string `gorm:"column:immotype" json:"immotype"`
+	ImmoRelayVoltage string `gorm:"column:immorelayvoltage" json:"immorelayvoltage"`
+	Can              string `gorm:"column:can" json:"can"`
+	Panic            string `gorm:"column:panic" json:"panic"`
+}
+
+// Columns returns the design as harness column values.
+func (d HarnessDesign) Columns() map[string]interface{} {
+	return map[string]interface{}{
+		"specification":    d.Specification,
+		"diagram":          d.Diagram,
+		"harnessimage":     d.HarnessImage,
+		"immotype":         d.ImmoType,
+		"immorelayvoltage": d.ImmoRelayVoltage,
+		"can":              d.Can,
+		"panic":            d.Panic,
+	}
+}
+
+// Merge returns d with its empty fields taken from base.
+func (d HarnessDesign) Merge(base HarnessDesign) HarnessDesign {
+	for _, f := range []struct{ field, from *string }{
+		{&d.Specification, &base.Specification},
+		{&d.Diagram, &base.Diagram},
+		{&d.HarnessImage, &base.HarnessImage},
+		{&d.ImmoType, &base.ImmoType},
+		{&d.ImmoRelayVoltage, &base.ImmoRelayVoltage},
+		{&d.Can, &base.Can},
+		{&d.Panic, &base.Panic},
+	} {
+		if *f.field == "" {
+			*f.field = *f.from
+		}
+	}
+	return d
+}
+
+// HarnessRevision is a snapshot of the design of the harnesses with a
+// PhCode. Exactly one revision per PhCode is current until the last harness
+// with the PhCode is deleted; its revisions are then archived and no longer
+// hold on to their attachment files.
+type HarnessRevision struct {
+	ID            int    `gorm:"column:id;primary_key" json:"id"`
+	PhCode        string `gorm:"column:phcode" json:"phcode"`
+	Rev           int    `gorm:"column:rev" json:"rev"`
+	IsCurrent     bool   `gorm:"column:iscurrent" json:"iscurrent"`
+	HarnessDesign `gorm:"embedded"`
+	Notes         string `gorm:"column:notes" json:"notes"`
+	CreatedBy     string `gorm:"column:createdby" json:"createdby"`
+	CreatedAt     int64  `gorm:"column:createdat" json:"createdat"`
+	ArchivedAt    *int64 `gorm:"column:archivedat" json:"archivedat"`
+}
+
+func (HarnessRevision) TableName() string {
+	return "Harness.revisions"
+}
+
+func (h Harness) Design() HarnessDesign {
+	return HarnessDesign{
+		Specification:    h.Specification,
+		Diagram:          h.Diagram,
+		HarnessImage:     h.HarnessImage,
+		ImmoType:         h.ImmoType,
+		ImmoRelayVoltage: h.ImmoRelayVoltage,
+		Can:              h.Can,
+		Panic:            h.Panic,
+	}
+}
+
+func (h *Harness) SetDesign(d HarnessDesign) {
+	h.Specification = d.Specification
+	h.Diagram = d.Diagram
+	h.HarnessImage = d.HarnessImage
+	h.ImmoType = d.ImmoType
+	h.ImmoRelayVoltage = d.ImmoRelayVoltage
+	h.Can = d.Can
+	h.Panic = d.Panic
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Members keeps the JSON members of a settings object that its typed fields
+// do not model, and which typed members were present, so that documents
+// round-trip without losing or adding keys.
+type Members struct {
+	extra   map[string]json.RawMessage
+	present map[string]bool
+}
+
+// Extra returns an unmodelled member verbatim.
+func (m Members) Extra(name string) (json.RawMessage, bool) {
+	raw, ok := m.extra[name]
+	return raw, ok
+}
+
+var membersType = reflect.TypeOf(Members{})
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// decodeMembers unmarshals data into v, a pointer to a struct with the same
+// fields as the type embedding Members but without its JSON methods.
+func decodeMembers(data []byte, v interface{}) (Members, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Members{}, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return Members{}, err
+	}
+
+	m := Members{present: map[string]bool{}}
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == membersType || !f.IsExported() {
+			continue
+		}
+		name := jsonName(f)
+		if _, ok := raw[name]; ok {
+			m.present[name] = true
+			delete(raw, name)
+		}
+	}
+	if len(raw) > 0 {
+		m.extra = raw
+	}
+	return m, nil
+}
+
+// encodeMembers marshals v, the struct counterpart of decodeMembers, with the
+// unmodelled members in m. Typed fields are written when they were present in
+// the decoded document or have been set since.
+func encodeMembers(v interface{}, m Members) ([]byte, error) {
+	out := make(map[string]json.RawMessage, len(m.extra))
+	for k, raw := range m.extra {
+		out[k] = raw
+	}
+
+	rv := reflect.ValueOf(v)
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == membersType || !f.IsExported() || f.Tag.Get("json") == "-" {
+			continue
+		}
+		name := jsonName(f)
+		fv := rv.Field(i)
+		if !m.present[name] && fv.IsZero() {
+			continue
+		}
+		raw, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		out[name] = raw
+	}
+	return json.Marshal(out)
+}
+
+// setPresent controls whether a zero typed member is written. The set is
+// copied first because copies of a document share it.
+func (m *Members) setPresent(name string, present bool) {
+	next := make(map[string]bool, len(m.present)+1)
+	for k, v := range m.present {
+		next[k] = v
+	}
+	next[name] = present
+	m.present = next
+}
+
+// Scalar is a JSON value kept verbatim, for fields stored either as numbers
+// or as strings such as "0x18FEF100".
+type Scalar json.RawMessage
+
+func (s Scalar) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return s, nil
+}
+
+func (s *Scalar) UnmarshalJSON(data []byte) error {
+	*s = append((*s)[:0], data...)
+	return nil
+}
+
+// String returns string values unquoted and anything else as JSON text.
+func (s Scalar) String() string {
+	var str string
+	if json.Unmarshal(s, &str) == nil {
+		return str
+	}
+	return string(s)
+}
+
+// Uint64 parses decimal, hex (0x) or octal (0o) numbers and numeric strings.
+func (s Scalar) Uint64() (uint64, error) {
+	text := strings.TrimSpace(s.String())
+	if n, err := strconv.ParseUint(text, 0, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f < 0 || f != float64(uint64(f)) {
+		return 0, fmt.Errorf("%s is not an unsigned integer", text)
+	}
+	return uint64(f), nil
+}
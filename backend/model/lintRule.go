@@ -0,0 +1,18 @@
+package model
+
+// LintRuleConfig overrides a lint rule for a vehicle group. GroupId 0 applies
+// to every group unless the group has its own row.
+type LintRuleConfig struct {
+	ID        int                    `gorm:"column:id;primary_key" json:"id"`
+	GroupId   int                    `gorm:"column:groupid" json:"groupid"`
+	RuleId    string                 `gorm:"column:ruleid" json:"ruleid"`
+	Enabled   bool                   `gorm:"column:enabled" json:"enabled"`
+	Severity  string                 `gorm:"column:severity" json:"severity"`
+	Params    map[string]interface{} `gorm:"column:params;serializer:json" json:"params,omitempty"`
+	UpdatedBy string                 `gorm:"column:updatedby" json:"updatedby"`
+	UpdatedAt int64                  `gorm:"column:updatedat" json:"updatedat"`
+}
+
+func (LintRuleConfig) TableName() string {
+	return "FileTracker.lintrules"
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const modelNameSeparator = '_'
+
+// ModelDescriptor identifies a vehicle model. Its canonical name joins the
+// fields with underscores; underscores and backslashes inside a field are
+// escaped with a backslash so that the name can be parsed back. Names whose
+// fields contain neither are unchanged from the old unescaped format.
+type ModelDescriptor struct {
+	VehicleType  string `json:"vehicletype"`
+	Oem          string `json:"oem"`
+	Model        string `json:"model"`
+	Variant      string `json:"variant"`
+	Year         int    `json:"year"`
+	FuelType     string `json:"fueltype"`
+	Transmission string `json:"transmission"`
+}
+
+func (d ModelDescriptor) fields() []string {
+	return []string{d.VehicleType, d.Oem, d.Model, d.Variant, strconv.Itoa(d.Year), d.FuelType, d.Transmission}
+}
+
+// String returns the canonical model name.
+func (d ModelDescriptor) String() string {
+	return JoinEscaped(modelNameSeparator, d.fields()...)
+}
+
+// DisplayName returns the non-empty fields after the vehicle type separated
+// by spaces, e.g. "Tata Nexon XZ+ 2021 Petrol Manual".
+func (d ModelDescriptor) DisplayName() string {
+	var parts []string
+	for _, f := range d.fields()[1:] {
+		if f != "" && f != "0" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseModelName parses a canonical model name.
+func ParseModelName(name string) (ModelDescriptor, error) {
+	parts := SplitEscaped(name, modelNameSeparator)
+	if len(parts) != 7 {
+		return ModelDescriptor{}, fmt.Errorf("model name %q has %d fields, expected 7", name, len(parts))
+	}
+	year, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return ModelDescriptor{}, fmt.Errorf("model name %q has invalid year %q", name, parts[4])
+	}
+	return ModelDescriptor{
+		VehicleType:  parts[0],
+		Oem:          parts[1],
+		Model:        parts[2],
+		Variant:      parts[3],
+		Year:         year,
+		FuelType:     parts[5],
+		Transmission: parts[6],
+	}, nil
+}
+
+// JoinEscaped joins fields with sep, escaping sep and backslashes inside a
+// field with a backslash.
+func JoinEscaped(sep byte, fields ...string) string {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(sep)
+		}
+		for j := 0; j < len(f); j++ {
+			if f[j] == sep || f[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(f[j])
+		}
+	}
+	return b.String()
+}
+
+// SplitEscaped splits s at every sep not preceded by a backslash and removes
+// the escapes. It reverses JoinEscaped.
+func SplitEscaped(s string, sep byte) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == sep:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+func (g GroupModels) Descriptor() ModelDescriptor {
+	return ModelDescriptor{
+		VehicleType:  g.VehicleType,
+		Oem:          g.Oem,
+		Model:        g.Model,
+		Variant:      g.Variant,
+		Year:         g.Year,
+		FuelType:     g.FuelType,
+		Transmission: g.Transmission,
+	}
+}
+
+func (v CatalogueVehicle) Descriptor() ModelDescriptor {
+	return ModelDescriptor{
+		VehicleType:  v.VehicleType,
+		Oem:          v.Oem,
+		Model:        v.Model,
+		Variant:      v.Variant,
+		Year:         v.Year,
+		FuelType:     v.FuelType,
+		Transmission: v.Transmission,
+	}
+}
+
+// Descriptor returns the model the harness is built for. A year of
+// manufacture that is not a plain number is reported as 0.
+func (h Harness) Descriptor() ModelDescriptor {
+	year, _ := strconv.Atoi(strings.TrimSpace(h.YearOfMfg))
+	return ModelDescriptor{
+		VehicleType:  h.VehicleType,
+		Oem:          h.VehicleOem,
+		Model:        h.VehicleModel,
+		Variant:      h.VehicleVariant,
+		Year:         year,
+		FuelType:     h.FuelType,
+		Transmission: h.TransmissionType,
+	}
+}
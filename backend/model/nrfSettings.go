@@ -1,14 +1,48 @@
 package model
 
+import "gorm.io/gorm"
+
+// NrfSettings is one version of an NRF settings file. Deleting a file
+// retires its versions through DeletedAt, which hides them from queries but
+// keeps them, and their version numbers, in the table.
 type NrfSettings struct {
-	FileName  string `gorm:"column:filename" json:"filename"`
-	FileType  int    `gorm:"column:filetype" json:"filetype"`
-	JSONData  []byte `gorm:"column:jsondata" json:"jsondata"`
-	SleepCdns []byte `gorm:"column:sleepcdns" json:"sleepcdns"`
-	CreatedBy string `gorm:"column:createdby" json:"createdby"`
-	CreatedAt int64  `gorm:"column:createdat" json:"createdat"`
+	FileName    string           `gorm:"column:filename" json:"filename"`
+	FileType    int              `gorm:"column:filetype" json:"filetype"`
+	Version     int              `gorm:"column:version" json:"version"`
+	ContentHash string           `gorm:"column:contenthash" json:"contenthash"`
+	IsCurrent   bool             `gorm:"column:iscurrent" json:"iscurrent"`
+	JSONData    *NrfSettingsData `gorm:"column:jsondata;serializer:settingsjson" json:"jsondata"`
+	SleepCdns   SleepConditions  `gorm:"column:sleepcdns;serializer:settingsjson" json:"sleepcdns"`
+	SchemaId    int              `gorm:"column:schemaid" json:"schemaid"`
+	CreatedBy   string           `gorm:"column:createdby" json:"createdby"`
+	UpdatedBy   string           `gorm:"column:updatedby" json:"updatedby"`
+	CreatedAt   int64            `gorm:"column:createdat" json:"createdat"`
+	UpdatedAt   int64            `gorm:"column:updatedat" json:"updatedat"`
+	DeletedAt   gorm.DeletedAt   `gorm:"column:deletedat" json:"-"`
 }
 
 func (NrfSettings) TableName() string {
 	return "NrfSettings.parsedfiles"
 }
+
+// Document returns the document served for the file: JSONData with the sleep
+// conditions merged in under "sleepcdns".
+func (s NrfSettings) Document() NrfSettingsData {
+	var doc NrfSettingsData
+	if s.JSONData != nil {
+		doc = *s.JSONData
+	}
+	doc.SleepCdns = s.SleepCdns
+	doc.setPresent("sleepcdns", true)
+	return doc
+}
+
+type NrfFileType struct {
+	ID          int    `gorm:"column:id;primary_key;autoIncrement:false" json:"id"`
+	Name        string `gorm:"column:name" json:"name"`
+	Description string `gorm:"column:description" json:"description"`
+}
+
+func (NrfFileType) TableName() string {
+	return "NrfSettings.filetypes"
+}
@@ -0,0 +1,76 @@
+package model
+
+import "encoding/json"
+
+// NrfSettingsData is the document stored in NrfSettings.JSONData. Sleep
+// settings are typed; other members are kept as they were uploaded.
+// SleepCdns is only set on documents built by NrfSettings.Document.
+type NrfSettingsData struct {
+	Members
+	SleepSettings map[string]SleepSetting `json:"sleepsettings"`
+	SleepCdns     SleepConditions         `json:"sleepcdns"`
+}
+
+func (d *NrfSettingsData) UnmarshalJSON(data []byte) error {
+	type plain NrfSettingsData
+	m, err := decodeMembers(data, (*plain)(d))
+	d.Members = m
+	return err
+}
+
+func (d NrfSettingsData) MarshalJSON() ([]byte, error) {
+	type plain NrfSettingsData
+	return encodeMembers(plain(d), d.Members)
+}
+
+// SleepSetting holds the thresholds of one state transition. Voltages are in
+// 1/10000 V and times in milliseconds.
+type SleepSetting struct {
+	Members
+	Ain0V        json.Number `json:"ain0_v"`
+	Ain1V        json.Number `json:"ain1_v"`
+	VbatV        json.Number `json:"vbat_v"`
+	VinV         json.Number `json:"vin_v"`
+	Hysteresis   json.Number `json:"hysteresis"`
+	MovTimeout   json.Number `json:"mov_timeout"`
+	StateTimeout json.Number `json:"state_timeout"`
+}
+
+func (s *SleepSetting) UnmarshalJSON(data []byte) error {
+	type plain SleepSetting
+	m, err := decodeMembers(data, (*plain)(s))
+	s.Members = m
+	return err
+}
+
+func (s SleepSetting) MarshalJSON() ([]byte, error) {
+	type plain SleepSetting
+	return encodeMembers(plain(s), s.Members)
+}
+
+// SleepTransitions lists the state transitions of the sleep state machine,
+// which key both sleepsettings and sleepcdns.
+var SleepTransitions = []string{"actToInact", "inactToAct", "inactToSleep", "sleepToInact", "sleepToDeepSleep", "deepSleepToInact"}
+
+// SleepConditions maps a state transition such as actToInact to the
+// conditions that trigger it.
+type SleepConditions map[string]SleepCondition
+
+type SleepCondition struct {
+	Members
+	And  []string `json:"cdn_and"`
+	Or   []string `json:"cdn_or"`
+	Targ []string `json:"cdn_targ"`
+}
+
+func (s *SleepCondition) UnmarshalJSON(data []byte) error {
+	type plain SleepCondition
+	m, err := decodeMembers(data, (*plain)(s))
+	s.Members = m
+	return err
+}
+
+func (s SleepCondition) MarshalJSON() ([]byte, error) {
+	type plain SleepCondition
+	return encodeMembers(plain(s), s.Members)
+}
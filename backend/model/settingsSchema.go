@@ -0,0 +1,17 @@
+package model
+
+type SettingsSchema struct {
+	ID           int    `gorm:"column:id;primary_key" json:"id"`
+	SettingsType string `gorm:"column:settingstype" json:"settingstype"`
+	FileType     *int   `gorm:"column:filetype" json:"filetype"`
+	Version      int    `gorm:"column:version" json:"version"`
+	Schema       []byte `gorm:"column:schema" json:"schema"`
+	IsActive     bool   `gorm:"column:isactive" json:"isactive"`
+	Description  string `gorm:"column:description" json:"description"`
+	CreatedBy    string `gorm:"column:createdby" json:"createdby"`
+	CreatedAt    int64  `gorm:"column:createdat" json:"createdat"`
+}
+
+func (SettingsSchema) TableName() string {
+	return "FileTracker.settingsschemas"
+}
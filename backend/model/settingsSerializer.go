@@ -0,0 +1,48 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SettingsSerializer stores typed settings documents as JSON. It is
+// registered as "settingsjson" and, unlike GORM's json serializer, writes
+// []byte so existing jsondata and sleepcdns columns keep their encoding.
+type SettingsSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("settingsjson", SettingsSerializer{})
+}
+
+func (SettingsSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+	if dbValue != nil {
+		var data []byte
+		switch v := dbValue.(type) {
+		case []byte:
+			data = v
+		case string:
+			data = []byte(v)
+		default:
+			return fmt.Errorf("failed to scan %s: unsupported type %T", field.DBName, dbValue)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, fieldValue.Interface()); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", field.DBName, err)
+			}
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+func (SettingsSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if rv := reflect.ValueOf(fieldValue); !rv.IsValid() || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map) && rv.IsNil()) {
+		return nil, nil
+	}
+	return json.Marshal(fieldValue)
+}
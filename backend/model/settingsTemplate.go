@@ -0,0 +1,51 @@
+package model
+
+type SettingsTemplate struct {
+	ID           int                 `gorm:"column:id;primary_key" json:"id"`
+	Name         string              `gorm:"column:name" json:"name"`
+	SettingsType string              `gorm:"column:settingstype" json:"settingstype"`
+	FileType     *int                `gorm:"column:filetype" json:"filetype"`
+	Version      int                 `gorm:"column:version" json:"version"`
+	Description  string              `gorm:"column:description" json:"description"`
+	Parameters   []TemplateParameter `gorm:"column:parameters;serializer:json" json:"parameters"`
+	Body         []byte              `gorm:"column:body" json:"body"`
+	CreatedBy    string              `gorm:"column:createdby" json:"createdby"`
+	UpdatedBy    string              `gorm:"column:updatedby" json:"updatedby"`
+	CreatedAt    int64               `gorm:"column:createdat" json:"createdat"`
+	UpdatedAt    int64               `gorm:"column:updatedat" json:"updatedat"`
+}
+
+func (SettingsTemplate) TableName() string {
+	return "FileTracker.settingstemplates"
+}
+
+// TemplateParameter declares a value substituted into a template body.
+type TemplateParameter struct {
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Min         *float64      `json:"min,omitempty"`
+	Max         *float64      `json:"max,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+}
+
+// SettingsGeneration records the template and parameter values a settings
+// file was last generated from.
+type SettingsGeneration struct {
+	ID              int                    `gorm:"column:id;primary_key" json:"id"`
+	TemplateId      int                    `gorm:"column:templateid" json:"templateid"`
+	TemplateVersion int                    `gorm:"column:templateversion" json:"templateversion"`
+	SettingsType    string                 `gorm:"column:settingstype" json:"settingstype"`
+	FileName        string                 `gorm:"column:filename" json:"filename"`
+	FileVersion     int                    `gorm:"column:fileversion" json:"fileversion"`
+	Params          map[string]interface{} `gorm:"column:params;serializer:json" json:"params"`
+	GeneratedBy     string                 `gorm:"column:generatedby" json:"generatedby"`
+	GeneratedAt     int64                  `gorm:"column:generatedat" json:"generatedat"`
+}
+
+func (SettingsGeneration) TableName() string {
+	return "FileTracker.settingsgenerations"
+}
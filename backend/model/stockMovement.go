@@ -0,0 +1,31 @@
+package model
+
+const (
+	StockIn         = "in"
+	StockOut        = "out"
+	StockAdjustment = "adjustment"
+	StockTransfer   = "transfer"
+)
+
+// StockMovement is an entry of the harness stock ledger. Quantity is the
+// signed change to the harness's stock and Balance the stock after it; Rev
+// is the harness revision of the units moved. A
+// transfer is recorded as a movement out of one harness and one into another,
+// each naming the other as CounterpartId.
+type StockMovement struct {
+	ID            int    `gorm:"column:id;primary_key" json:"id"`
+	HarnessId     int64  `gorm:"column:harnessid" json:"harnessid"`
+	Rev           int    `gorm:"column:rev" json:"rev"`
+	Type          string `gorm:"column:type" json:"type"`
+	Quantity      int    `gorm:"column:quantity" json:"quantity"`
+	Balance       int    `gorm:"column:balance" json:"balance"`
+	Reason        string `gorm:"column:reason" json:"reason"`
+	Reference     string `gorm:"column:reference" json:"reference"`
+	CounterpartId *int64 `gorm:"column:counterpartid" json:"counterpartid,omitempty"`
+	CreatedBy     string `gorm:"column:createdby" json:"createdby"`
+	CreatedAt     int64  `gorm:"column:createdat" json:"createdat"`
+}
+
+func (StockMovement) TableName() string {
+	return "Harness.stockmovements"
+}
@@ -0,0 +1,37 @@
+package model
+
+// StockThreshold sets the minimum stock and reorder quantity for a harness
+// part. An empty AhCode applies to every harness with the PhCode.
+type StockThreshold struct {
+	ID              int    `gorm:"column:id;primary_key" json:"id"`
+	PhCode          string `gorm:"column:phcode" json:"phcode"`
+	AhCode          string `gorm:"column:ahcode" json:"ahcode"`
+	MinStock        int    `gorm:"column:minstock" json:"minstock"`
+	ReorderQuantity int    `gorm:"column:reorderquantity" json:"reorderquantity"`
+	UpdatedBy       string `gorm:"column:updatedby" json:"updatedby"`
+	UpdatedAt       int64  `gorm:"column:updatedat" json:"updatedat"`
+}
+
+func (StockThreshold) TableName() string {
+	return "Harness.stockthresholds"
+}
+
+// StockAlert is raised when the stock of a threshold's harnesses falls below
+// its minimum and resolved once it recovers. NotifiedAt is set when the
+// alert has been sent.
+type StockAlert struct {
+	ID              int    `gorm:"column:id;primary_key" json:"id"`
+	ThresholdId     int    `gorm:"column:thresholdid" json:"thresholdid"`
+	PhCode          string `gorm:"column:phcode" json:"phcode"`
+	AhCode          string `gorm:"column:ahcode" json:"ahcode"`
+	Stock           int    `gorm:"column:stock" json:"stock"`
+	MinStock        int    `gorm:"column:minstock" json:"minstock"`
+	ReorderQuantity int    `gorm:"column:reorderquantity" json:"reorderquantity"`
+	RaisedAt        int64  `gorm:"column:raisedat" json:"raisedat"`
+	NotifiedAt      *int64 `gorm:"column:notifiedat" json:"notifiedat"`
+	ResolvedAt      *int64 `gorm:"column:resolvedat" json:"resolvedat"`
+}
+
+func (StockAlert) TableName() string {
+	return "Harness.stockalerts"
+}
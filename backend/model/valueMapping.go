@@ -0,0 +1,34 @@
+package model
+
+type ValueMappingDictionary struct {
+	ID           int                 `gorm:"column:id;primary_key" json:"id"`
+	Name         string              `gorm:"column:name" json:"name"`
+	Description  string              `gorm:"column:description" json:"description"`
+	SettingsType string              `gorm:"column:settingstype" json:"settingstype"`
+	Owner        string              `gorm:"column:owner" json:"owner"`
+	Visibility   string              `gorm:"column:visibility" json:"visibility"`
+	CreatedAt    int64               `gorm:"column:createdat" json:"createdat"`
+	UpdatedAt    int64               `gorm:"column:updatedat" json:"updatedat"`
+	Mappings     []ValueMappingEntry `gorm:"foreignKey:DictId" json:"mappings,omitempty"`
+}
+
+func (ValueMappingDictionary) TableName() string {
+	return "FileTracker.valuemappingdicts"
+}
+
+type ValueMappingEntry struct {
+	ID           int    `gorm:"column:id;primary_key" json:"-"`
+	DictId       int    `gorm:"column:dictid" json:"-"`
+	KeyPath      string `gorm:"column:keypath" json:"key"`
+	Value        string `gorm:"column:value" json:"value"`
+	DisplayValue string `gorm:"column:displayvalue" json:"displayvalue"`
+}
+
+func (ValueMappingEntry) TableName() string {
+	return "FileTracker.valuemappingentries"
+}
+
+const (
+	VisibilityPrivate = "private"
+	VisibilityShared  = "shared"
+)
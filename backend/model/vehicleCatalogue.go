@@ -0,0 +1,20 @@
+package model
+
+// CatalogueVehicle is an entry of the vehicle master catalogue. Group models
+// and harnesses reference it by VehicleId and keep copies of its fields.
+type CatalogueVehicle struct {
+	VehicleId    int    `gorm:"column:vehicleid;primary_key" json:"vehicleid"`
+	VehicleType  string `gorm:"column:vehicletype" json:"vehicletype"`
+	Oem          string `gorm:"column:oem" json:"oem"`
+	Model        string `gorm:"column:model" json:"model"`
+	Variant      string `gorm:"column:variant" json:"variant"`
+	Year         int    `gorm:"column:year" json:"year"`
+	FuelType     string `gorm:"column:fueltype" json:"fueltype"`
+	Transmission string `gorm:"column:transmission" json:"transmission"`
+	UpdatedBy    string `gorm:"column:updatedby" json:"updatedby"`
+	UpdatedAt    int64  `gorm:"column:updatedat" json:"updatedat"`
+}
+
+func (CatalogueVehicle) TableName() string {
+	return "LAFPackages.vehicles"
+}
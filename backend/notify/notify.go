@@ -0,0 +1,102 @@
+// Package notify delivers operational notifications, such as low-stock
+// alerts, through pluggable channels.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"filepackage/notify/smtptest"
+)
+
+// Message is a notification. Data carries the structured payload for
+// channels that can use it, such as webhooks.
+type Message struct {
+	Subject string      `json:"subject"`
+	Body    string      `json:"body"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Multi sends every message through each of its notifiers and returns their
+// errors joined.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Log writes messages to the standard logger. It is used when no other
+// channel is configured.
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, msg Message) error {
+	log.Printf("Notification: %s\n%s", msg.Subject, msg.Body)
+	return nil
+}
+
+// FromEnv builds the notifier configured by the environment:
+//
+//	ALERT_SMTP_HOST, ALERT_SMTP_PORT, ALERT_SMTP_FROM, ALERT_SMTP_TO (comma
+//	separated), ALERT_SMTP_USERNAME, ALERT_SMTP_PASSWORD
+//	ALERT_WEBHOOK_URL
+//
+// ALERT_SMTP_HOST=test starts a local test server that logs the mail it
+// receives. Without any channel, messages are logged.
+func FromEnv() Notifier {
+	var m Multi
+	if host := os.Getenv("ALERT_SMTP_HOST"); host != "" {
+		smtp := &SMTP{
+			Host:     host,
+			Port:     os.Getenv("ALERT_SMTP_PORT"),
+			From:     os.Getenv("ALERT_SMTP_FROM"),
+			To:       splitList(os.Getenv("ALERT_SMTP_TO")),
+			Username: os.Getenv("ALERT_SMTP_USERNAME"),
+			Password: os.Getenv("ALERT_SMTP_PASSWORD"),
+		}
+		if host == "test" {
+			server, err := smtptest.NewServer()
+			if err != nil {
+				log.Printf("Failed to start SMTP test server: %v", err)
+			} else {
+				server.OnMessage = func(msg smtptest.Message) {
+					log.Printf("SMTP test server received mail from %s to %v:\n%s", msg.From, msg.To, msg.Data)
+				}
+				smtp.Host, smtp.Port = server.Host(), server.Port()
+				log.Printf("SMTP test server listening on %s", server.Addr())
+			}
+		}
+		m = append(m, smtp)
+	}
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		m = append(m, &Webhook{URL: url, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	if len(m) == 0 {
+		return Log{}
+	}
+	return m
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
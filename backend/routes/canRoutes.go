@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"filepackage/auth"
 	"filepackage/handler"
 
 	"github.com/gin-gonic/gin"
@@ -11,7 +12,22 @@ func CanSettingsRoutes(router *gin.Engine) {
 
 	api := router.Group("/api/v1/products/api")
 	{
-		api.GET("/cansettings/:filename", handler.GetCanSettingsByFileName)
+		api.GET("/cansettings/:filename", auth.OptionalAuth(), handler.GetCanSettingsByFileName)
 		api.GET("/cansettings/all", handler.GetAllFileNames)
+		api.GET("/cansettings/diff", handler.DiffCanSettings)
+		api.GET("/cansettings/:filename/versions", handler.GetCanSettingsVersions)
+		api.GET("/cansettings/:filename/usage", handler.GetCanSettingsUsage)
+		api.GET("/cansettings/:filename/versions/:version", auth.OptionalAuth(), handler.GetCanSettingsByFileName)
+		api.GET("/cansettings/:filename/dbc", handler.ExportDbc)
+		api.GET("/cansettings/:filename/binary", handler.ExportCanSettingsBinary)
+		api.POST("/cansettings/dbc/preview", handler.PreviewDbc)
+		api.POST("/cansettings/:filename/lint", handler.LintCanSettings)
+	}
+
+	write := api.Group("", auth.RequireAuth())
+	{
+		write.POST("/cansettings", handler.CreateCanSettings)
+		write.PUT("/cansettings/:filename/versions/:version/current", handler.SetCurrentCanSettingsVersion)
+		write.POST("/cansettings/dbc", handler.ImportDbc)
 	}
 }
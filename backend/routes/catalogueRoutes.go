@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"filepackage/auth"
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CatalogueRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	{
+		api.GET("/catalogue", handler.GetCatalogueVehicles)
+		api.GET("/catalogue/lookup", handler.LookupVehicle)
+		api.GET("/catalogue/unmatched", handler.GetUnmatchedHarnesses)
+		api.GET("/catalogue/:vehicleid", handler.GetCatalogueVehicle)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/catalogue", handler.CreateCatalogueVehicle)
+		admin.POST("/catalogue/match", handler.MatchHarnessCatalogue)
+		admin.PUT("/catalogue/:vehicleid", handler.UpdateCatalogueVehicle)
+		admin.DELETE("/catalogue/:vehicleid", handler.DeleteCatalogueVehicle)
+	}
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"filepackage/auth"
 	"filepackage/handler"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,20 @@ func GroupModelRoutes(router *gin.Engine) {
 
 	{
 		api.GET("/groups", handler.GetAllGroups)
-		api.GET("/groups/:groupname", handler.GetModelsForGroup)
+		api.GET("/groups/:groupid", handler.GetModelsForGroupByNameDeprecated)
+		api.GET("/groups/:groupid/models", handler.GetModelsForGroupId)
+		api.GET("/groups/by-name/:groupname/models", handler.GetModelsForGroup)
+		api.GET("/models/browse", handler.BrowseGroupModels)
+		api.GET("/models/tree", handler.GetGroupModelTree)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/groups", handler.CreateGroup)
+		admin.PUT("/groups/:groupid", handler.UpdateGroup)
+		admin.DELETE("/groups/:groupid", handler.DeleteGroup)
+		admin.POST("/models", handler.CreateGroupModel)
+		admin.PUT("/models/:modelid", handler.UpdateGroupModel)
+		admin.DELETE("/models/:modelid", handler.DeleteGroupModel)
 	}
 }
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"filepackage/auth"
 	"filepackage/handler"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +12,41 @@ func HarnessRoutes(r *gin.Engine) {
 	api := r.Group("/api")
 
 	{
-		api.POST("/vehicle", handler.CreateVehicle)
-		api.GET("/vehicle/:vehicledetails", handler.GetVehicle)
+		api.GET("/vehicle", handler.GetVehicle)
+		api.GET("/vehicle/:harnessid", handler.GetVehicle)
 		api.GET("/vehicles", handler.GetAllVehicles)
-		api.PUT("/vehicle/:vehicledetails", handler.UpdateVehicle)
-		api.DELETE("/vehicle/:vehicledetails", handler.DeleteVehicle)
+		api.GET("/vehicle/:harnessid/stock", handler.GetStockAsOf)
+		api.GET("/vehicle/:harnessid/stock/movements", handler.GetStockMovements)
+		api.GET("/vehicle/:harnessid/attachments", handler.GetHarnessAttachments)
+		api.GET("/vehicle/:harnessid/attachments/:kind", handler.DownloadHarnessAttachment)
+		api.GET("/vehicles/stock", handler.GetAllStockAsOf)
+		api.GET("/vehicles/export", handler.ExportVehicles)
+		api.GET("/harness/alerts", handler.GetStockAlerts)
+		api.GET("/harness/thresholds", handler.GetStockThresholds)
+		api.GET("/harness/revisions/:phcode", handler.GetHarnessRevisions)
+		api.GET("/harness/revisions/:phcode/diff", handler.DiffHarnessRevisions)
+		api.GET("/harness/revisions/:phcode/:rev", handler.GetHarnessRevision)
+	}
+
+	write := api.Group("", auth.RequireAuth())
+	{
+		write.POST("/vehicle", handler.CreateVehicle)
+		write.PUT("/vehicle", handler.UpdateVehicle)
+		write.PUT("/vehicle/:harnessid", handler.UpdateVehicle)
+		write.DELETE("/vehicle", handler.DeleteVehicle)
+		write.DELETE("/vehicle/:harnessid", handler.DeleteVehicle)
+		write.POST("/vehicle/:harnessid/stock/movements", handler.CreateStockMovement)
+		write.PUT("/vehicle/:harnessid/attachments/:kind", handler.UploadHarnessAttachment)
+		write.DELETE("/vehicle/:harnessid/attachments/:kind", handler.DeleteHarnessAttachment)
+		write.POST("/harness/revisions/:phcode", handler.CreateHarnessRevision)
+		write.PUT("/harness/revisions/:phcode/:rev/current", handler.SetCurrentHarnessRevision)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/vehicles/import", handler.ImportVehicles)
+		admin.POST("/harness/alerts/evaluate", handler.EvaluateStockAlerts)
+		admin.PUT("/harness/thresholds", handler.SaveStockThreshold)
+		admin.DELETE("/harness/thresholds/:id", handler.DeleteStockThreshold)
 	}
 }
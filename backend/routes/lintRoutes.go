@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"filepackage/auth"
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LintRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	{
+		api.GET("/lint/rules", handler.GetLintRules)
+		api.GET("/lint/configs", handler.GetLintRuleConfigs)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.PUT("/lint/configs/:groupid/:ruleid", handler.SaveLintRuleConfig)
+		admin.DELETE("/lint/configs/:groupid/:ruleid", handler.DeleteLintRuleConfig)
+	}
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"filepackage/auth"
 	"filepackage/handler"
 
 	"github.com/gin-gonic/gin"
@@ -10,7 +11,29 @@ func NrfSettingsRoutes(router *gin.Engine) {
 	api := router.Group("/api")
 
 	{
-		api.GET("/nrfsettings/:filename", handler.GetNrfSettingsByFileName)
+		api.GET("/nrfsettings/:filename", auth.OptionalAuth(), handler.GetNrfSettingsByFileName)
 		api.GET("/nrfsettings/all", handler.GetAllNrfFileNames)
+		api.GET("/nrfsettings/diff", handler.DiffNrfSettings)
+		api.GET("/nrfsettings/filetypes", handler.GetNrfFileTypes)
+		api.GET("/nrfsettings/:filename/sleepcdns", handler.GetNrfSleepConditions)
+		api.GET("/nrfsettings/:filename/binary", handler.ExportNrfSettingsBinary)
+		api.GET("/nrfsettings/:filename/versions", handler.GetNrfSettingsVersions)
+		api.GET("/nrfsettings/:filename/usage", handler.GetNrfSettingsUsage)
+		api.GET("/nrfsettings/:filename/versions/:version", auth.OptionalAuth(), handler.GetNrfSettingsByFileName)
+		api.POST("/nrfsettings/:filename/lint", handler.LintNrfSettings)
+	}
+
+	write := api.Group("", auth.RequireAuth())
+	{
+		write.POST("/nrfsettings", handler.CreateNrfSettings)
+		write.PUT("/nrfsettings/:filename/versions/:version/current", handler.SetCurrentNrfSettingsVersion)
+		write.PUT("/nrfsettings/:filename", handler.UpdateNrfSettings)
+		write.PUT("/nrfsettings/:filename/sleepcdns", handler.UpdateNrfSleepConditions)
+		write.DELETE("/nrfsettings/:filename", handler.DeleteNrfSettings)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/nrfsettings/filetypes", handler.CreateNrfFileType)
 	}
 }
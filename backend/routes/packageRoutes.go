@@ -16,4 +16,5 @@ func PackageRoutes(router *gin.Engine) {
 	api.GET("/packages", handler.GetAllPackages)
 	api.PUT("/package/:fpcode", handler.UpdatePackage)
 	api.DELETE("/package/:fpcode", handler.DeletePackage)
+	api.GET("/firmware/:name/usage", handler.GetFirmwareUsage)
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"filepackage/auth"
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SchemaRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	{
+		api.GET("/schemas", handler.GetSettingsSchemas)
+		api.GET("/schemas/report", handler.GetSchemaValidationReport)
+		api.GET("/schemas/:id", handler.GetSettingsSchema)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/schemas", handler.CreateSettingsSchema)
+		admin.PUT("/schemas/:id/activate", handler.ActivateSettingsSchema)
+	}
+}
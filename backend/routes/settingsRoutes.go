@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SettingsRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	{
+		api.POST("/settings/query", handler.QuerySettings)
+		api.GET("/settings/search", handler.SearchSettings)
+		api.GET("/settings/binary/layouts", handler.GetBinaryLayouts)
+		api.POST("/settings/binary/decode", handler.DecodeSettingsBinary)
+	}
+}
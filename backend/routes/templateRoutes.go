@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"filepackage/auth"
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TemplateRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	{
+		api.GET("/templates", handler.GetSettingsTemplates)
+		api.GET("/templates/:id", handler.GetSettingsTemplate)
+		api.GET("/templates/:id/generations", handler.GetTemplateGenerations)
+	}
+
+	write := api.Group("", auth.RequireAuth())
+	{
+		write.POST("/templates/:id/generate", handler.GenerateFromTemplate)
+		write.POST("/templates/:id/refresh", handler.RefreshTemplateGenerations)
+	}
+
+	admin := api.Group("", auth.RequireRole("admin"))
+	{
+		admin.POST("/templates", handler.CreateSettingsTemplate)
+		admin.PUT("/templates/:id", handler.UpdateSettingsTemplate)
+		admin.DELETE("/templates/:id", handler.DeleteSettingsTemplate)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"filepackage/auth"
+	"filepackage/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ValueMappingRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1/products/api")
+
+	read := api.Group("", auth.OptionalAuth())
+	{
+		read.GET("/valuemappings", handler.GetValueMappings)
+		read.GET("/valuemappings/:id", handler.GetValueMapping)
+	}
+
+	write := api.Group("", auth.RequireAuth())
+	{
+		write.POST("/valuemappings", handler.CreateValueMapping)
+		write.PUT("/valuemappings/:id", handler.UpdateValueMapping)
+		write.DELETE("/valuemappings/:id", handler.DeleteValueMapping)
+	}
+}
@@ -0,0 +1,81 @@
+// Package schemas compiles admin-managed JSON Schemas and validates settings
+// documents against them.
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type Issue struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+var cache sync.Map
+
+// Compile parses a schema document. Schemas are immutable once stored, so
+// compiled schemas are cached by id.
+func Compile(id int, raw []byte) (*jsonschema.Schema, error) {
+	if s, ok := cache.Load(id); ok {
+		return s.(*jsonschema.Schema), nil
+	}
+
+	url := "settingsschema://" + strconv.Itoa(id) + ".json"
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	s, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	if id > 0 {
+		cache.Store(id, s)
+	}
+	return s, nil
+}
+
+// Validate checks doc against s and returns the leaf validation failures.
+func Validate(s *jsonschema.Schema, doc []byte) ([]Issue, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	err := s.Validate(v)
+	if err == nil {
+		return nil, nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return nil, err
+	}
+
+	var issues []Issue
+	collect(ve, &issues)
+	return issues, nil
+}
+
+func collect(ve *jsonschema.ValidationError, issues *[]Issue) {
+	if len(ve.Causes) == 0 {
+		path := ve.InstanceLocation
+		if path == "" {
+			path = "/"
+		}
+		*issues = append(*issues, Issue{Path: path, Keyword: ve.KeywordLocation, Message: ve.Message})
+		return
+	}
+	for _, cause := range ve.Causes {
+		collect(cause, issues)
+	}
+}
@@ -0,0 +1,82 @@
+// Package storage keeps uploaded files on the local filesystem, one folder
+// per kind of file.
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is the directory holding the upload folders. HARNESS_STORAGE_DIR
+// overrides the default.
+var Dir = "/home/akash/harness/"
+
+func init() {
+	if dir := os.Getenv("HARNESS_STORAGE_DIR"); dir != "" {
+		Dir = dir
+	}
+}
+
+var ErrInvalidName = errors.New("invalid file name")
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Path returns the location of name in folder. Names that would leave the
+// folder are rejected.
+func Path(folder, name string) (string, error) {
+	if !validName(name) || (folder != "" && !validName(folder)) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(Dir, folder, name), nil
+}
+
+// Save writes r to name in folder, replacing any file already there. The
+// file appears only once it is completely written.
+func Save(folder, name string, r io.Reader) error {
+	path, err := Path(folder, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func Open(folder, name string) (*os.File, error) {
+	path, err := Path(folder, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Remove deletes name from folder. A file that does not exist is not an
+// error.
+func Remove(folder, name string) error {
+	path, err := Path(folder, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// Package templates generates settings documents from a template body and a
+// set of declared parameters. A string member that is exactly "{{name}}" is
+// replaced by the parameter value with its JSON type; placeholders inside a
+// longer string are replaced by the value's text.
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"filepackage/model"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeHex     = "hex"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	paramName   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	types       = map[string]bool{TypeString: true, TypeInteger: true, TypeNumber: true, TypeBoolean: true, TypeHex: true}
+)
+
+type Issue struct {
+	Parameter string `json:"parameter,omitempty"`
+	Message   string `json:"message"`
+}
+
+func issuef(name, format string, args ...interface{}) Issue {
+	return Issue{Parameter: name, Message: fmt.Sprintf(format, args...)}
+}
+
+// Check validates parameter declarations and that body is a JSON object
+// whose placeholders are all declared.
+func Check(params []model.TemplateParameter, body []byte) []Issue {
+	var issues []Issue
+	declared := map[string]bool{}
+	for _, p := range params {
+		switch {
+		case !paramName.MatchString(p.Name):
+			issues = append(issues, issuef(p.Name, "Parameter name must be an identifier"))
+			continue
+		case declared[p.Name]:
+			issues = append(issues, issuef(p.Name, "Parameter is declared twice"))
+			continue
+		case !types[p.Type]:
+			issues = append(issues, issuef(p.Name, "Unknown parameter type %q", p.Type))
+			continue
+		}
+		declared[p.Name] = true
+		if p.Pattern != "" {
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				issues = append(issues, issuef(p.Name, "Invalid pattern: %v", err))
+				continue
+			}
+		}
+		if p.Default != nil {
+			if _, err := coerce(p, p.Default); err != nil {
+				issues = append(issues, issuef(p.Name, "Invalid default: %v", err))
+			}
+		}
+		for _, v := range p.Enum {
+			if _, err := coerce(p, v); err != nil {
+				issues = append(issues, issuef(p.Name, "Invalid enum value: %v", err))
+			}
+		}
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return append(issues, Issue{Message: "Template body must be a JSON object"})
+	}
+	for _, name := range References(body) {
+		if !declared[name] {
+			issues = append(issues, issuef(name, "Placeholder refers to an undeclared parameter"))
+		}
+	}
+	return issues
+}
+
+// References returns the parameter names used in body, sorted.
+func References(body []byte) []string {
+	seen := map[string]bool{}
+	for _, m := range placeholder.FindAllSubmatch(body, -1) {
+		seen[string(m[1])] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve checks values against params and fills in defaults. The result
+// holds a value for every parameter that has one.
+func Resolve(params []model.TemplateParameter, values map[string]interface{}) (map[string]interface{}, []Issue) {
+	var issues []Issue
+	resolved := map[string]interface{}{}
+	declared := map[string]bool{}
+	for _, p := range params {
+		declared[p.Name] = true
+		value, ok := values[p.Name]
+		if !ok || value == nil {
+			if p.Default == nil {
+				if p.Required {
+					issues = append(issues, issuef(p.Name, "Value is required"))
+				}
+				continue
+			}
+			value = p.Default
+		}
+		v, err := coerce(p, value)
+		if err != nil {
+			issues = append(issues, issuef(p.Name, "%v", err))
+			continue
+		}
+		if err := constrain(p, v); err != nil {
+			issues = append(issues, issuef(p.Name, "%v", err))
+			continue
+		}
+		resolved[p.Name] = v
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			issues = append(issues, issuef(name, "Unknown parameter"))
+		}
+	}
+	return resolved, issues
+}
+
+// Render substitutes values into body. Placeholders without a value are an
+// error.
+func Render(body []byte, values map[string]interface{}) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	out, err := substitute(doc, values)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(out)
+}
+
+func substitute(node interface{}, values map[string]interface{}) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			out, err := substitute(child, values)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = out
+		}
+		return v, nil
+	case []interface{}:
+		for i, child := range v {
+			out, err := substitute(child, values)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = out
+		}
+		return v, nil
+	case string:
+		return substituteString(v, values)
+	}
+	return node, nil
+}
+
+func substituteString(s string, values map[string]interface{}) (interface{}, error) {
+	if m := placeholder.FindStringSubmatchIndex(s); m != nil && m[0] == 0 && m[1] == len(s) {
+		name := s[m[2]:m[3]]
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("no value for parameter %s", name)
+		}
+		return value, nil
+	}
+
+	var err error
+	out := placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		value, ok := values[name]
+		if !ok {
+			err = fmt.Errorf("no value for parameter %s", name)
+			return match
+		}
+		return text(value)
+	})
+	return out, err
+}
+
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprint(value)
+}
+
+// coerce converts a JSON-decoded value to the parameter's type. Hex values
+// may be given as numbers or as strings and are rendered as "0x..." strings.
+func coerce(p model.TemplateParameter, value interface{}) (interface{}, error) {
+	switch p.Type {
+	case TypeString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a string")
+	case TypeBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean")
+	case TypeNumber:
+		if f, ok := number(value); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected a number")
+	case TypeInteger:
+		if f, ok := number(value); ok && f == math.Trunc(f) {
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("expected an integer")
+	case TypeHex:
+		if f, ok := number(value); ok && f >= 0 && f == math.Trunc(f) {
+			return fmt.Sprintf("0x%X", uint64(f)), nil
+		}
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 64); err == nil {
+				return fmt.Sprintf("0x%X", n), nil
+			}
+		}
+		return nil, fmt.Errorf("expected a hexadecimal value")
+	}
+	return nil, fmt.Errorf("unknown type %q", p.Type)
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func constrain(p model.TemplateParameter, value interface{}) error {
+	if len(p.Enum) > 0 {
+		allowed := false
+		for _, e := range p.Enum {
+			if ev, err := coerce(p, e); err == nil && ev == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("value must be one of %v", p.Enum)
+		}
+	}
+
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case int64:
+		f = float64(v)
+	case string:
+		if p.Pattern != "" {
+			if ok, _ := regexp.MatchString(p.Pattern, v); !ok {
+				return fmt.Errorf("value does not match %s", p.Pattern)
+			}
+		}
+		if p.Type != TypeHex {
+			return nil
+		}
+		n, _ := strconv.ParseUint(v, 0, 64)
+		f = float64(n)
+	default:
+		return nil
+	}
+	if p.Min != nil && f < *p.Min {
+		return fmt.Errorf("value must be at least %v", *p.Min)
+	}
+	if p.Max != nil && f > *p.Max {
+		return fmt.Errorf("value must be at most %v", *p.Max)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+func ContentHash(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			data = canonical
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func NrfContentHash(jsonData, sleepCdns []byte) string {
+	sum := sha256.Sum256([]byte(ContentHash(jsonData) + ContentHash(sleepCdns)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// trigrams returns the trigrams of s as pg_trgm builds them: each
+// alphanumeric word is lower-cased and padded with two spaces in front and
+// one behind.
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// TrigramSimilarity returns the share of trigrams common to a and b, from 0
+// for no overlap to 1 for the same trigram sets, like pg_trgm's similarity.
+func TrigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
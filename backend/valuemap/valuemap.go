@@ -0,0 +1,92 @@
+// Package valuemap replaces or annotates raw settings values with display
+// labels. Keys are dot-joined object member names with array indexes
+// omitted, matching the key paths used by the dashboard, e.g.
+// "messages.signals.unit".
+package valuemap
+
+import (
+	"strconv"
+)
+
+// Dictionary maps a key path to raw values and their display labels.
+type Dictionary map[string]map[string]string
+
+// Add registers label for value at key, replacing any earlier label.
+func (d Dictionary) Add(key, value, label string) {
+	values, ok := d[key]
+	if !ok {
+		values = map[string]string{}
+		d[key] = values
+	}
+	values[value] = label
+}
+
+// Lookup returns the label for a scalar value at key.
+func (d Dictionary) Lookup(key string, value interface{}) (string, bool) {
+	values, ok := d[key]
+	if !ok {
+		return "", false
+	}
+	raw, ok := ValueString(value)
+	if !ok {
+		return "", false
+	}
+	label, ok := values[raw]
+	return label, ok
+}
+
+// Apply returns a copy of doc with mapped scalars replaced by their labels.
+func Apply(doc interface{}, dict Dictionary) interface{} {
+	return walk(doc, "", dict, func(value interface{}, label string) interface{} {
+		return label
+	})
+}
+
+// Annotate returns a copy of doc with mapped scalars replaced by
+// {"value": raw, "label": label} objects.
+func Annotate(doc interface{}, dict Dictionary) interface{} {
+	return walk(doc, "", dict, func(value interface{}, label string) interface{} {
+		return map[string]interface{}{"value": value, "label": label}
+	})
+}
+
+func walk(node interface{}, path string, dict Dictionary, mapped func(interface{}, string) interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			out[key] = walk(child, childPath, dict, mapped)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = walk(child, path, dict, mapped)
+		}
+		return out
+	}
+
+	if label, ok := dict.Lookup(path, node); ok {
+		return mapped(node, label)
+	}
+	return node
+}
+
+// ValueString formats a scalar JSON value the way it is keyed in a Dictionary.
+func ValueString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "null", true
+	}
+	return "", false
+}
@@ -19,7 +19,7 @@ export const firmwareApi = {
   },
 
   async getModelSuggestions(groupname: string): Promise<ModelSuggestion[]> {
-    const response = await api.get(`/groups/${encodeURIComponent(groupname)}`);
+    const response = await api.get(`/groups/by-name/${encodeURIComponent(groupname)}/models`);
     return response.data;
   },
 
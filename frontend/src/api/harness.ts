@@ -1,5 +1,5 @@
 import { api } from './config';
-import { HarnessData } from '../types';
+import { HarnessData, StockMovementRequest, StockMovementResult, VehicleImportResult, VehicleSearchResponse } from '../types';
 
 export const harnessApi = {
   async getAllVehicles(): Promise<HarnessData[]> {
@@ -7,29 +7,113 @@ export const harnessApi = {
     return response.data;
   },
 
-  async getVehicle(slno: number): Promise<HarnessData> {
-    const response = await api.get(`/vehicle/${slno}`);
+  async searchVehicles(params: Record<string, string | string[] | number | boolean>): Promise<VehicleSearchResponse> {
+    const response = await api.get('/vehicles', {
+      params,
+      paramsSerializer: { indexes: null },
+    });
+    return response.data;
+  },
+
+  async importVehicles(file: File, options: { dryrun?: boolean; mapping?: Record<string, string>; sheet?: string } = {}): Promise<VehicleImportResult> {
+    const formData = new FormData();
+    formData.append('file', file);
+    if (options.mapping) {
+      formData.append('mapping', JSON.stringify(options.mapping));
+    }
+    if (options.sheet) {
+      formData.append('sheet', options.sheet);
+    }
+
+    const response = await api.post('/vehicles/import', formData, {
+      params: { dryrun: options.dryrun ? 'true' : undefined },
+      headers: {
+        'Content-Type': 'multipart/form-data',
+      },
+      validateStatus: status => status === 200 || status === 422,
+    });
     return response.data;
   },
 
-  async createVehicle(data: any): Promise<void> {
-    await api.post('/vehicle', data, {
+  async downloadImportReport(file: File, options: { mapping?: Record<string, string>; sheet?: string } = {}): Promise<void> {
+    const formData = new FormData();
+    formData.append('file', file);
+    if (options.mapping) {
+      formData.append('mapping', JSON.stringify(options.mapping));
+    }
+    if (options.sheet) {
+      formData.append('sheet', options.sheet);
+    }
+
+    const response = await api.post('/vehicles/import', formData, {
+      params: { dryrun: 'true', report: 'xlsx' },
+      headers: {
+        'Content-Type': 'multipart/form-data',
+      },
+      responseType: 'blob',
+      validateStatus: status => status === 422,
+    });
+    this.saveBlob(response.data, 'import-errors.xlsx');
+  },
+
+  async exportVehicles(format: 'csv' | 'xlsx', filters: Record<string, string | string[]> = {}): Promise<Blob> {
+    const response = await api.get('/vehicles/export', {
+      params: { ...filters, format },
+      paramsSerializer: { indexes: null },
+      responseType: 'blob',
+      timeout: 60000,
+    });
+    return response.data;
+  },
+
+  async getVehicle(harnessid: number): Promise<HarnessData> {
+    const response = await api.get(`/vehicle/${harnessid}`);
+    return response.data;
+  },
+
+  async createVehicle(data: any): Promise<HarnessData> {
+    const response = await api.post('/vehicle', data, {
       headers: {
         'Content-Type': 'application/json',
       },
     });
+    return response.data;
   },
 
-  async updateVehicle(params: string, data: any): Promise<void> {
-    await api.put(`/vehicle/${params}`, data, {
+  async updateVehicle(harnessid: number, data: any): Promise<void> {
+    await api.put(`/vehicle/${harnessid}`, data, {
       headers: {
         'Content-Type': 'application/json',
       },
     });
   },
 
-  async deleteVehicle(params: string): Promise<void> {
-    await api.delete(`/vehicle/${params}`);
+  async deleteVehicle(harnessid: number): Promise<void> {
+    await api.delete(`/vehicle/${harnessid}`);
+  },
+
+  async createStockMovement(harnessid: number, movement: StockMovementRequest): Promise<StockMovementResult> {
+    const response = await api.post(`/vehicle/${harnessid}/stock/movements`, movement, {
+      headers: {
+        'Content-Type': 'application/json',
+      },
+    });
+    return response.data;
+  },
+
+  async uploadAttachment(harnessid: number, kind: 'diagram' | 'harnessimage', file: File): Promise<void> {
+    const formData = new FormData();
+    formData.append('file', file);
+
+    await api.put(`/vehicle/${harnessid}/attachments/${kind}`, formData, {
+      headers: {
+        'Content-Type': 'multipart/form-data',
+      },
+    });
+  },
+
+  async deleteAttachment(harnessid: number, kind: 'diagram' | 'harnessimage'): Promise<void> {
+    await api.delete(`/vehicle/${harnessid}/attachments/${kind}`);
   },
 
   async uploadFile(file: File, folder: string): Promise<string> {
@@ -55,17 +139,21 @@ export const harnessApi = {
     return response.data;
   },
 
+  saveBlob(blob: Blob, filename: string): void {
+    const objectUrl = URL.createObjectURL(blob);
+    const link = document.createElement('a');
+    link.href = objectUrl;
+    link.download = filename;
+    document.body.appendChild(link);
+    link.click();
+    document.body.removeChild(link);
+    URL.revokeObjectURL(objectUrl);
+  },
+
   async downloadFile(folder: string, filename: string): Promise<void> {
     try {
       const blob = await this.getFileContent(folder, filename);
-      const objectUrl = URL.createObjectURL(blob);
-      const link = document.createElement('a');
-      link.href = objectUrl;
-      link.download = filename;
-      document.body.appendChild(link);
-      link.click();
-      document.body.removeChild(link);
-      URL.revokeObjectURL(objectUrl);
+      this.saveBlob(blob, filename);
     } catch (error) {
       console.error('Download error:', error);
       throw error;
@@ -122,7 +122,7 @@ export default function ColumnFilterDialog({
     ];
 
     return {
-      labels: filteredValues.map(item => item.value),
+      labels: filteredValues.map(item => item.value || 'NA'),
       datasets: [{
         data: filteredValues.map(item => item.count),
         backgroundColor: colors.slice(0, filteredValues.length),
@@ -207,7 +207,7 @@ export default function ColumnFilterDialog({
                       onChange={() => handleValueToggle(value)}
                       className="rounded text-purple-600 focus:ring-purple-500"
                     />
-                    <span className="text-sm text-gray-600 flex-1">{value}</span>
+                    <span className="text-sm text-gray-600 flex-1">{value || 'NA'}</span>
                     <span className="text-xs text-gray-400">({count})</span>
                   </label>
                 ))}
@@ -1,25 +1,28 @@
 import React, { useEffect, useState, useCallback } from 'react';
 import { useNavigate } from 'react-router-dom';
+import axios from 'axios';
 import {
   createColumnHelper,
   flexRender,
   getCoreRowModel,
   useReactTable,
   getSortedRowModel,
-  getFilteredRowModel,
 } from '@tanstack/react-table';
-import { Home, LogOut, Edit, Trash2, Plus, RefreshCw, Search, X, Check, Upload, Download, Eye, Loader2, Filter, FilterX } from 'lucide-react';
+import { Home, LogOut, Edit, Trash2, Plus, RefreshCw, Search, X, Check, Upload, Download, Eye, Loader2, Filter, FilterX, ChevronLeft, ChevronRight, ArrowLeftRight } from 'lucide-react';
 import { motion, AnimatePresence } from 'framer-motion';
 import toast from 'react-hot-toast';
 import { useDispatch, useSelector } from 'react-redux';
 import { resetUser } from '../store/slices/userSlice';
 import { authApi, harnessApi } from '../api';
-import type { User, HarnessData } from '../types';
+import type { User, HarnessData, VehicleFacet } from '../types';
 import FileViewer from './FileViewer';
 import HarnessFilters from './HarnessFilters';
 import ColumnFilterDialog from './ColumnFilterDialog';
+import HarnessImportDialog from './HarnessImportDialog';
+import StockMovementDialog from './StockMovementDialog';
 
 const initialFormData: HarnessData = {
+  harnessid: 0,
   slno: 0,
   phcode: '',
   ahcode: '',
@@ -49,14 +52,26 @@ const initialFormData: HarnessData = {
 
 const columnHelper = createColumnHelper<HarnessData>();
 
+const PAGE_SIZE = 50;
+
+// FILTER_COLUMNS are the columns GET /vehicles filters and counts facets on.
+const FILTER_COLUMNS = ['vehicletype', 'vehicleoem', 'vehiclemodel', 'fueltype', 'transmissiontype', 'ignitiontype', 'devicetype', 'immotype', 'can', 'panic'];
+
 function HarnessDetails() {
   const [data, setData] = useState<HarnessData[]>([]);
-  const [filteredData, setFilteredData] = useState<HarnessData[]>([]);
+  const [facets, setFacets] = useState<Record<string, VehicleFacet[]>>({});
+  const [page, setPage] = useState(1);
+  const [total, setTotal] = useState(0);
   const [loading, setLoading] = useState(true);
   const [showForm, setShowForm] = useState(false);
   const [formData, setFormData] = useState<HarnessData>(initialFormData);
   const [isEditing, setIsEditing] = useState(false);
   const [searchQuery, setSearchQuery] = useState('');
+  const [debouncedQuery, setDebouncedQuery] = useState('');
+  const [showImport, setShowImport] = useState(false);
+  const [stockHarness, setStockHarness] = useState<HarnessData | null>(null);
+  const [exportFormat, setExportFormat] = useState<'csv' | 'xlsx'>('xlsx');
+  const [exporting, setExporting] = useState(false);
   const [refreshing, setRefreshing] = useState(false);
   const [showDeleteConfirm, setShowDeleteConfirm] = useState<HarnessData | null>(null);
   const [diagramFile, setDiagramFile] = useState<File | null>(null);
@@ -79,47 +94,60 @@ function HarnessDetails() {
 
   let isAdmin = user.role.toLowerCase() === "admin";
 
-  const normalizeValue = useCallback((val: any): string => {
-    const str = val == null ? '' : String(val).trim();
-    return str === '' || str.toLowerCase() === 'null' || str.toLowerCase() === 'undefined'
-      ? 'NA'
-      : str;
-  }, []);
+  // filterParams turns the active filters and search into GET /vehicles
+  // query parameters.
+  const filterParams = useCallback(() => {
+    const params: Record<string, string | string[]> = {};
+    activeFilters.forEach((values, column) => {
+      params[column] = Array.from(values);
+    });
+    if (debouncedQuery.trim() !== '') {
+      params.q = debouncedQuery.trim();
+    }
+    return params;
+  }, [activeFilters, debouncedQuery]);
 
-  const fetchData = async () => {
+  const fetchData = useCallback(async () => {
     setLoading(true);
     try {
-      const vehicles = await harnessApi.getAllVehicles();
-      setData(vehicles);
-      setFilteredData(vehicles);
+      const response = await harnessApi.searchVehicles({
+        ...filterParams(),
+        page,
+        pagesize: PAGE_SIZE,
+        facets: true,
+      });
+      if (response.results.length === 0 && page > 1) {
+        setPage(Math.max(1, Math.ceil(response.total / PAGE_SIZE)));
+        return;
+      }
+      setData(response.results);
+      setTotal(response.total);
+      setFacets(response.facets || {});
     } catch (error) {
       console.error('Error fetching vehicles:', error);
       toast.error('Failed to fetch vehicles');
     } finally {
       setLoading(false);
     }
-  };
+  }, [filterParams, page]);
 
-useEffect(() => {
-  fetchData();
-}, []);
+  useEffect(() => {
+    fetchData();
+  }, [fetchData]);
 
-const filterDataByFilters = useCallback(
-  (filters: Map<string, Set<string>>, source: HarnessData[]) => {
-    if (filters.size === 0) return source;
-    return source.filter(item =>
-      Array.from(filters.entries()).every(([col, values]) =>
-        values.has(normalizeValue(item[col as keyof HarnessData]))
-      )
-    );
-  },
-  [normalizeValue]
-);
+  useEffect(() => {
+    const timer = setTimeout(() => {
+      setDebouncedQuery(searchQuery);
+      setPage(1);
+    }, 300);
+    return () => clearTimeout(timer);
+  }, [searchQuery]);
 
-useEffect(() => {
-  const filtered = filterDataByFilters(activeFilters, data);
-  setFilteredData(filtered);
-}, [activeFilters, data, filterDataByFilters]);
+  // applyFilters returns to the first page, as the filters change the pages.
+  const applyFilters = (filters: Map<string, Set<string>>) => {
+    setActiveFilters(filters);
+    setPage(1);
+  };
 
   const handleRefresh = async () => {
     setRefreshing(true);
@@ -128,6 +156,20 @@ useEffect(() => {
     toast.success('Data refreshed successfully');
   };
 
+  // handleExport downloads the harnesses matching the current filters.
+  const handleExport = async () => {
+    setExporting(true);
+    try {
+      const blob = await harnessApi.exportVehicles(exportFormat, filterParams());
+      harnessApi.saveBlob(blob, `harnesses.${exportFormat}`);
+    } catch (error) {
+      console.error('Error exporting vehicles:', error);
+      toast.error('Failed to export vehicles');
+    } finally {
+      setExporting(false);
+    }
+  };
+
   const handleSignOut = async () => {
     await authApi.signOut();
     dispatch(resetUser());
@@ -165,13 +207,23 @@ useEffect(() => {
   };
 
   const handleClearAllFilters = () => {
-    setActiveFilters(new Map());
+    applyFilters(new Map());
   };
 
   const handleClearFilter = (columnId: string) => {
     const newFilters = new Map(activeFilters);
     newFilters.delete(columnId);
-    setActiveFilters(newFilters);
+    applyFilters(newFilters);
+  };
+
+  const handleFilterChange = (columnId: string, values: Set<string>) => {
+    const newFilters = new Map(activeFilters);
+    if (values.size > 0) {
+      newFilters.set(columnId, values);
+    } else {
+      newFilters.delete(columnId);
+    }
+    applyFilters(newFilters);
   };
 
   const handleFilterValueToggle = (value: string) => {
@@ -190,21 +242,21 @@ useEffect(() => {
     } else {
       newFilters.delete(activeFilter.column);
     }
-    setActiveFilters(newFilters);
+    applyFilters(newFilters);
     setActiveFilter({ ...activeFilter, values: newValues });
   };
 
   const handleDelete = async (vehicleDetails: HarnessData) => {
     try {
-      const params = `${vehicleDetails.phcode},${vehicleDetails.vehicleoem},${vehicleDetails.vehiclemodel},${vehicleDetails.vehiclevariant},${vehicleDetails.yearofmfg}`;
-      
-      await harnessApi.deleteVehicle(params);
+      await harnessApi.deleteVehicle(vehicleDetails.harnessid);
       
       await fetchData();
       toast.success('Vehicle deleted successfully');
     } catch (error) {
       console.error('Error deleting vehicle:', error);
-      toast.error('Failed to delete vehicle');
+      toast.error(axios.isAxiosError(error) && error.response?.status === 409
+        ? error.response.data.error
+        : 'Failed to delete vehicle');
     } finally {
       setShowDeleteConfirm(null);
     }
@@ -219,42 +271,33 @@ useEffect(() => {
     } else {
       newFilters.delete(activeFilter.column);
     }
-    setActiveFilters(newFilters);
+    applyFilters(newFilters);
     setActiveFilter(null);
   };
 
   const renderColumnHeader = (column: any) => (
     <div className="flex items-center gap-2">
       <span>{column.id.charAt(0).toUpperCase() + column.id.slice(1)}</span>
-      <div className="flex items-center">
-        <button
-          onClick={() => openFilterDialog(column)}
-          className={`p-1 rounded transition-colors ${
-            activeFilters.has(column.id)
-              ? 'text-purple-600 bg-purple-100 hover:bg-purple-200'
-              : 'text-gray-400 hover:bg-gray-100'
-          }`}
-        >
-          <Filter className="w-4 h-4" />
-        </button>
-      </div>
+      {FILTER_COLUMNS.includes(column.id) && (
+        <div className="flex items-center">
+          <button
+            onClick={() => openFilterDialog(column)}
+            className={`p-1 rounded transition-colors ${
+              activeFilters.has(column.id)
+                ? 'text-purple-600 bg-purple-100 hover:bg-purple-200'
+                : 'text-gray-400 hover:bg-gray-100'
+            }`}
+          >
+            <Filter className="w-4 h-4" />
+          </button>
+        </div>
+      )}
     </div>
   );
 
   const openFilterDialog = (column: any) => {
     const colId = column.id;
 
-    // Prepare a copy of activeFilters without the current column
-    const filtersExcludingCurrent = new Map(activeFilters);
-    filtersExcludingCurrent.delete(colId);
-
-    // Filter data using all filters except the current column
-    const filtered = Array.from(filtersExcludingCurrent.entries()).reduce(
-      (acc, [filterCol, values]) =>
-        acc.filter(item => values.has(normalizeValue(item[filterCol as keyof HarnessData]))),
-      data
-    );
-
     // If a filter is already active for this column, use its values
     const existing = activeFilters.get(colId);
     if (existing) {
@@ -262,10 +305,8 @@ useEffect(() => {
       return;
     }
 
-    // Compute unique values from the filtered data
-    const allValues = new Set(
-      filtered.map(item => normalizeValue(item[colId as keyof HarnessData]))
-    );
+    // The facet counts of a column apply every filter but its own
+    const allValues = new Set((facets[colId] || []).map(facet => facet.value));
     setActiveFilter({ column: colId, values: allValues });
   };
 
@@ -276,7 +317,6 @@ useEffect(() => {
         const updatedFormData = {
             phcode: formData.phcode,
             ahcode: formData.ahcode,
-            currentstock: formData.currentstock,
             vehicletype: formData.vehicletype,
             vehicleoem: formData.vehicleoem,
             vehiclemodel: formData.vehiclemodel,
@@ -294,29 +334,31 @@ useEffect(() => {
             devversion: formData.devversion,
             rev: formData.rev,
             description: formData.description,
-            diagram: diagramFile ? diagramFile.name : formData.diagram,
-            harnessimage: harnessImageFile ? harnessImageFile.name : formData.harnessimage,
+            diagram: diagramFile ? '' : formData.diagram,
+            harnessimage: harnessImageFile ? '' : formData.harnessimage,
             updatedat: Date.now(),
             updatedby: user?.email || '',
         };
 
+        // The stock is set once on creation and then changes through stock movements only.
+        let harnessid: number;
+        if (isEditing && originalData) {
+            await harnessApi.updateVehicle(originalData.harnessid, updatedFormData);
+            harnessid = originalData.harnessid;
+        } else {
+            harnessid = (await harnessApi.createVehicle({ ...updatedFormData, currentstock: formData.currentstock })).harnessid;
+        }
+
         if (diagramFile) {
-          await harnessApi.uploadFile(diagramFile, 'diagram');
+          await harnessApi.uploadAttachment(harnessid, 'diagram', diagramFile);
         }
-  
-        if (harnessImageFile) {
-          await harnessApi.uploadFile(harnessImageFile, 'harnessimage');
-        }  
 
-        if (isEditing && originalData) {
-            const params = `${originalData.phcode},${originalData.vehicleoem},${originalData.vehiclemodel},${originalData.vehiclevariant},${originalData.yearofmfg}`;
-            await harnessApi.updateVehicle(params, updatedFormData);
-            toast.success('Vehicle updated successfully');
-        } else {
-            await harnessApi.createVehicle(updatedFormData);
-            toast.success('Vehicle added successfully');
+        if (harnessImageFile) {
+          await harnessApi.uploadAttachment(harnessid, 'harnessimage', harnessImageFile);
         }
 
+        toast.success(isEditing ? 'Vehicle updated successfully' : 'Vehicle added successfully');
+
         await fetchData();
         setShowForm(false);
         setFormData(initialFormData);
@@ -329,7 +371,7 @@ useEffect(() => {
         if (error instanceof Error && 'response' in error && (error as any).response?.data) {
             // If the error is from the server
             console.error('Server responded with:', (error as any).response.data);
-            toast.error(`Failed to save vehicle: ${(error as any).response.data.message || 'Duplicate entry detected, Please use unique values'}`);
+            toast.error(`Failed to save vehicle: ${(error as any).response.data.error || 'Duplicate entry detected, Please use unique values'}`);
         } else {
             // If the error is a network error
             toast.error('Network error: Please check your connection or server status.');
@@ -558,6 +600,13 @@ useEffect(() => {
             >
               <Edit className="w-4 h-4" />
             </button>
+            <button
+              onClick={() => setStockHarness(props.row.original)}
+              title="Record stock movement"
+              className="p-1.5 bg-gradient-to-r from-emerald-400 to-emerald-500 text-white rounded-lg hover:from-emerald-500 hover:to-emerald-600 transition-all duration-200"
+            >
+              <ArrowLeftRight className="w-4 h-4" />
+            </button>
             <button
               onClick={() => setShowDeleteConfirm(props.row.original)}
               className="p-1.5 bg-gradient-to-r from-red-400 to-red-500 text-white rounded-lg hover:from-red-500 hover:to-red-600 transition-all duration-200"
@@ -578,17 +627,14 @@ useEffect(() => {
     setHarnessImageFile(null);
   };
   const table = useReactTable({
-    data: filteredData,
+    data,
     columns,
     getCoreRowModel: getCoreRowModel(),
     getSortedRowModel: getSortedRowModel(),
-    getFilteredRowModel: getFilteredRowModel(),
-    state: {
-      globalFilter: searchQuery,
-    },
-    onGlobalFilterChange: setSearchQuery,
   });
 
+  const pageCount = Math.max(1, Math.ceil(total / PAGE_SIZE));
+
   return (
     <div className="flex flex-col h-screen">
       <nav className="bg-gradient-to-r from-emerald-600 to-teal-600 shadow-lg">
@@ -640,7 +686,7 @@ useEffect(() => {
                   type="text"
                   value={searchQuery}
                   onChange={(e) => setSearchQuery(e.target.value)}
-                  placeholder="Search harness details..."
+                  placeholder="Search description and specification..."
                   className="w-full pl-10 pr-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
                 />
                 <Search className="w-5 h-5 text-gray-400 absolute left-3 top-2.5" />
@@ -666,6 +712,36 @@ useEffect(() => {
                 <RefreshCw className={`w-4 h-4 ${refreshing ? 'animate-spin' : ''}`} />
                 Refresh
               </button>
+              <div className="flex">
+                <select
+                  value={exportFormat}
+                  onChange={(e) => setExportFormat(e.target.value as 'csv' | 'xlsx')}
+                  className="px-2 py-2 border border-r-0 border-gray-300 rounded-l-lg text-sm focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+                  aria-label="Export format"
+                >
+                  <option value="xlsx">XLSX</option>
+                  <option value="csv">CSV</option>
+                </select>
+                <button
+                  onClick={handleExport}
+                  disabled={exporting}
+                  className={`flex items-center gap-2 px-4 py-2 bg-gradient-to-r from-indigo-500 to-purple-500 text-white rounded-r-lg hover:from-indigo-600 hover:to-purple-600 transition-all duration-200 ${
+                    exporting ? 'opacity-50 cursor-not-allowed' : ''
+                  }`}
+                >
+                  {exporting ? <Loader2 className="w-4 h-4 animate-spin" /> : <Download className="w-4 h-4" />}
+                  Export
+                </button>
+              </div>
+              {isAdmin && (
+                <button
+                  onClick={() => setShowImport(true)}
+                  className="flex items-center gap-2 px-4 py-2 bg-gradient-to-r from-amber-500 to-orange-500 text-white rounded-lg hover:from-amber-600 hover:to-orange-600 transition-all duration-200"
+                >
+                  <Upload className="w-4 h-4" />
+                  Import
+                </button>
+              )}
               {isAdmin && (
                 <button
                   className="flex items-center gap-2 px-4 py-2 bg-gradient-to-r from-emerald-500 to-teal-500 text-white rounded-lg hover:from-emerald-600 hover:to-teal-600 transition-all duration-200"
@@ -682,6 +758,13 @@ useEffect(() => {
               )}
             </div>
           </div>
+          <div className="mt-3">
+            <HarnessFilters
+              facets={facets}
+              filters={activeFilters}
+              onFilterChange={handleFilterChange}
+            />
+          </div>
         </div>
       </div>
 
@@ -737,7 +820,47 @@ useEffect(() => {
         </div>
       </div>
 
+      <div className="bg-white border-t border-gray-200 px-4 sm:px-6 lg:px-8 py-3 flex items-center justify-between">
+        <span className="text-sm text-gray-600">
+          {total} {total === 1 ? 'harness' : 'harnesses'}
+        </span>
+        <div className="flex items-center gap-3">
+          <button
+            onClick={() => setPage(page - 1)}
+            disabled={page <= 1 || loading}
+            className="p-1.5 rounded-lg text-gray-600 hover:bg-gray-100 disabled:opacity-50 disabled:cursor-not-allowed"
+            aria-label="Previous page"
+          >
+            <ChevronLeft className="w-5 h-5" />
+          </button>
+          <span className="text-sm text-gray-600">
+            Page {page} of {pageCount}
+          </span>
+          <button
+            onClick={() => setPage(page + 1)}
+            disabled={page >= pageCount || loading}
+            className="p-1.5 rounded-lg text-gray-600 hover:bg-gray-100 disabled:opacity-50 disabled:cursor-not-allowed"
+            aria-label="Next page"
+          >
+            <ChevronRight className="w-5 h-5" />
+          </button>
+        </div>
+      </div>
+
       <AnimatePresence>
+        {showImport && (
+          <HarnessImportDialog
+            onClose={() => setShowImport(false)}
+            onImported={fetchData}
+          />
+        )}
+        {stockHarness && (
+          <StockMovementDialog
+            harness={stockHarness}
+            onClose={() => setStockHarness(null)}
+            onRecorded={fetchData}
+          />
+        )}
         {showFileViewer && (
           <FileViewer
             folder={viewerFolder}
@@ -747,30 +870,9 @@ useEffect(() => {
         )}
         {activeFilter && (
           (() => {
-            // Filter data by all active filters except the current column
-            const filtersExcludingCurrent = new Map(activeFilters);
-            filtersExcludingCurrent.delete(activeFilter.column);
-            const filtered = Array.from(filtersExcludingCurrent.entries()).reduce(
-              (acc, [filterCol, values]) =>
-                acc.filter(item => values.has(normalizeValue(item[filterCol as keyof HarnessData]))),
-              data
-            );
-            const uniqueValues = Array.from(
-              new Set(
-                filtered.map(item =>
-                  normalizeValue(item[activeFilter.column as keyof HarnessData])
-                )
-              )
-            )
-              .sort()
-              .map(value => ({
-                value,
-                count: filtered.filter(
-                  item =>
-                    normalizeValue(item[activeFilter.column as keyof HarnessData]) ===
-                    value
-                ).length
-              }));
+            const uniqueValues = (facets[activeFilter.column] || [])
+              .map(facet => ({ value: facet.value, count: facet.count }))
+              .sort((a, b) => a.value.localeCompare(b.value));
             return (
               <ColumnFilterDialog
                 isOpen={true}
@@ -839,8 +941,14 @@ useEffect(() => {
                       name="currentstock"
                       value={formData.currentstock}
                       onChange={(e) => setFormData({ ...formData, currentstock: Number(e.target.value) })}
-                      className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+                      readOnly={isEditing}
+                      className={`w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent ${isEditing ? 'bg-gray-100 text-gray-500' : ''}`}
                     />
+                    {isEditing && (
+                      <p className="mt-1 text-xs text-gray-500">
+                        Record a stock movement to change the stock.
+                      </p>
+                    )}
                   </div>
                   <div>
                     <label className="block text-sm font-medium text-gray-700 mb-1">
@@ -0,0 +1,235 @@
+import React, { useState } from 'react';
+import { motion } from 'framer-motion';
+import { X, Upload, Download, Loader2, AlertCircle } from 'lucide-react';
+import toast from 'react-hot-toast';
+import { harnessApi } from '../api';
+import type { VehicleImportResult } from '../types';
+
+// IMPORT_COLUMNS are the harness columns an import can fill.
+const IMPORT_COLUMNS = [
+  'phcode', 'ahcode', 'currentstock', 'vehicletype', 'vehicleoem', 'vehiclemodel', 'vehiclevariant', 'yearofmfg',
+  'fueltype', 'transmissiontype', 'ignitiontype', 'devicetype', 'specification', 'immotype', 'immorelayvoltage',
+  'can', 'panic', 'devversion', 'harnessimage', 'diagram', 'description',
+];
+
+interface HarnessImportDialogProps {
+  onClose: () => void;
+  onImported: () => void;
+}
+
+const errorMessage = (error: unknown, fallback: string) =>
+  (error as any)?.response?.data?.error || fallback;
+
+export default function HarnessImportDialog({ onClose, onImported }: HarnessImportDialogProps) {
+  const [file, setFile] = useState<File | null>(null);
+  const [sheet, setSheet] = useState('');
+  const [mapping, setMapping] = useState<Record<string, string>>({});
+  const [unmatched, setUnmatched] = useState<string[]>([]);
+  const [result, setResult] = useState<VehicleImportResult | null>(null);
+  const [busy, setBusy] = useState(false);
+
+  const options = () => ({
+    mapping: Object.keys(mapping).length > 0 ? mapping : undefined,
+    sheet: sheet.trim() || undefined,
+  });
+
+  const handleFileChange = (event: React.ChangeEvent<HTMLInputElement>) => {
+    setFile(event.target.files?.[0] || null);
+    setMapping({});
+    setUnmatched([]);
+    setResult(null);
+  };
+
+  const handleMappingChange = (header: string, column: string) => {
+    setMapping(prev => {
+      const next = { ...prev };
+      if (column) {
+        next[header] = column;
+      } else {
+        delete next[header];
+      }
+      return next;
+    });
+    setResult(null);
+  };
+
+  // Validate runs the import as a dry run, so nothing is saved.
+  const handleValidate = async () => {
+    if (!file) return;
+    setBusy(true);
+    try {
+      const validated = await harnessApi.importVehicles(file, { ...options(), dryrun: true });
+      // Mapped headers drop out of ignored but stay listed, so they can be changed back.
+      setUnmatched(prev => Array.from(new Set([...prev, ...validated.ignored])));
+      setResult(validated);
+    } catch (error) {
+      console.error('Error validating import:', error);
+      toast.error(errorMessage(error, 'Failed to validate file'));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const handleImport = async () => {
+    if (!file) return;
+    setBusy(true);
+    try {
+      const imported = await harnessApi.importVehicles(file, options());
+      if (imported.issues && imported.issues.length > 0) {
+        setResult(imported);
+        toast.error('Nothing was imported, the file has issues');
+        return;
+      }
+      toast.success(`Imported ${imported.rows} rows: ${imported.created} created, ${imported.updated} updated`);
+      onImported();
+      onClose();
+    } catch (error) {
+      console.error('Error importing vehicles:', error);
+      toast.error(errorMessage(error, 'Failed to import file'));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const handleDownloadReport = async () => {
+    if (!file) return;
+    try {
+      await harnessApi.downloadImportReport(file, options());
+    } catch (error) {
+      console.error('Error downloading import report:', error);
+      toast.error('Failed to download error report');
+    }
+  };
+
+  const issues = result?.issues || [];
+
+  return (
+    <div className="fixed inset-0 bg-black/50 flex items-center justify-center z-50">
+      <motion.div
+        initial={{ opacity: 0, scale: 0.95 }}
+        animate={{ opacity: 1, scale: 1 }}
+        exit={{ opacity: 0, scale: 0.95 }}
+        className="bg-white rounded-lg shadow-xl w-full max-w-2xl max-h-[90vh] overflow-y-auto"
+      >
+        <div className="p-4 border-b border-gray-200 flex justify-between items-center">
+          <h3 className="text-lg font-medium text-gray-900">
+            Import Harnesses
+          </h3>
+          <button
+            onClick={onClose}
+            className="text-gray-400 hover:text-gray-500"
+          >
+            <X className="w-5 h-5" />
+          </button>
+        </div>
+
+        <div className="p-4 space-y-4">
+          <div className="grid grid-cols-1 sm:grid-cols-2 gap-4">
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">CSV or XLSX file</span>
+              <input
+                type="file"
+                accept=".csv,.xlsx"
+                onChange={handleFileChange}
+                className="mt-1 block w-full text-sm text-gray-600"
+              />
+            </label>
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">Sheet (XLSX only)</span>
+              <input
+                type="text"
+                value={sheet}
+                onChange={(e) => {
+                  setSheet(e.target.value);
+                  setResult(null);
+                }}
+                placeholder="First sheet"
+                className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+              />
+            </label>
+          </div>
+
+          {unmatched.length > 0 && (
+            <div>
+              <h4 className="text-sm font-medium text-gray-700 mb-2">Unmatched headers</h4>
+              <div className="space-y-2">
+                {unmatched.map(header => (
+                  <div key={header} className="flex items-center gap-3">
+                    <span className="text-sm text-gray-600 w-1/2 truncate">{header}</span>
+                    <select
+                      value={mapping[header] || ''}
+                      onChange={(e) => handleMappingChange(header, e.target.value)}
+                      className="w-1/2 px-3 py-1.5 border border-gray-300 rounded-lg text-sm focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+                    >
+                      <option value="">Skip</option>
+                      {IMPORT_COLUMNS.map(column => (
+                        <option key={column} value={column}>{column}</option>
+                      ))}
+                    </select>
+                  </div>
+                ))}
+              </div>
+            </div>
+          )}
+
+          {result && (
+            <div className="space-y-3">
+              <p className="text-sm text-gray-600">
+                {result.rows} rows: {result.created} to create, {result.updated} to update, {result.unchanged} unchanged
+              </p>
+              {issues.length > 0 && (
+                <div>
+                  <div className="flex items-center justify-between mb-2">
+                    <h4 className="flex items-center gap-2 text-sm font-medium text-red-600">
+                      <AlertCircle className="w-4 h-4" />
+                      {issues.length} {issues.length === 1 ? 'issue' : 'issues'}
+                    </h4>
+                    <button
+                      onClick={handleDownloadReport}
+                      className="flex items-center gap-2 px-3 py-1.5 text-sm text-red-600 bg-red-50 hover:bg-red-100 rounded-lg transition-colors"
+                    >
+                      <Download className="w-4 h-4" />
+                      Error Report
+                    </button>
+                  </div>
+                  <ul className="max-h-48 overflow-y-auto border border-gray-200 rounded-lg divide-y divide-gray-200">
+                    {issues.map((issue, i) => (
+                      <li key={i} className="px-3 py-2 text-sm text-gray-600">
+                        Row {issue.row}{issue.column ? `, ${issue.column}` : ''}: {issue.message}
+                      </li>
+                    ))}
+                  </ul>
+                </div>
+              )}
+            </div>
+          )}
+        </div>
+
+        <div className="p-4 border-t border-gray-200 flex justify-end gap-2">
+          <button
+            onClick={onClose}
+            className="px-4 py-2 text-gray-600 bg-gray-100 rounded-lg hover:bg-gray-200"
+          >
+            Cancel
+          </button>
+          <button
+            onClick={handleValidate}
+            disabled={!file || busy}
+            className="flex items-center gap-2 px-4 py-2 text-emerald-700 bg-emerald-50 rounded-lg hover:bg-emerald-100 disabled:opacity-50 disabled:cursor-not-allowed"
+          >
+            {busy && <Loader2 className="w-4 h-4 animate-spin" />}
+            Validate
+          </button>
+          <button
+            onClick={handleImport}
+            disabled={!result || issues.length > 0 || busy}
+            className="flex items-center gap-2 px-4 py-2 bg-emerald-600 text-white rounded-lg hover:bg-emerald-700 disabled:opacity-50 disabled:cursor-not-allowed"
+          >
+            <Upload className="w-4 h-4" />
+            Import
+          </button>
+        </div>
+      </motion.div>
+    </div>
+  );
+}
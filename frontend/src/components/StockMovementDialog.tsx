@@ -0,0 +1,169 @@
+import React, { useState } from 'react';
+import { motion } from 'framer-motion';
+import { X, Loader2 } from 'lucide-react';
+import toast from 'react-hot-toast';
+import { harnessApi } from '../api';
+import type { HarnessData, StockMovementRequest, StockMovementType } from '../types';
+
+const MOVEMENT_TYPES: { value: StockMovementType; label: string }[] = [
+  { value: 'in', label: 'Stock in' },
+  { value: 'out', label: 'Stock out' },
+  { value: 'adjustment', label: 'Adjustment' },
+  { value: 'transfer', label: 'Transfer' },
+];
+
+interface StockMovementDialogProps {
+  harness: HarnessData;
+  onClose: () => void;
+  onRecorded: () => void;
+}
+
+const errorMessage = (error: unknown, fallback: string) =>
+  (error as any)?.response?.data?.error || fallback;
+
+export default function StockMovementDialog({ harness, onClose, onRecorded }: StockMovementDialogProps) {
+  const [type, setType] = useState<StockMovementType>('in');
+  const [quantity, setQuantity] = useState('');
+  const [reason, setReason] = useState('');
+  const [reference, setReference] = useState('');
+  const [target, setTarget] = useState('');
+  const [busy, setBusy] = useState(false);
+
+  // Adjustments give the signed change, every other type a positive quantity.
+  const amount = Number(quantity);
+  const validQuantity = Number.isInteger(amount) && (type === 'adjustment' ? amount !== 0 : amount > 0);
+  const validTarget = type !== 'transfer' || (Number.isInteger(Number(target)) && Number(target) > 0 && Number(target) !== harness.harnessid);
+  const canSubmit = quantity.trim() !== '' && validQuantity && validTarget && (type !== 'adjustment' || reason.trim() !== '');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!canSubmit) return;
+    setBusy(true);
+    try {
+      const movement: StockMovementRequest = {
+        type,
+        quantity: amount,
+        reason: reason.trim() || undefined,
+        reference: reference.trim() || undefined,
+        targetharnessid: type === 'transfer' ? Number(target) : undefined,
+      };
+      const result = await harnessApi.createStockMovement(harness.harnessid, movement);
+      toast.success(`Stock of ${harness.phcode} is now ${result.currentstock}`);
+      onRecorded();
+      onClose();
+    } catch (error) {
+      console.error('Error recording stock movement:', error);
+      toast.error(errorMessage(error, 'Failed to record stock movement'));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  return (
+    <div className="fixed inset-0 bg-black/50 flex items-center justify-center z-50">
+      <motion.div
+        initial={{ opacity: 0, scale: 0.95 }}
+        animate={{ opacity: 1, scale: 1 }}
+        exit={{ opacity: 0, scale: 0.95 }}
+        className="bg-white rounded-lg shadow-xl w-full max-w-md"
+      >
+        <div className="p-4 border-b border-gray-200 flex justify-between items-center">
+          <h3 className="text-lg font-medium text-gray-900">
+            Stock Movement: {harness.phcode}
+          </h3>
+          <button
+            onClick={onClose}
+            className="text-gray-400 hover:text-gray-500"
+          >
+            <X className="w-5 h-5" />
+          </button>
+        </div>
+
+        <form onSubmit={handleSubmit}>
+          <div className="p-4 space-y-4">
+            <p className="text-sm text-gray-600">
+              Current stock: {harness.currentstock}
+            </p>
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">Type</span>
+              <select
+                value={type}
+                onChange={(e) => setType(e.target.value as StockMovementType)}
+                className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+              >
+                {MOVEMENT_TYPES.map(option => (
+                  <option key={option.value} value={option.value}>{option.label}</option>
+                ))}
+              </select>
+            </label>
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">
+                {type === 'adjustment' ? 'Change (negative to reduce)' : 'Quantity'}
+              </span>
+              <input
+                type="number"
+                step={1}
+                min={type === 'adjustment' ? undefined : 1}
+                value={quantity}
+                onChange={(e) => setQuantity(e.target.value)}
+                className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+              />
+            </label>
+            {type === 'transfer' && (
+              <label className="block">
+                <span className="text-sm font-medium text-gray-700">Target harness ID</span>
+                <input
+                  type="number"
+                  step={1}
+                  min={1}
+                  value={target}
+                  onChange={(e) => setTarget(e.target.value)}
+                  className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+                />
+              </label>
+            )}
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">
+                Reason{type === 'adjustment' ? '' : ' (optional)'}
+              </span>
+              <input
+                type="text"
+                value={reason}
+                onChange={(e) => setReason(e.target.value)}
+                className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+              />
+            </label>
+            <label className="block">
+              <span className="text-sm font-medium text-gray-700">Reference (optional)</span>
+              <input
+                type="text"
+                value={reference}
+                onChange={(e) => setReference(e.target.value)}
+                placeholder="Order or invoice number"
+                className="mt-1 w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-emerald-500 focus:border-transparent"
+              />
+            </label>
+          </div>
+
+          <div className="p-4 border-t border-gray-200 flex justify-end gap-2">
+            <button
+              type="button"
+              onClick={onClose}
+              className="px-4 py-2 text-gray-600 bg-gray-100 rounded-lg hover:bg-gray-200"
+            >
+              Cancel
+            </button>
+            <button
+              type="submit"
+              disabled={!canSubmit || busy}
+              className="flex items-center gap-2 px-4 py-2 bg-emerald-600 text-white rounded-lg hover:bg-emerald-700 disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {busy && <Loader2 className="w-4 h-4 animate-spin" />}
+              Record
+            </button>
+          </div>
+        </form>
+      </motion.div>
+    </div>
+  );
+}
@@ -25,6 +25,65 @@ export interface FirmwareData {
   updatedat: number;
 }
 
+export interface VehicleFacet {
+  value: string;
+  count: number;
+}
+
+export interface VehicleImportIssue {
+  row: number;
+  column?: string;
+  message: string;
+}
+
+export interface VehicleImportResult {
+  dryrun: boolean;
+  rows: number;
+  created: number;
+  updated: number;
+  unchanged: number;
+  ignored: string[];
+  issues: VehicleImportIssue[] | null;
+}
+
+export interface VehicleSearchResponse {
+  page: number;
+  pagesize: number;
+  total: number;
+  results: HarnessData[];
+  facets?: Record<string, VehicleFacet[]>;
+}
+
+export type StockMovementType = 'in' | 'out' | 'adjustment' | 'transfer';
+
+export interface StockMovementRequest {
+  type: StockMovementType;
+  quantity: number;
+  reason?: string;
+  reference?: string;
+  rev?: number;
+  targetharnessid?: number;
+}
+
+export interface StockMovement {
+  id: number;
+  harnessid: number;
+  rev: number;
+  type: StockMovementType;
+  quantity: number;
+  balance: number;
+  reason: string;
+  reference: string;
+  counterpartid?: number;
+  createdby: string;
+  createdat: number;
+}
+
+export interface StockMovementResult {
+  movements: StockMovement[];
+  currentstock: number;
+}
+
 export interface GroupSuggestion {
   groupname: string;
   groupid: number;
@@ -50,6 +109,7 @@ export interface UserManagement extends User {
 }
 
 export interface HarnessData {
+  harnessid: number;
   slno: number;
   phcode: string;
   ahcode: string;